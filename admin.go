@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/tip/config"
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/crypto/encoding"
+	"github.com/MixinNetwork/tip/keeper"
+	"github.com/MixinNetwork/tip/store"
+	"github.com/urfave/cli/v2"
+)
+
+func adminActionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "operator",
+			Usage:    "The operator name recorded in the audit",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "reason",
+			Usage:    "The reason recorded in the audit",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "confirm",
+			Usage:    "Repeat the pubkey or watcher to confirm the action",
+			Required: true,
+		},
+	}
+}
+
+// openAdmin prefers the admin socket of the running process, and opens the
+// database directly only when no process serves the socket. The read only
+// commands never migrate or write the database opened directly.
+func openAdmin(ctx context.Context, conf *store.Configuration, readOnly bool) (store.Admin, func(), error) {
+	if conf.AdminSocket != "" {
+		client := store.NewAdminClient(conf.AdminSocket)
+		if client.Ping() == nil {
+			return client, func() {}, nil
+		}
+	}
+	err := requireBadger(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if readOnly {
+		bs, err := store.OpenBadgerReadOnly(&store.Configuration{Dir: conf.Dir})
+		if err != nil {
+			return nil, nil, err
+		}
+		return bs, bs.Close, nil
+	}
+	bs, err := store.OpenBadger(ctx, &store.Configuration{Dir: conf.Dir})
+	if err != nil {
+		return nil, nil, err
+	}
+	return bs, bs.Close, nil
+}
+
+// the operator commands rely on badger backup and transactions, the other
+// engines should be converted to badger first
+func requireBadger(conf *store.Configuration) error {
+	if conf.Engine != "" && conf.Engine != store.EngineBadger {
+		return fmt.Errorf("operator command not supported by store engine %s", conf.Engine)
+	}
+	return nil
+}
+
+func parseIdentityArg(c *cli.Context) ([]byte, error) {
+	arg := c.Args().First()
+	if b, err := hex.DecodeString(arg); err == nil && len(b) == 32 {
+		return b, nil
+	}
+	pub, err := encoding.ParsePublicKey(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey or watcher %s", arg)
+	}
+	return crypto.PublicKeyBytes(pub), nil
+}
+
+func readIdentityState(c *cli.Context, readOnly bool) (store.Admin, func(), *store.IdentityState, error) {
+	key, err := parseIdentityArg(c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	conf, err := config.ReadConfiguration(c.String("config"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	admin, close, err := openAdmin(context.Background(), conf.Store, readOnly)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	state, err := admin.InspectIdentity(key)
+	if err != nil {
+		close()
+		return nil, nil, nil, err
+	}
+	if state == nil {
+		close()
+		return nil, nil, nil, fmt.Errorf("identity %s not found", c.Args().First())
+	}
+	return admin, close, state, nil
+}
+
+func inspectIdentity(c *cli.Context) error {
+	_, close, state, err := readIdentityState(c, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	now := time.Now()
+	fmt.Println("key", hex.EncodeToString(state.Key))
+	fmt.Println("watcher", hex.EncodeToString(state.Watcher))
+	fmt.Println("assignee", hex.EncodeToString(state.Assignee))
+	fmt.Println("assignor", hex.EncodeToString(state.Assignor))
+	fmt.Println("genesis", state.Genesis)
+	fmt.Println("counter", state.Counter)
+	fmt.Println("ephemeral", hex.EncodeToString(state.Ephemeral))
+	fmt.Println("nonce", state.Nonce, state.NonceAt)
+	if state.Grace > 0 {
+		fmt.Println("grace", state.Grace, state.GraceExpiry)
+	} else if !state.NonceAt.IsZero() {
+		// the nonce recorded before the grace stored is never swept
+		fmt.Println("grace unknown")
+	}
+	for _, l := range state.Limits {
+		window, quota := keeper.LimitWindowQuota(l.Kind)
+		var used int
+		for _, e := range l.Entries {
+			if e.Add(window).After(now) {
+				used++
+			}
+		}
+		fmt.Printf("limit %x %s window %s quota %d remaining %d\n", l.Key, l.Kind, window, quota, max(quota-used, 0))
+		for _, e := range l.Entries {
+			fmt.Printf("    %s expired %t\n", e, !e.Add(window).After(now))
+		}
+	}
+	return nil
+}
+
+func resetIdentityLimit(c *cli.Context) error {
+	kind := c.String("kind")
+	if window, _ := keeper.LimitWindowQuota(kind); window == 0 {
+		return fmt.Errorf("invalid limit kind %s", kind)
+	}
+	if c.String("confirm") != c.Args().First() {
+		return fmt.Errorf("confirm %s not match %s", c.String("confirm"), c.Args().First())
+	}
+	admin, close, state, err := readIdentityState(c, false)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	// the keeper throttles the assignor, the same as the nonce
+	assignor := state.Assignor
+	if assignor == nil {
+		assignor = state.Key
+	}
+	key := append(append([]byte{}, assignor...), kind...)
+	action, err := admin.ResetLimit(key, &store.AdminAction{
+		Operator: c.String("operator"),
+		Reason:   c.String("reason"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(action.Action, hex.EncodeToString(assignor), kind, action.Deleted, action.CreatedAt)
+	return nil
+}
+
+func clearIdentityNonce(c *cli.Context) error {
+	if c.String("confirm") != c.Args().First() {
+		return fmt.Errorf("confirm %s not match %s", c.String("confirm"), c.Args().First())
+	}
+	admin, close, state, err := readIdentityState(c, false)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	assignor := state.Assignor
+	if assignor == nil {
+		assignor = state.Key
+	}
+	action, err := admin.ClearNonce(assignor, &store.AdminAction{
+		Operator: c.String("operator"),
+		Reason:   c.String("reason"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(action.Action, hex.EncodeToString(assignor), action.Deleted, action.CreatedAt)
+	return nil
+}
+
+func listAdminActions(c *cli.Context) error {
+	conf, err := config.ReadConfiguration(c.String("config"))
+	if err != nil {
+		return err
+	}
+	admin, close, err := openAdmin(context.Background(), conf.Store, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	actions, err := admin.ListAdminActions()
+	if err != nil {
+		return err
+	}
+	for _, a := range actions {
+		fmt.Println(a.CreatedAt, a.Action, hex.EncodeToString(a.Key), a.Deleted, a.Operator, a.Reason)
+	}
+	return nil
+}
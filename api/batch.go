@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// signBatchSize is the default max requests in a batch.
+const signBatchSize = 16
+
+type SignBatchRequest struct {
+	Action   string         `json:"action"`
+	Requests []*SignRequest `json:"requests"`
+}
+
+// SignBatchResult has either the signed data or the error of the request
+// at the same index, and the data is signed the same as a single sign.
+type SignBatchResult struct {
+	Data      *SignData    `json:"data,omitempty"`
+	Error     *ErrorDetail `json:"error,omitempty"`
+	Signature string       `json:"signature,omitempty"`
+}
+
+type SignBatchResponse struct {
+	Results []*SignBatchResult `json:"results"`
+}
+
+func (hdr *Handler) signBatchSize() int {
+	if hdr.conf.SignBatchSize > 0 {
+		return hdr.conf.SignBatchSize
+	}
+	return signBatchSize
+}
+
+func (hdr *Handler) handleLegacySignBatch(w http.ResponseWriter, r *http.Request, b []byte) {
+	var body SignBatchRequest
+	err := json.Unmarshal(b, &body)
+	if err != nil {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
+	hdr.handleSignBatch(w, r, &body)
+}
+
+func (hdr *Handler) handleV1SignBatch(w http.ResponseWriter, r *http.Request) {
+	var body SignBatchRequest
+	if !hdr.decode(w, r, &body, int64(hdr.signBatchSize())*4096) {
+		return
+	}
+	if body.Action != "" && body.Action != "SIGN_BATCH" {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
+	hdr.handleSignBatch(w, r, &body)
+}
+
+// handleSignBatch runs each request through the challenge and guard one by
+// one, so a request is throttled the same as it's sent alone, and each one
+// takes a token of the client bucket, while the batch takes one sign slot.
+func (hdr *Handler) handleSignBatch(w http.ResponseWriter, r *http.Request, body *SignBatchRequest) {
+	if len(body.Requests) == 0 || len(body.Requests) > hdr.signBatchSize() {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
+	if !hdr.limit(w, r, true) {
+		return
+	}
+	defer hdr.limiter.release()
+
+	results := make([]*SignBatchResult, len(body.Requests))
+	for i, req := range body.Requests {
+		results[i] = hdr.signBatchItem(r, i, req)
+	}
+	hdr.json(w, r, http.StatusOK, &SignBatchResponse{Results: results})
+}
+
+func (hdr *Handler) signBatchItem(r *http.Request, i int, req *SignRequest) *SignBatchResult {
+	if req == nil || (req.Action != "" && req.Action != "SIGN") {
+		return signBatchError(http.StatusBadRequest)
+	}
+	// the first request has been charged by the batch limit
+	if i > 0 {
+		ok, _ := hdr.limiter.allow(r)
+		if !ok {
+			return signBatchError(http.StatusTooManyRequests)
+		}
+	}
+	data, sig, err := hdr.signRequest(req)
+	if err != nil {
+		return signBatchError(signStatus(err))
+	}
+	return &SignBatchResult{Data: data, Signature: sig}
+}
+
+func signBatchError(code int) *SignBatchResult {
+	return &SignBatchResult{Error: &ErrorDetail{
+		Code:        code,
+		Description: http.StatusText(code),
+	}}
+}
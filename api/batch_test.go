@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/keeper"
+	"github.com/stretchr/testify/require"
+	"github.com/unrolled/render"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestSignBatch(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteBn256()
+	serverKey := suite.Scalar().Pick(random.New())
+	serverPub := crypto.PublicKey(serverKey)
+	conf := &Configuration{
+		Key:           serverKey,
+		Share:         &share.PriShare{I: 0, V: suite.Scalar().Pick(random.New())},
+		SignBatchSize: 4,
+	}
+	hdr := &Handler{store: openAPIBadger(t), conf: conf, render: render.New()}
+	grace := uint64(keeper.EphemeralGracePeriod)
+	makeRequest := func(nonce uint64, watcher byte) *SignRequest {
+		user := suite.Scalar().Pick(random.New())
+		ephmr := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
+		return makeAPISignRequest(user, serverPub, ephmr, nil, nonce, grace, "", hex.EncodeToString(bytes.Repeat([]byte{watcher}, 32)))
+	}
+
+	first, second := makeRequest(31, 0x31), makeRequest(32, 0x32)
+	invalid := *second
+	invalid.Action = "WATCH"
+	b, err := json.Marshal(&SignBatchRequest{
+		Action:   "SIGN_BATCH",
+		Requests: []*SignRequest{first, second, &invalid, second},
+	})
+	require.NoError(err)
+	rec := serveTest(hdr, http.MethodPost, "/", string(b), nil)
+	require.Equal(http.StatusOK, rec.Code)
+	var res SignBatchResponse
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Len(res.Results, 4)
+	for _, r := range res.Results[:2] {
+		require.Nil(r.Error)
+		payload, _ := json.Marshal(r.Data)
+		sig, _ := hex.DecodeString(r.Signature)
+		require.NoError(crypto.Verify(serverPub, payload, sig))
+	}
+	require.Equal(http.StatusBadRequest, res.Results[2].Error.Code)
+	// the replayed request is rejected by the guard as it's sent alone
+	require.Nil(res.Results[3].Data)
+	b, err = json.Marshal(second)
+	require.NoError(err)
+	rec = serveTest(hdr, http.MethodPost, "/", string(b), nil)
+	require.Equal(rec.Code, res.Results[3].Error.Code)
+
+	// the v1 route takes the same body without the action
+	b, err = json.Marshal(&SignBatchRequest{Requests: []*SignRequest{makeRequest(33, 0x33)}})
+	require.NoError(err)
+	rec = serveTest(hdr, http.MethodPost, "/v1/sign_batch", string(b), map[string]string{"Content-Type": "application/json"})
+	require.Equal(http.StatusOK, rec.Code)
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Len(res.Results, 1)
+	require.Nil(res.Results[0].Error)
+
+	b, err = json.Marshal(&SignBatchRequest{
+		Action:   "SIGN_BATCH",
+		Requests: []*SignRequest{first, first, first, first, first},
+	})
+	require.NoError(err)
+	rec = serveTest(hdr, http.MethodPost, "/", string(b), nil)
+	require.Equal(http.StatusBadRequest, rec.Code)
+	rec = serveTest(hdr, http.MethodPost, "/", `{"action":"SIGN_BATCH","requests":[]}`, nil)
+	require.Equal(http.StatusBadRequest, rec.Code)
+
+	// a single request is still limited to 4096 bytes
+	rec = serveTest(hdr, http.MethodPost, "/", `{"action":"SIGN","data":"`+strings.Repeat("X", 4096)+`"}`, nil)
+	require.Equal(http.StatusBadRequest, rec.Code)
+}
+
+func TestSignBatchClientLimit(t *testing.T) {
+	require := require.New(t)
+
+	conf := &Configuration{Key: testScalar(), ClientRate: 1, ClientBurst: 2}
+	hdr := &Handler{store: &stubStore{}, conf: conf, render: render.New(), limiter: newLimiter(conf)}
+	rec := serveTest(hdr, http.MethodPost, "/", `{"action":"SIGN_BATCH","requests":[{},{},{}]}`, nil)
+	require.Equal(http.StatusOK, rec.Code)
+	var res SignBatchResponse
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Len(res.Results, 3)
+	require.Equal(http.StatusServiceUnavailable, res.Results[0].Error.Code)
+	require.Equal(http.StatusServiceUnavailable, res.Results[1].Error.Code)
+	require.Equal(http.StatusTooManyRequests, res.Results[2].Error.Code)
+}
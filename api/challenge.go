@@ -0,0 +1,169 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha3"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"go.dedis.ch/kyber/v4"
+)
+
+// challengeExpiry is the default seconds a challenge could be solved and
+// used in the sign requests.
+const challengeExpiry = 120
+
+// ChallengeData is signed like the other responses, so the client knows
+// it's from the signer, and the seed has a MAC of the other fields, so the
+// signer verifies it without pairing.
+type ChallengeData struct {
+	Difficulty int    `json:"difficulty"`
+	Expiry     int64  `json:"expiry"`
+	Seed       string `json:"seed"`
+}
+
+type ChallengeResponse struct {
+	Data      *ChallengeData `json:"data"`
+	Signature string         `json:"signature"`
+}
+
+// challenges has the MAC key derived from the signer key once, so the
+// signer key itself is never used as the MAC key, and the seeds used are
+// kept until expiry, so each solved challenge is accepted only once.
+type challenges struct {
+	key []byte
+
+	mutex sync.Mutex
+	used  map[[16]byte]int64
+	swept int64
+}
+
+func newChallenges(key kyber.Scalar) *challenges {
+	if key == nil {
+		return nil
+	}
+	return &challenges{
+		key:  crypto.DeriveKey(key, crypto.KeyLabelChallenge),
+		used: make(map[[16]byte]int64),
+	}
+}
+
+func (c *challenges) challenge(key kyber.Scalar, difficulty int, expiry time.Time, version int) (*ChallengeData, string) {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		panic(err)
+	}
+	data := &ChallengeData{
+		Difficulty: difficulty,
+		Expiry:     expiry.Unix(),
+	}
+	seed := append(random, c.mac(random, data)...)
+	data.Seed = hex.EncodeToString(seed)
+	b, _ := json.Marshal(data)
+	sig, _ := crypto.SignDomain(key, crypto.DomainChallenge, version, b)
+	return data, hex.EncodeToString(sig)
+}
+
+// check runs before the guard, and costs only a MAC and a hash,
+// the solution is bound to the encrypted data, so each sign request has
+// its own work.
+func (c *challenges) check(difficulty int, body *SignRequest, now time.Time) error {
+	if difficulty < 1 {
+		return nil
+	}
+	ch := body.Challenge
+	if ch == nil || ch.Difficulty < difficulty || ch.Expiry < now.Unix() {
+		return ErrChallengeRequired
+	}
+	seed, err := hex.DecodeString(ch.Seed)
+	if err != nil || len(seed) != 32 {
+		return ErrChallengeRequired
+	}
+	if !hmac.Equal(seed[16:], c.mac(seed[:16], ch)) {
+		return ErrChallengeRequired
+	}
+	solution, err := strconv.ParseUint(body.Solution, 16, 64)
+	if err != nil {
+		return ErrChallengeRequired
+	}
+	if !crypto.VerifyWork(seed, []byte(body.Data), ch.Difficulty, solution) {
+		return ErrChallengeRequired
+	}
+	if !c.use([16]byte(seed[:16]), ch.Expiry, now.Unix()) {
+		return ErrChallengeRequired
+	}
+	return nil
+}
+
+// use records the seed until its expiry, and returns false if it has been
+// used, the expired ones are removed at most once a minute.
+func (c *challenges) use(seed [16]byte, expiry, now int64) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now-c.swept >= int64(limiterSweepInterval/time.Second) {
+		c.swept = now
+		for s, e := range c.used {
+			if e < now {
+				delete(c.used, s)
+			}
+		}
+	}
+	if _, found := c.used[seed]; found {
+		return false
+	}
+	c.used[seed] = expiry
+	return true
+}
+
+func (c *challenges) mac(random []byte, data *ChallengeData) []byte {
+	mac := hmac.New(func() hash.Hash { return sha3.New256() }, c.key)
+	mac.Write(random)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(data.Difficulty)))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(data.Expiry)))
+	return mac.Sum(nil)[:16]
+}
+
+func (hdr *Handler) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	version, ok := queryVersion(r)
+	if !ok {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
+	hdr.writeChallenge(w, r, version)
+}
+
+func (hdr *Handler) writeChallenge(w http.ResponseWriter, r *http.Request, version int) {
+	if crypto.CheckSignatureVersion(version) != nil {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
+	data, sig := hdr.challenge(version)
+	if data == nil {
+		hdr.error(w, r, http.StatusNotFound)
+		return
+	}
+	hdr.json(w, r, http.StatusOK, &ChallengeResponse{Data: data, Signature: sig})
+}
+
+// challenge returns nil when the challenge is disabled.
+func (hdr *Handler) challenge(version int) (*ChallengeData, string) {
+	if hdr.conf.ChallengeDifficulty < 1 {
+		return nil, ""
+	}
+	expiry := hdr.conf.ChallengeExpiry
+	if expiry < 1 {
+		expiry = challengeExpiry
+	}
+	at := time.Now().Add(time.Duration(expiry) * time.Second)
+	return hdr.challenges.challenge(hdr.conf.Key, hdr.conf.ChallengeDifficulty, at, version)
+}
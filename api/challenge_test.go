@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckChallenge(t *testing.T) {
+	require := require.New(t)
+
+	key := testScalar()
+	c := newChallenges(key)
+	now := time.Unix(1700000000, 0)
+	data, sig := c.challenge(key, 8, now.Add(time.Minute), crypto.SignatureLegacy)
+	b, _ := json.Marshal(data)
+	s, _ := hex.DecodeString(sig)
+	require.NoError(crypto.Verify(crypto.PublicKey(key), b, s))
+
+	seed, _ := hex.DecodeString(data.Seed)
+	body := &SignRequest{Data: "cipher", Challenge: data}
+	body.Solution = strconv.FormatUint(crypto.SolveWork(seed, []byte(body.Data), 8), 16)
+	require.NoError(c.check(8, body, now))
+	require.NoError(c.check(0, &SignRequest{}, now))
+
+	// a solved challenge is accepted only once, until it expires
+	require.ErrorIs(c.check(8, body, now), ErrChallengeRequired)
+	require.ErrorIs(c.check(6, body, now), ErrChallengeRequired)
+	require.NoError(newChallenges(key).check(6, body, now))
+	require.Len(c.used, 1)
+	// the expired seeds are swept after a minute
+	require.True(c.use([16]byte{1}, now.Unix()+120, now.Unix()+61))
+	require.Len(c.used, 1)
+
+	require.ErrorIs(c.check(8, &SignRequest{Data: "cipher"}, now), ErrChallengeRequired)
+	require.ErrorIs(c.check(8, body, now.Add(2*time.Minute)), ErrChallengeRequired)
+	require.ErrorIs(c.check(10, body, now), ErrChallengeRequired)
+	require.ErrorIs(newChallenges(testScalar()).check(8, body, now), ErrChallengeRequired)
+
+	// the solution is bound to the encrypted data
+	other := *body
+	other.Data = "other"
+	if !crypto.VerifyWork(seed, []byte(other.Data), 8, 0) {
+		other.Solution = "0"
+		require.ErrorIs(c.check(8, &other, now), ErrChallengeRequired)
+	}
+
+	// the fields are authenticated by the seed
+	forged := *data
+	forged.Expiry = now.Add(time.Hour).Unix()
+	other = *body
+	other.Challenge = &forged
+	require.ErrorIs(c.check(8, &other, now.Add(2*time.Minute)), ErrChallengeRequired)
+	forged = *data
+	forged.Seed = data.Seed[:32]
+	require.ErrorIs(c.check(8, &other, now), ErrChallengeRequired)
+}
+
+func TestChallengeRoutes(t *testing.T) {
+	require := require.New(t)
+
+	hdr := testHandler(testScalar(), &stubStore{})
+	jsonHeader := map[string]string{"Content-Type": "application/json"}
+	rec := serveTest(hdr, http.MethodGet, "/v1/challenge", "", nil)
+	require.Equal(http.StatusNotFound, rec.Code)
+
+	hdr.conf.ChallengeDifficulty = 4
+	rec = serveTest(hdr, http.MethodGet, "/v1/challenge", "", nil)
+	require.Equal(http.StatusOK, rec.Code)
+	var res ChallengeResponse
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Equal(4, res.Data.Difficulty)
+	require.InDelta(time.Now().Add(challengeExpiry*time.Second).Unix(), res.Data.Expiry, 2)
+
+	rec = serveTest(hdr, http.MethodPost, "/", `{"action":"CHALLENGE"}`, nil)
+	require.Equal(http.StatusOK, rec.Code)
+	require.Contains(rec.Body.String(), `"difficulty":4`)
+
+	rec = serveTest(hdr, http.MethodPost, "/v1/sign", `{"data":"cipher"}`, jsonHeader)
+	require.Equal(http.StatusPreconditionRequired, rec.Code)
+	rec = serveTest(hdr, http.MethodPost, "/", `{"action":"SIGN","data":"cipher"}`, nil)
+	require.Equal(http.StatusPreconditionRequired, rec.Code)
+}
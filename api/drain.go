@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"sync"
+)
+
+// drainGroup tracks the sign requests in flight, which hold the key share
+// and the storage transactions, and it rejects the new ones once draining,
+// so the WaitGroup is never added after the wait.
+type drainGroup struct {
+	sync.Mutex
+	wg       sync.WaitGroup
+	draining bool
+}
+
+func (dg *drainGroup) enter() bool {
+	dg.Lock()
+	defer dg.Unlock()
+	if dg.draining {
+		return false
+	}
+	dg.wg.Add(1)
+	return true
+}
+
+func (dg *drainGroup) leave() {
+	dg.wg.Done()
+}
+
+// Drain waits for the sign requests in flight until the context done, and
+// the node key and storage must not be released before it returns nil.
+func (hdr *Handler) Drain(ctx context.Context) error {
+	hdr.drain.Lock()
+	hdr.drain.draining = true
+	hdr.drain.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		hdr.drain.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerDrain(t *testing.T) {
+	require := require.New(t)
+
+	hdr := &Handler{conf: &Configuration{}}
+	require.True(hdr.drain.enter())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(hdr.Drain(ctx), context.DeadlineExceeded)
+
+	// no new sign request once draining
+	_, _, err := hdr.signRequest(&SignRequest{})
+	require.Equal(ErrNotReady, err)
+
+	hdr.drain.leave()
+	require.NoError(hdr.Drain(context.Background()))
+}
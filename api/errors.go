@@ -6,4 +6,8 @@ var (
 	ErrUnknown         = fmt.Errorf("server error")
 	ErrInvalidAssignor = fmt.Errorf("invalid assignor")
 	ErrTooManyRequest  = fmt.Errorf("too many request")
+	ErrNotReady        = fmt.Errorf("not ready")
+
+	ErrChallengeRequired = fmt.Errorf("challenge required")
+	ErrInvalidFreshness  = fmt.Errorf("invalid freshness")
 )
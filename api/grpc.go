@@ -0,0 +1,195 @@
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pb/tip.proto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"strconv"
+
+	"github.com/MixinNetwork/tip/api/pb"
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcSigner converts the typed messages to the JSON API requests, so both
+// servers run the same challenge, guard and sign code.
+type grpcSigner struct {
+	pb.UnimplementedSignerServer
+	hdr *Handler
+}
+
+// GRPCServer has the same TLS certificates of the HTTP server, and the
+// client limits apply to the peer address, because there is no proxy
+// header for gRPC.
+func (hdr *Handler) GRPCServer(certs *Certificates) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(4096),
+	}
+	if certs != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(certs.config("h2"))))
+	}
+	server := grpc.NewServer(opts...)
+	pb.RegisterSignerServer(server, &grpcSigner{hdr: hdr})
+	return server
+}
+
+// ListenGRPC returns nil when the gRPC port is not configured.
+func ListenGRPC(conf *Configuration) (net.Listener, error) {
+	if conf.GRPCPort < 1 {
+		return nil, nil
+	}
+	return net.Listen("tcp", ":"+strconv.Itoa(conf.GRPCPort))
+}
+
+func (gs *grpcSigner) Info(_ context.Context, req *pb.InfoRequest) (*pb.InfoResponse, error) {
+	if len(req.Freshness) > 32 {
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidFreshness.Error())
+	}
+	err := crypto.CheckSignatureVersion(int(req.Version))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	conf := gs.hdr.conf
+	data, sig := info(conf.Key, conf.Signers, conf.Poly, hex.EncodeToString(req.Freshness), int(req.Version))
+	res := &pb.InfoResponse{
+		Identity:    data.Identity,
+		Commitments: data.Commitments,
+		Version:     Version,
+		Freshness:   req.Freshness,
+		Timestamp:   data.Timestamp,
+		Suite:       data.Suite,
+	}
+	for _, c := range data.Ciphers {
+		res.Ciphers = append(res.Ciphers, int32(c))
+	}
+	for _, s := range data.Signers {
+		res.Signers = append(res.Signers, &pb.SignerInfo{Identity: s.Identity, Index: s.Index})
+	}
+	res.Signature, _ = hex.DecodeString(sig)
+	return res, nil
+}
+
+func (gs *grpcSigner) Challenge(_ context.Context, req *pb.ChallengeRequest) (*pb.ChallengeResponse, error) {
+	err := crypto.CheckSignatureVersion(int(req.Version))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	data, sig := gs.hdr.challenge(int(req.Version))
+	if data == nil {
+		return nil, status.Error(codes.NotFound, "challenge disabled")
+	}
+	seed, _ := hex.DecodeString(data.Seed)
+	res := &pb.ChallengeResponse{Challenge: &pb.Challenge{
+		Difficulty: int64(data.Difficulty),
+		Expiry:     data.Expiry,
+		Seed:       seed,
+	}}
+	res.Signature, _ = hex.DecodeString(sig)
+	return res, nil
+}
+
+// Sign is the same as the JSON sign request, and the challenge solution
+// is made on the base64 data of the JSON request.
+func (gs *grpcSigner) Sign(ctx context.Context, req *pb.SignRequest) (*pb.SignResponse, error) {
+	err := gs.limit(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer gs.hdr.limiter.release()
+
+	body := &SignRequest{
+		Action:    "SIGN",
+		Identity:  req.Identity,
+		Data:      base64.RawURLEncoding.EncodeToString(req.Data),
+		Signature: hex.EncodeToString(req.Signature),
+		Watcher:   hex.EncodeToString(req.Watcher),
+		Freshness: hex.EncodeToString(req.Freshness),
+		Cipher:    int(req.Cipher),
+	}
+	if len(req.Session) > 0 {
+		session, err := crypto.PubKeyFromBytes(req.Session)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid session")
+		}
+		body.Session = crypto.PublicKeyString(session)
+	}
+	if ch := req.Challenge; ch != nil {
+		body.Challenge = &ChallengeData{
+			Difficulty: int(ch.Difficulty),
+			Expiry:     ch.Expiry,
+			Seed:       hex.EncodeToString(ch.Seed),
+		}
+		body.Solution = strconv.FormatUint(req.Solution, 16)
+	}
+	data, sig, err := gs.hdr.signRequest(body)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	res := &pb.SignResponse{Freshness: req.Freshness, Timestamp: data.Timestamp}
+	res.Cipher, _ = hex.DecodeString(data.Cipher)
+	res.Request, _ = hex.DecodeString(data.Request)
+	res.Signature, _ = hex.DecodeString(sig)
+	return res, nil
+}
+
+func (gs *grpcSigner) Watch(ctx context.Context, req *pb.WatchRequest) (*pb.WatchResponse, error) {
+	err := gs.limit(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Watcher) != 32 {
+		return nil, status.Error(codes.InvalidArgument, "invalid watcher")
+	}
+	genesis, counter, err := watch(gs.hdr.store, hex.EncodeToString(req.Watcher))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.WatchResponse{
+		Genesis: timestamppb.New(genesis),
+		Counter: int64(counter),
+	}, nil
+}
+
+func (gs *grpcSigner) limit(ctx context.Context, sign bool) error {
+	if p, ok := peer.FromContext(ctx); ok {
+		ap, err := netip.ParseAddrPort(p.Addr.String())
+		if err == nil {
+			ok, _ := gs.hdr.limiter.allowAddr(ap.Addr().Unmap())
+			if !ok {
+				metrics.RateLimited("client")
+				return status.Error(codes.ResourceExhausted, "too many requests")
+			}
+		}
+	}
+	if sign && !gs.hdr.limiter.acquire() {
+		metrics.RateLimited("concurrency")
+		return status.Error(codes.ResourceExhausted, "too many requests")
+	}
+	return nil
+}
+
+func grpcError(err error) error {
+	switch err {
+	case ErrChallengeRequired:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case ErrInvalidFreshness:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case ErrTooManyRequest:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case ErrInvalidAssignor:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case ErrNotReady:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/tip/api/pb"
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCServer(t *testing.T) {
+	require := require.New(t)
+
+	key := testScalar()
+	genesis := time.Unix(1701000000, 0)
+	hdr := testHandler(key, &stubStore{
+		watchFn: func([]byte) ([]byte, time.Time, int, error) {
+			return []byte("assignor"), genesis, 3, nil
+		},
+	})
+	hdr.limiter = newLimiter(hdr.conf)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	gs := hdr.GRPCServer(nil)
+	go func() { _ = gs.Serve(l) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err)
+	defer conn.Close()
+	client := pb.NewSignerClient(conn)
+	ctx := context.Background()
+
+	info, err := client.Info(ctx, &pb.InfoRequest{})
+	require.NoError(err)
+	require.Equal(Version, info.Version)
+	require.Len(info.Signers, 2)
+	data, _ := json.Marshal(&InfoData{
+		Commitments: info.Commitments,
+		Identity:    info.Identity,
+		Signers: []*SignerInfo{
+			{Identity: info.Signers[0].Identity, Index: info.Signers[0].Index},
+			{Identity: info.Signers[1].Identity, Index: info.Signers[1].Index},
+		},
+	})
+	require.NoError(crypto.Verify(crypto.PublicKey(key), data, info.Signature))
+
+	freshness := []byte("0123456789abcdef")
+	info, err = client.Info(ctx, &pb.InfoRequest{Freshness: freshness})
+	require.NoError(err)
+	require.Equal(freshness, info.Freshness)
+	require.InDelta(time.Now().Unix(), info.Timestamp, 5)
+	data, _ = json.Marshal(&InfoData{
+		Commitments: info.Commitments,
+		Freshness:   hex.EncodeToString(info.Freshness),
+		Identity:    info.Identity,
+		Signers: []*SignerInfo{
+			{Identity: info.Signers[0].Identity, Index: info.Signers[0].Index},
+			{Identity: info.Signers[1].Identity, Index: info.Signers[1].Index},
+		},
+		Timestamp: info.Timestamp,
+	})
+	require.NoError(crypto.Verify(crypto.PublicKey(key), data, info.Signature))
+	_, err = client.Info(ctx, &pb.InfoRequest{Freshness: make([]byte, 33)})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	info, err = client.Info(ctx, &pb.InfoRequest{Version: crypto.SignatureDomain})
+	require.NoError(err)
+	require.Equal([]int32{crypto.CipherLegacy, crypto.CipherBound, crypto.CipherSession}, info.Ciphers)
+	data, _ = json.Marshal(&InfoData{
+		Ciphers:     []int{crypto.CipherLegacy, crypto.CipherBound, crypto.CipherSession},
+		Commitments: info.Commitments,
+		Identity:    info.Identity,
+		Signers: []*SignerInfo{
+			{Identity: info.Signers[0].Identity, Index: info.Signers[0].Index},
+			{Identity: info.Signers[1].Identity, Index: info.Signers[1].Index},
+		},
+	})
+	require.NoError(crypto.VerifyDomain(crypto.PublicKey(key), crypto.DomainInfo, crypto.SignatureDomain, data, info.Signature))
+	_, err = client.Info(ctx, &pb.InfoRequest{Version: 2})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	watch, err := client.Watch(ctx, &pb.WatchRequest{Watcher: make([]byte, 32)})
+	require.NoError(err)
+	require.Equal(int64(3), watch.Counter)
+	require.True(genesis.Equal(watch.Genesis.AsTime()))
+	_, err = client.Watch(ctx, &pb.WatchRequest{Watcher: make([]byte, 16)})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Challenge(ctx, &pb.ChallengeRequest{})
+	require.Equal(codes.NotFound, status.Code(err))
+	hdr.conf.ChallengeDifficulty = 4
+	ch, err := client.Challenge(ctx, &pb.ChallengeRequest{})
+	require.NoError(err)
+	require.Equal(int64(4), ch.Challenge.Difficulty)
+	require.Len(ch.Challenge.Seed, 32)
+	_, err = client.Sign(ctx, &pb.SignRequest{Data: []byte("cipher")})
+	require.Equal(codes.FailedPrecondition, status.Code(err))
+
+	hdr.conf.ChallengeDifficulty = 0
+	hdr.conf.Share = nil
+	_, err = client.Sign(ctx, &pb.SignRequest{Data: []byte("cipher")})
+	require.Equal(codes.Unavailable, status.Code(err))
+
+	hdr.limiter = newLimiter(&Configuration{MaxConcurrentSign: 1})
+	require.True(hdr.limiter.acquire())
+	_, err = client.Sign(ctx, &pb.SignRequest{Data: []byte("cipher")})
+	require.Equal(codes.ResourceExhausted, status.Code(err))
+	hdr.limiter.release()
+}
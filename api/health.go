@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/logger"
+	"go.dedis.ch/kyber/v4/share"
+)
+
+const (
+	healthOK      = "ok"
+	healthMissing = "missing"
+	healthInvalid = "invalid"
+	healthFailed  = "failed"
+)
+
+type HealthResponse struct {
+	Checks map[string]string `json:"checks"`
+	Status string            `json:"status"`
+}
+
+// handleHealth only tells the process is serving, and it never touches the
+// store, so a slow disk doesn't get the process restarted.
+func (hdr *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	hdr.json(w, r, http.StatusOK, &HealthResponse{Checks: map[string]string{}, Status: healthOK})
+}
+
+// handleReady reports whether the signer could serve sign requests, the
+// load balancer should only route to the ready replicas.
+func (hdr *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"share": hdr.checkShare(),
+		"store": hdr.checkStore(),
+		"group": hdr.checkGroup(),
+	}
+	res := &HealthResponse{Checks: checks, Status: healthOK}
+	code := http.StatusOK
+	for _, c := range checks {
+		if c != healthOK {
+			res.Status = "not ready"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	hdr.json(w, r, code, res)
+}
+
+func (hdr *Handler) checkShare() string {
+	if hdr.conf.Share == nil || len(hdr.conf.Poly) == 0 {
+		return healthMissing
+	}
+	pub := share.NewPubPoly(crypto.PointSuite(hdr.conf.Poly[0]), nil, hdr.conf.Poly)
+	if !pub.Check(hdr.conf.Share) {
+		return healthInvalid
+	}
+	return healthOK
+}
+
+// storeProbe caches the result of the store ping, so the unauthenticated
+// readiness requests write at most once each storeProbeInterval.
+type storeProbe struct {
+	sync.Mutex
+	at  time.Time
+	err error
+}
+
+const storeProbeInterval = 10 * time.Second
+
+func (hdr *Handler) checkStore() string {
+	p := &hdr.probe
+	p.Lock()
+	if time.Since(p.at) >= storeProbeInterval {
+		p.err = hdr.store.Ping()
+		p.at = time.Now()
+		if p.err != nil {
+			logger.Error("store.Ping", p.err)
+		}
+	}
+	err := p.err
+	p.Unlock()
+
+	if err != nil {
+		return healthFailed
+	}
+	return healthOK
+}
+
+func (hdr *Handler) checkGroup() string {
+	if len(hdr.conf.Group) == 0 {
+		return healthMissing
+	}
+	// the probe never writes, the group is only stored by the node setup
+	group, err := hdr.store.ReadPolyGroup()
+	if err != nil {
+		logger.Error("store.ReadPolyGroup", err)
+		return healthFailed
+	}
+	if group == nil {
+		return healthMissing
+	}
+	if !bytes.Equal(group, hdr.conf.Group) {
+		return healthInvalid
+	}
+	return healthOK
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/store"
+	"github.com/stretchr/testify/require"
+	"github.com/unrolled/render"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestHealthAndReadiness(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteG2()
+	pri := share.NewPriPoly(suite, 2, nil, random.New())
+	_, commits := pri.Commit(suite.Point().Base()).Info()
+	group := crypto.PublicKeyBytes(crypto.PublicKey(testScalar()))
+	bs := openAPIBadger(t)
+	pings := &pingStorage{Storage: bs}
+	hdr := &Handler{
+		store: pings,
+		conf: &Configuration{
+			Key:   testScalar(),
+			Poly:  commits,
+			Share: pri.Shares(3)[1],
+			Group: group,
+		},
+		render: render.New(),
+	}
+	ready := func() (int, *HealthResponse) {
+		rec := serveTest(hdr, http.MethodGet, "/readyz", "", nil)
+		var body HealthResponse
+		require.NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+		return rec.Code, &body
+	}
+
+	rec := serveTest(hdr, http.MethodGet, "/healthz", "", nil)
+	require.Equal(http.StatusOK, rec.Code)
+	require.Contains(rec.Body.String(), `"status":"ok"`)
+
+	// the readiness never writes the group to the store
+	code, body := ready()
+	require.Equal(http.StatusServiceUnavailable, code)
+	require.Equal("missing", body.Checks["group"])
+	stored, err := bs.ReadPolyGroup()
+	require.NoError(err)
+	require.Nil(stored)
+	valid, err := bs.CheckPolyGroup(group)
+	require.NoError(err)
+	require.True(valid)
+
+	code, body = ready()
+	require.Equal(http.StatusOK, code)
+	require.Equal("ok", body.Status)
+	require.Equal(map[string]string{"share": "ok", "store": "ok", "group": "ok"}, body.Checks)
+	// the store ping writes, so it's cached for the interval
+	require.Equal(1, pings.count)
+
+	hdr.conf.Share = &share.PriShare{I: 1, V: suite.Scalar().Pick(random.New())}
+	code, body = ready()
+	require.Equal(http.StatusServiceUnavailable, code)
+	require.Equal("not ready", body.Status)
+	require.Equal("invalid", body.Checks["share"])
+
+	hdr.conf.Share = nil
+	code, body = ready()
+	require.Equal(http.StatusServiceUnavailable, code)
+	require.Equal("missing", body.Checks["share"])
+	require.Equal("ok", body.Checks["group"])
+
+	hdr.conf.Share = pri.Shares(3)[1]
+	hdr.conf.Group = crypto.PublicKeyBytes(crypto.PublicKey(testScalar()))
+	code, body = ready()
+	require.Equal(http.StatusServiceUnavailable, code)
+	require.Equal("invalid", body.Checks["group"])
+	require.Equal("ok", body.Checks["share"])
+
+	hdr.conf.Share = nil
+	rec = serveTest(hdr, http.MethodPost, "/", `{"action":"SIGN"}`, nil)
+	require.Equal(http.StatusServiceUnavailable, rec.Code)
+	rec = serveTest(hdr, http.MethodPost, "/v1/sign", `{"action":"SIGN"}`, map[string]string{"Content-Type": "application/json"})
+	require.Equal(http.StatusServiceUnavailable, rec.Code)
+	require.Equal(1, pings.count)
+
+	hdr.probe.at = time.Now().Add(-storeProbeInterval)
+	ready()
+	require.Equal(2, pings.count)
+}
+
+type pingStorage struct {
+	store.Storage
+	count int
+}
+
+func (ps *pingStorage) Ping() error {
+	ps.count++
+	return ps.Storage.Ping()
+}
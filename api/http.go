@@ -3,10 +3,15 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
 	"time"
 
+	"github.com/MixinNetwork/tip/crypto"
 	"github.com/MixinNetwork/tip/logger"
+	"github.com/MixinNetwork/tip/metrics"
 	"github.com/MixinNetwork/tip/store"
 	"github.com/unrolled/render"
 	"go.dedis.ch/kyber/v4"
@@ -15,9 +20,14 @@ import (
 )
 
 type Handler struct {
-	store  store.Storage
-	conf   *Configuration
-	render *render.Render
+	store   store.Storage
+	conf    *Configuration
+	render  *render.Render
+	limiter *limiter
+
+	challenges *challenges
+	probe      storeProbe
+	drain      drainGroup
 }
 
 type Configuration struct {
@@ -25,15 +35,67 @@ type Configuration struct {
 	Signers []dkg.Node      `toml:"-"`
 	Poly    []kyber.Point   `toml:"-"`
 	Share   *share.PriShare `toml:"-"`
+	Group   []byte          `toml:"-"`
 	Port    int             `toml:"port"`
+
+	// the unix socket for a proxy on the same host, the port is not
+	// listened when it's set
+	Socket string `toml:"socket"`
+
+	// the PEM files reloaded on SIGHUP, and the client certificate
+	// is required when the client_ca is set
+	TLSCert  string `toml:"tls_cert"`
+	TLSKey   string `toml:"tls_key"`
+	ClientCA string `toml:"client_ca"`
+
+	// the token bucket of each client IP for the sign and watch requests,
+	// with the rate per second and the burst, zero rate disables it
+	ClientRate  float64 `toml:"client_rate"`
+	ClientBurst int     `toml:"client_burst"`
+
+	// the client IP is read from the proxy header, e.g. X-Forwarded-For,
+	// only when the peer is one of the trusted proxy IPs or CIDRs, or the
+	// unix socket
+	ProxyHeader    string   `toml:"proxy_header"`
+	TrustedProxies []string `toml:"trusted_proxies"`
+
+	// the sign requests processed at the same time, and more requests are
+	// rejected, zero disables it
+	MaxConcurrentSign int `toml:"max_concurrent_sign"`
+
+	// the leading zero bits of the proof-of-work required by each sign
+	// request, and the seconds a challenge is valid, zero difficulty
+	// disables it
+	ChallengeDifficulty int `toml:"challenge_difficulty"`
+	ChallengeExpiry     int `toml:"challenge_expiry"`
+
+	// the port of the gRPC server, which has the same TLS and limits as
+	// the HTTP server, zero disables it
+	GRPCPort int `toml:"grpc_port"`
+
+	// the max sign requests in a batch, zero is the default 16
+	SignBatchSize int `toml:"sign_batch_size"`
 }
 
 func NewServer(store store.Storage, conf *Configuration) *http.Server {
-	hdr := &Handler{
-		store:  store,
-		render: render.New(),
-		conf:   conf,
+	return NewHandler(store, conf).HTTPServer()
+}
+
+// NewHandler is shared by the HTTP and gRPC servers, so they have the same
+// limits.
+func NewHandler(store store.Storage, conf *Configuration) *Handler {
+	return &Handler{
+		store:   store,
+		render:  render.New(),
+		conf:    conf,
+		limiter: newLimiter(conf),
+
+		challenges: newChallenges(conf.Key),
 	}
+}
+
+func (hdr *Handler) HTTPServer() *http.Server {
+	conf := hdr.conf
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", conf.Port),
 		Handler:      handleCORS(hdr),
@@ -44,63 +106,226 @@ func NewServer(store store.Storage, conf *Configuration) *http.Server {
 	return server
 }
 
+const Version = "v0.4.2"
+
+// requestRecorder captures the status code and the action resolved by the
+// handlers, for the request metrics.
+type requestRecorder struct {
+	http.ResponseWriter
+	action string
+	status int
+}
+
+func (rr *requestRecorder) WriteHeader(code int) {
+	rr.status = code
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func setAction(w http.ResponseWriter, action string) {
+	if rr, ok := w.(*requestRecorder); ok {
+		rr.action = action
+	}
+}
+
 func (hdr *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger.Info(*r)
-	defer handlePanic(w, r)
+	rr := &requestRecorder{ResponseWriter: w, action: "unknown", status: http.StatusOK}
+	defer func() {
+		metrics.APIRequest(rr.action, rr.status)
+	}()
+	defer handlePanic(rr, r)
+	hdr.serve(rr, r)
+}
 
-	if r.URL.Path != "/" {
-		hdr.error(w, r, http.StatusNotFound)
+func (hdr *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		hdr.handleLegacy(w, r)
 		return
 	}
+	for _, rt := range routes {
+		if rt.Path != r.URL.Path {
+			continue
+		}
+		setAction(w, path.Base(rt.Path))
+		if rt.Method != r.Method {
+			w.Header().Set("Allow", rt.Method)
+			hdr.error(w, r, http.StatusMethodNotAllowed)
+			return
+		}
+		if !acceptJSON(r) {
+			hdr.error(w, r, http.StatusNotAcceptable)
+			return
+		}
+		if rt.Request != nil && !contentJSON(r) {
+			hdr.error(w, r, http.StatusUnsupportedMediaType)
+			return
+		}
+		rt.handle(hdr, w, r)
+		return
+	}
+	hdr.error(w, r, http.StatusNotFound)
+}
 
+// handleLegacy serves the unversioned endpoint, GET for info, and POST
+// dispatches on the action, which is kept for the released clients.
+func (hdr *Handler) handleLegacy(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		hdr.handle(w, r)
 		return
 	}
-
-	data, sig := info(hdr.conf.Key, hdr.conf.Signers, hdr.conf.Poly)
-	hdr.json(w, r, http.StatusOK, map[string]any{"data": data, "signature": sig, "version": "v0.4.2"})
+	setAction(w, "info")
+	hdr.handleInfo(w, r)
 }
 
 func (hdr *Handler) handle(w http.ResponseWriter, r *http.Request) {
-	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(hdr.signBatchSize())*4096))
+	if err != nil {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
 	var body SignRequest
-	err := json.NewDecoder(r.Body).Decode(&body)
+	err = json.Unmarshal(b, &body)
 	if err != nil {
 		hdr.error(w, r, http.StatusBadRequest)
 		return
 	}
+	// only the batch could be larger than a single request
+	if body.Action == "SIGN_BATCH" {
+		setAction(w, "sign_batch")
+		hdr.handleLegacySignBatch(w, r, b)
+		return
+	}
+	if len(b) > 4096 {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
 	switch body.Action {
 	case "SIGN":
-		data, sig, err := sign(hdr.conf.Key, hdr.store, &body, hdr.conf.Share)
-		logger.Debug("api.sign", body.Identity, data, sig, err)
-		if err == ErrTooManyRequest {
-			hdr.error(w, r, http.StatusTooManyRequests)
-			return
-		} else if err == ErrInvalidAssignor {
-			hdr.error(w, r, http.StatusForbidden)
-			return
-		} else if err != nil {
-			hdr.error(w, r, http.StatusInternalServerError)
-			return
-		}
-		hdr.json(w, r, http.StatusOK, map[string]any{"data": data, "signature": sig})
+		setAction(w, "sign")
+		hdr.handleSign(w, r, &body)
 	case "WATCH":
-		genesis, counter, err := watch(hdr.store, body.Watcher)
-		if err != nil {
-			hdr.error(w, r, http.StatusInternalServerError)
-			return
-		}
-		hdr.json(w, r, http.StatusOK, map[string]any{"genesis": genesis, "counter": counter})
+		setAction(w, "watch")
+		hdr.handleWatch(w, r, &WatchRequest{Watcher: body.Watcher})
+	case "CHALLENGE":
+		setAction(w, "challenge")
+		hdr.writeChallenge(w, r, body.Version)
 	default:
 		hdr.error(w, r, http.StatusBadRequest)
 	}
 }
 
+// handleInfo echoes the freshness query in the signed data, with the
+// server time, so the client could reject a replayed info.
+func (hdr *Handler) handleInfo(w http.ResponseWriter, r *http.Request) {
+	freshness := r.URL.Query().Get("freshness")
+	version, ok := queryVersion(r)
+	if !ok || !validFreshness(freshness) {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
+	data, sig := info(hdr.conf.Key, hdr.conf.Signers, hdr.conf.Poly, freshness, version)
+	hdr.json(w, r, http.StatusOK, &InfoResponse{Data: data, Signature: sig, Version: Version})
+}
+
+// queryVersion reads the signature version of the GET responses, and the
+// legacy version without the query.
+func queryVersion(r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("version")
+	if v == "" {
+		return crypto.SignatureLegacy, true
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || crypto.CheckSignatureVersion(version) != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+func (hdr *Handler) handleSign(w http.ResponseWriter, r *http.Request, body *SignRequest) {
+	if !hdr.limit(w, r, true) {
+		return
+	}
+	defer hdr.limiter.release()
+
+	data, sig, err := hdr.signRequest(body)
+	if err != nil {
+		hdr.error(w, r, signStatus(err))
+		return
+	}
+	hdr.json(w, r, http.StatusOK, &SignResponse{Data: data, Signature: sig})
+}
+
+func signStatus(err error) int {
+	switch err {
+	case nil:
+		return http.StatusOK
+	case ErrChallengeRequired:
+		return http.StatusPreconditionRequired
+	case ErrInvalidFreshness:
+		return http.StatusBadRequest
+	case ErrTooManyRequest:
+		return http.StatusTooManyRequests
+	case ErrInvalidAssignor:
+		return http.StatusForbidden
+	case ErrNotReady:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// signRequest checks the challenge before the guard, and it's shared by the
+// HTTP and gRPC servers, which are drained before the node released.
+func (hdr *Handler) signRequest(body *SignRequest) (*SignData, string, error) {
+	if !hdr.drain.enter() {
+		return nil, "", ErrNotReady
+	}
+	defer hdr.drain.leave()
+
+	if !validFreshness(body.Freshness) {
+		return nil, "", ErrInvalidFreshness
+	}
+	err := hdr.challenges.check(hdr.conf.ChallengeDifficulty, body, time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+
+	start := time.Now()
+	data, sig, err := sign(hdr.conf.Key, hdr.store, body, hdr.conf.Share)
+	logger.Debug("api.sign", body.Identity, data, sig, err)
+	metrics.SignDuration(signResult(err), time.Since(start))
+	return data, sig, err
+}
+
+func signResult(err error) string {
+	switch err {
+	case nil:
+		return "ok"
+	case ErrTooManyRequest:
+		return "throttled"
+	case ErrInvalidAssignor:
+		return "forbidden"
+	case ErrNotReady:
+		return "not_ready"
+	}
+	return "error"
+}
+
+func (hdr *Handler) handleWatch(w http.ResponseWriter, r *http.Request, body *WatchRequest) {
+	if !hdr.limit(w, r, false) {
+		return
+	}
+	genesis, counter, err := watch(hdr.store, body.Watcher)
+	if err != nil {
+		hdr.error(w, r, http.StatusInternalServerError)
+		return
+	}
+	hdr.json(w, r, http.StatusOK, &WatchResponse{Genesis: genesis, Counter: counter})
+}
+
 func (hdr *Handler) error(w http.ResponseWriter, r *http.Request, code int) {
-	hdr.json(w, r, code, map[string]any{"error": map[string]any{
-		"code":        code,
-		"description": http.StatusText(code),
+	hdr.json(w, r, code, &ErrorResponse{Error: &ErrorDetail{
+		Code:        code,
+		Description: http.StatusText(code),
 	}})
 }
 
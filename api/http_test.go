@@ -27,6 +27,10 @@ func (s *stubStore) CheckPolyGroup([]byte) (bool, error) {
 	return false, nil
 }
 
+func (s *stubStore) ReadPolyGroup() ([]byte, error) {
+	return nil, nil
+}
+
 func (s *stubStore) ReadPolyPublic() ([]byte, error) {
 	return nil, nil
 }
@@ -67,6 +71,14 @@ func (s *stubStore) WriteSignRequest([]byte, []byte) (time.Time, int, error) {
 	return time.Time{}, 0, nil
 }
 
+func (s *stubStore) WriteSignAudit([]byte, []byte, uint64, int) error {
+	return nil
+}
+
+func (s *stubStore) Ping() error {
+	return nil
+}
+
 func (s *stubStore) Watch(key []byte) ([]byte, time.Time, int, error) {
 	if s.watchFn != nil {
 		return s.watchFn(key)
@@ -96,7 +108,8 @@ func testHandler(key kyber.Scalar, store *stubStore) *Handler {
 			Share:   &share.PriShare{I: 1},
 			Port:    7000,
 		},
-		render: render.New(),
+		render:     render.New(),
+		challenges: newChallenges(key),
 	}
 }
 
@@ -113,12 +126,11 @@ func TestInfoSignsPayload(t *testing.T) {
 		crypto.PublicKey(testScalar()),
 	}
 
-	data, sigHex := info(key, signers, poly)
-	body, ok := data.(map[string]any)
-	require.True(ok)
-	require.Equal(crypto.PublicKeyString(crypto.PublicKey(key)), body["identity"])
-	require.Len(body["signers"], len(signers))
-	require.Len(body["commitments"], len(poly))
+	data, sigHex := info(key, signers, poly, "", crypto.SignatureLegacy)
+	require.Equal(crypto.PublicKeyString(crypto.PublicKey(key)), data.Identity)
+	require.Empty(data.Suite)
+	require.Len(data.Signers, len(signers))
+	require.Len(data.Commitments, len(poly))
 
 	rawSig, err := hex.DecodeString(sigHex)
 	require.NoError(err)
@@ -128,6 +140,25 @@ func TestInfoSignsPayload(t *testing.T) {
 	require.NoError(crypto.Verify(crypto.PublicKey(key), payload, rawSig))
 }
 
+func TestInfoSuite(t *testing.T) {
+	require := require.New(t)
+
+	suite, err := crypto.NewSuite(crypto.SuiteBLS12381)
+	require.NoError(err)
+	key := suite.Scalar().Pick(random.New())
+	signers := []dkg.Node{{Index: 0, Public: crypto.PublicKey(key)}}
+	poly := []kyber.Point{crypto.PublicKey(suite.Scalar().Pick(random.New()))}
+
+	data, sigHex := info(key, signers, poly, "", crypto.SignatureDomain)
+	require.Equal(crypto.SuiteBLS12381, data.Suite)
+	payload, err := json.Marshal(data)
+	require.NoError(err)
+	require.Contains(string(payload), `"suite":"bls12381"`)
+	rawSig, err := hex.DecodeString(sigHex)
+	require.NoError(err)
+	require.NoError(crypto.VerifyDomain(crypto.PublicKey(key), crypto.DomainInfo, crypto.SignatureDomain, payload, rawSig))
+}
+
 func TestWatchRejectsInvalidWatcher(t *testing.T) {
 	require := require.New(t)
 
@@ -183,6 +214,85 @@ func TestServeHTTPGetRoot(t *testing.T) {
 	require.NoError(crypto.Verify(crypto.PublicKey(key), payload, rawSig))
 }
 
+func TestServeHTTPInfoFreshness(t *testing.T) {
+	require := require.New(t)
+
+	key := testScalar()
+	hdr := testHandler(key, &stubStore{})
+
+	rec := httptest.NewRecorder()
+	hdr.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(http.StatusOK, rec.Code)
+	require.NotContains(rec.Body.String(), "freshness")
+	require.NotContains(rec.Body.String(), "timestamp")
+
+	freshness := "000102030405060708090a0b0c0d0e0f"
+	for _, path := range []string{"/?freshness=" + freshness, "/v1/info?freshness=" + freshness} {
+		rec = httptest.NewRecorder()
+		hdr.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(http.StatusOK, rec.Code)
+
+		var body struct {
+			Data      map[string]any `json:"data"`
+			Signature string         `json:"signature"`
+		}
+		require.NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Equal(freshness, body.Data["freshness"])
+		require.InDelta(float64(time.Now().Unix()), body.Data["timestamp"], 5)
+
+		rawSig, err := hex.DecodeString(body.Signature)
+		require.NoError(err)
+		payload, err := json.Marshal(body.Data)
+		require.NoError(err)
+		require.NoError(crypto.Verify(crypto.PublicKey(key), payload, rawSig))
+	}
+
+	for _, freshness := range []string{"xyz", hex.EncodeToString(make([]byte, 33))} {
+		rec = httptest.NewRecorder()
+		hdr.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?freshness="+freshness, nil))
+		require.Equal(http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestServeHTTPSignatureVersion(t *testing.T) {
+	require := require.New(t)
+
+	key := testScalar()
+	hdr := testHandler(key, &stubStore{})
+	hdr.conf.ChallengeDifficulty = 4
+
+	requests := map[string]*http.Request{
+		crypto.DomainInfo:      httptest.NewRequest(http.MethodGet, "/v1/info?version=1", nil),
+		crypto.DomainChallenge: httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"action":"CHALLENGE","version":1}`))),
+	}
+	for domain, req := range requests {
+		rec := httptest.NewRecorder()
+		hdr.ServeHTTP(rec, req)
+		require.Equal(http.StatusOK, rec.Code)
+
+		var body struct {
+			Data      map[string]any `json:"data"`
+			Signature string         `json:"signature"`
+		}
+		require.NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+		rawSig, err := hex.DecodeString(body.Signature)
+		require.NoError(err)
+		payload, err := json.Marshal(body.Data)
+		require.NoError(err)
+		require.NoError(crypto.VerifyDomain(crypto.PublicKey(key), domain, crypto.SignatureDomain, payload, rawSig))
+		require.Error(crypto.Verify(crypto.PublicKey(key), payload, rawSig))
+		if domain == crypto.DomainInfo {
+			require.Equal([]any{float64(crypto.CipherLegacy), float64(crypto.CipherBound), float64(crypto.CipherSession)}, body.Data["ciphers"])
+		}
+	}
+
+	for _, path := range []string{"/?version=2", "/v1/info?version=x", "/v1/challenge?version=-1"} {
+		rec := httptest.NewRecorder()
+		hdr.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(http.StatusBadRequest, rec.Code, path)
+	}
+}
+
 func TestServeHTTPHandlesErrorsAndWatchRequests(t *testing.T) {
 	require := require.New(t)
 
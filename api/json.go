@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/sha3"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
@@ -12,26 +13,116 @@ import (
 	"github.com/MixinNetwork/tip/logger"
 	"github.com/MixinNetwork/tip/store"
 	"go.dedis.ch/kyber/v4"
-	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/share"
 	"go.dedis.ch/kyber/v4/share/dkg/pedersen"
 	"go.dedis.ch/kyber/v4/sign/tbls"
 )
 
 type SignRequest struct {
-	Action    string `json:"action"`
-	Watcher   string `json:"watcher"`
-	Identity  string `json:"identity"`
-	Signature string `json:"signature"`
-	Data      string `json:"data"`
+	Action    string         `json:"action"`
+	Watcher   string         `json:"watcher"`
+	Identity  string         `json:"identity"`
+	Signature string         `json:"signature"`
+	Data      string         `json:"data"`
+	Challenge *ChallengeData `json:"challenge,omitempty"`
+	Solution  string         `json:"solution,omitempty"`
+	Freshness string         `json:"freshness,omitempty"`
+
+	// the signature version of the challenge response, and the sign
+	// response has the version of the encrypted request
+	Version int `json:"version,omitempty"`
+
+	// the cipher version of the data, and the response cipher has the
+	// same version, which is one of the info ciphers
+	Cipher int `json:"cipher,omitempty"`
+
+	// the one-time public key of the session cipher, which encrypts the
+	// data and receives the response
+	Session string `json:"session,omitempty"`
+}
+
+type WatchRequest struct {
+	Watcher string `json:"watcher"`
+}
+
+// The signed data types keep the fields in alphabetical order, so they are
+// encoded to the same bytes as the sorted maps signed by older versions.
+// The freshness fields are only set when the client sends a freshness, so
+// the older clients still verify the same bytes.
+type InfoData struct {
+	Ciphers     []int         `json:"ciphers,omitempty"`
+	Commitments []string      `json:"commitments"`
+	Freshness   string        `json:"freshness,omitempty"`
+	Identity    string        `json:"identity"`
+	Signers     []*SignerInfo `json:"signers"`
+	Suite       string        `json:"suite,omitempty"`
+	Timestamp   int64         `json:"timestamp,omitempty"`
+}
+
+type SignerInfo struct {
+	Identity string `json:"identity"`
+	Index    uint32 `json:"index"`
+}
+
+type SignData struct {
+	Cipher    string `json:"cipher"`
+	Freshness string `json:"freshness,omitempty"`
+	Request   string `json:"request,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+type InfoResponse struct {
+	Data      *InfoData `json:"data"`
+	Signature string    `json:"signature"`
+	Version   string    `json:"version"`
+}
+
+type SignResponse struct {
+	Data      *SignData `json:"data"`
+	Signature string    `json:"signature"`
+}
+
+type WatchResponse struct {
+	Counter int       `json:"counter"`
+	Genesis time.Time `json:"genesis"`
+}
+
+type ErrorResponse struct {
+	Error *ErrorDetail `json:"error"`
 }
 
-func info(key kyber.Scalar, sigrs []dkg.Node, poly []kyber.Point) (any, string) {
-	signers := make([]map[string]any, len(sigrs))
+type ErrorDetail struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+// validFreshness accepts the hex of at most 32 random bytes, which are
+// echoed back in the signed data.
+func validFreshness(freshness string) bool {
+	if len(freshness) > 64 {
+		return false
+	}
+	_, err := hex.DecodeString(freshness)
+	return err == nil
+}
+
+// RequestHash is signed in the response, so the client could tell it's
+// the response of its own request, instead of another one replayed.
+func RequestHash(body *SignRequest) string {
+	h := sha3.New256()
+	for _, s := range []string{body.Identity, body.Data, body.Signature, body.Watcher, body.Freshness, body.Session} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func info(key kyber.Scalar, sigrs []dkg.Node, poly []kyber.Point, freshness string, version int) (*InfoData, string) {
+	signers := make([]*SignerInfo, len(sigrs))
 	for i, s := range sigrs {
-		signers[i] = map[string]any{
-			"index":    s.Index,
-			"identity": crypto.PublicKeyString(s.Public),
+		signers[i] = &SignerInfo{
+			Index:    s.Index,
+			Identity: crypto.PublicKeyString(s.Public),
 		}
 	}
 	commitments := make([]string, len(poly))
@@ -39,13 +130,25 @@ func info(key kyber.Scalar, sigrs []dkg.Node, poly []kyber.Point) (any, string)
 		commitments[i] = crypto.PublicKeyString(c)
 	}
 	id := crypto.PublicKey(key)
-	data := map[string]any{
-		"identity":    crypto.PublicKeyString(id),
-		"signers":     signers,
-		"commitments": commitments,
+	data := &InfoData{
+		Identity:    crypto.PublicKeyString(id),
+		Signers:     signers,
+		Commitments: commitments,
+	}
+	if freshness != "" {
+		data.Freshness = freshness
+		data.Timestamp = time.Now().Unix()
+	}
+	// the bn256 info is unchanged for the clients without the suites
+	if suite := crypto.SuiteName(crypto.ScalarSuite(key)); suite != crypto.SuiteBN256 {
+		data.Suite = suite
+	}
+	// the legacy clients verify the info without the ciphers
+	if version != crypto.SignatureLegacy {
+		data.Ciphers = []int{crypto.CipherLegacy, crypto.CipherBound, crypto.CipherSession}
 	}
 	b, _ := json.Marshal(data)
-	sig, _ := crypto.Sign(key, b)
+	sig, _ := crypto.SignDomain(key, crypto.DomainInfo, version, b)
 	return data, hex.EncodeToString(sig)
 }
 
@@ -59,8 +162,13 @@ func watch(store store.Storage, watcher string) (time.Time, int, error) {
 	return genesis, counter, err
 }
 
-func sign(key kyber.Scalar, store store.Storage, body *SignRequest, priv *share.PriShare) (any, string, error) {
-	res, err := keeper.Guard(store, key, body.Identity, body.Signature, body.Data)
+func sign(key kyber.Scalar, store store.Storage, body *SignRequest, priv *share.PriShare) (*SignData, string, error) {
+	// the api may start before the DKG finishes, and a nil share must not
+	// consume any nonce or quota of the identity
+	if priv == nil {
+		return nil, "", ErrNotReady
+	}
+	res, err := keeper.Guard(store, key, body.Identity, body.Signature, body.Data, body.Cipher, body.Session)
 	if err != nil {
 		logger.Debug("keeper.Guard", body.Identity, body.Watcher, body.Signature, err)
 		return nil, "", ErrUnknown
@@ -74,7 +182,7 @@ func sign(key kyber.Scalar, store store.Storage, body *SignRequest, priv *share.
 		return nil, "", ErrInvalidAssignor
 	}
 
-	scheme := tbls.NewThresholdSchemeOnG1(bn256.NewSuiteG2())
+	scheme := tbls.NewThresholdSchemeOnG1(crypto.ScalarSuite(priv.V))
 	partial, err := scheme.Sign(priv, res.Assignor)
 	if err != nil {
 		panic(err)
@@ -85,6 +193,12 @@ func sign(key kyber.Scalar, store store.Storage, body *SignRequest, priv *share.
 		logger.Debug("store.WriteSignRequest", err)
 		return nil, "", ErrUnknown
 	}
+	// the partial is only returned after it's recorded in the audit chain
+	err = store.WriteSignAudit(res.Assignor, res.Watcher, res.Nonce, counter)
+	if err != nil {
+		logger.Debug("store.WriteSignAudit", err)
+		return nil, "", ErrUnknown
+	}
 
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, res.Nonce)
@@ -94,11 +208,20 @@ func sign(key kyber.Scalar, store store.Storage, body *SignRequest, priv *share.
 	plain = append(plain, buf...)
 	binary.BigEndian.PutUint64(buf, uint64(counter))
 	plain = append(plain, buf...)
-	cipher := crypto.EncryptECDH(res.Identity, key, plain)
-	data := map[string]any{
-		"cipher": hex.EncodeToString(cipher),
+	cipher, err := crypto.EncryptECDHWithAD(res.Receiver, key, crypto.DirectionResponse, body.Cipher, plain)
+	if err != nil {
+		logger.Debug("crypto.EncryptECDHWithAD", err)
+		return nil, "", ErrUnknown
+	}
+	data := &SignData{
+		Cipher: hex.EncodeToString(cipher),
+	}
+	if body.Freshness != "" {
+		data.Freshness = body.Freshness
+		data.Request = RequestHash(body)
+		data.Timestamp = time.Now().Unix()
 	}
 	b, _ := json.Marshal(data)
-	sig, _ := crypto.Sign(key, b)
+	sig, _ := crypto.SignDomain(key, crypto.DomainResponse, res.Version, b)
 	return data, hex.EncodeToString(sig), nil
 }
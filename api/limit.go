@@ -0,0 +1,205 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/tip/metrics"
+)
+
+// limiterSweepInterval is how often the idle buckets are removed, a bucket
+// refilled to the burst is the same as a new one.
+const limiterSweepInterval = time.Minute
+
+// limiter sheds the load before the keeper guard, which is per identity
+// and costs a decryption and several transactions for each request.
+type limiter struct {
+	rate    float64
+	burst   float64
+	header  string
+	proxies []netip.Prefix
+	sign    chan struct{}
+
+	mutex   sync.Mutex
+	buckets map[netip.Addr]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(conf *Configuration) *limiter {
+	lim := &limiter{
+		rate:    conf.ClientRate,
+		burst:   float64(max(conf.ClientBurst, 1)),
+		header:  conf.ProxyHeader,
+		buckets: make(map[netip.Addr]*bucket),
+		now:     time.Now,
+	}
+	for _, p := range conf.TrustedProxies {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				panic(p)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		lim.proxies = append(lim.proxies, prefix.Masked())
+	}
+	if conf.MaxConcurrentSign > 0 {
+		lim.sign = make(chan struct{}, conf.MaxConcurrentSign)
+	}
+	return lim
+}
+
+// allow takes a token from the bucket of the client, or returns the time
+// until the next token.
+func (lim *limiter) allow(r *http.Request) (bool, time.Duration) {
+	if lim == nil || lim.rate <= 0 {
+		return true, 0
+	}
+	addr, ok := lim.clientAddr(r)
+	if !ok {
+		return true, 0
+	}
+	return lim.allowAddr(addr)
+}
+
+func (lim *limiter) allowAddr(addr netip.Addr) (bool, time.Duration) {
+	if lim == nil || lim.rate <= 0 {
+		return true, 0
+	}
+	// an IPv6 client usually owns the whole /64
+	if addr.Is6() {
+		addr = netip.PrefixFrom(addr, 64).Masked().Addr()
+	}
+
+	lim.mutex.Lock()
+	defer lim.mutex.Unlock()
+
+	now := lim.now()
+	lim.sweep(now)
+	b := lim.buckets[addr]
+	if b == nil {
+		b = &bucket{tokens: lim.burst, last: now}
+		lim.buckets[addr] = b
+	}
+	b.tokens = min(lim.burst, b.tokens+now.Sub(b.last).Seconds()*lim.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens -= 1
+		return true, 0
+	}
+	wait := (1 - b.tokens) / lim.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+func (lim *limiter) sweep(now time.Time) {
+	if now.Sub(lim.swept) < limiterSweepInterval {
+		return
+	}
+	lim.swept = now
+	for addr, b := range lim.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*lim.rate >= lim.burst {
+			delete(lim.buckets, addr)
+		}
+	}
+}
+
+// clientAddr only reads the proxy header when the peer is a trusted proxy
+// or the unix socket, and the rightmost untrusted address is the client,
+// because the left ones are set by the client itself.
+func (lim *limiter) clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	trusted := err != nil || lim.trusted(peer.Unmap())
+	if lim.header == "" || !trusted {
+		return peer.Unmap(), err == nil
+	}
+
+	values := r.Header.Values(lim.header)
+	var hops []string
+	for _, v := range values {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = addr.Unmap()
+		if !lim.trusted(addr) {
+			return addr, true
+		}
+		peer = addr
+	}
+	return peer.Unmap(), peer.IsValid()
+}
+
+func (lim *limiter) trusted(addr netip.Addr) bool {
+	for _, p := range lim.proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire returns false when all the sign slots are taken, and the request
+// is rejected immediately instead of queued.
+func (lim *limiter) acquire() bool {
+	if lim == nil || lim.sign == nil {
+		return true
+	}
+	select {
+	case lim.sign <- struct{}{}:
+		metrics.SignConcurrency(len(lim.sign))
+		return true
+	default:
+		return false
+	}
+}
+
+func (lim *limiter) release() {
+	if lim == nil || lim.sign == nil {
+		return
+	}
+	<-lim.sign
+	metrics.SignConcurrency(len(lim.sign))
+}
+
+// limit writes the 429 response when the client or the server is out of
+// capacity, and the caller must release the sign slot after the request.
+func (hdr *Handler) limit(w http.ResponseWriter, r *http.Request, sign bool) bool {
+	ok, wait := hdr.limiter.allow(r)
+	if !ok {
+		metrics.RateLimited("client")
+		hdr.tooManyRequests(w, r, wait)
+		return false
+	}
+	if sign && !hdr.limiter.acquire() {
+		metrics.RateLimited("concurrency")
+		hdr.tooManyRequests(w, r, time.Second)
+		return false
+	}
+	return true
+}
+
+func (hdr *Handler) tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	seconds := max(int(math.Ceil(wait.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	hdr.error(w, r, http.StatusTooManyRequests)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/unrolled/render"
+)
+
+func limitRequest(remote string, header ...string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/v1/watch", nil)
+	r.RemoteAddr = remote
+	for _, h := range header {
+		r.Header.Add("X-Forwarded-For", h)
+	}
+	return r
+}
+
+func TestLimiterTokenBucket(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Unix(1700000000, 0)
+	lim := newLimiter(&Configuration{ClientRate: 0.5, ClientBurst: 2})
+	lim.now = func() time.Time { return now }
+
+	a := limitRequest("203.0.113.1:4000")
+	b := limitRequest("203.0.113.2:4000")
+	for range 2 {
+		ok, _ := lim.allow(a)
+		require.True(ok)
+	}
+	ok, wait := lim.allow(a)
+	require.False(ok)
+	require.Equal(2*time.Second, wait)
+	ok, _ = lim.allow(b)
+	require.True(ok)
+
+	now = now.Add(time.Second)
+	ok, wait = lim.allow(a)
+	require.False(ok)
+	require.Equal(time.Second, wait)
+	now = now.Add(time.Second)
+	ok, _ = lim.allow(a)
+	require.True(ok)
+
+	// the same /64 shares the bucket
+	c := limitRequest("[2001:db8::1]:4000")
+	d := limitRequest("[2001:db8::2]:4000")
+	ok, _ = lim.allow(c)
+	require.True(ok)
+	ok, _ = lim.allow(d)
+	require.True(ok)
+	ok, _ = lim.allow(c)
+	require.False(ok)
+
+	now = now.Add(limiterSweepInterval)
+	ok, _ = lim.allow(b)
+	require.True(ok)
+	require.Len(lim.buckets, 1)
+
+	ok, _ = (*limiter)(nil).allow(a)
+	require.True(ok)
+	ok, _ = newLimiter(&Configuration{}).allow(a)
+	require.True(ok)
+}
+
+func TestLimiterClientAddr(t *testing.T) {
+	require := require.New(t)
+
+	lim := newLimiter(&Configuration{
+		ProxyHeader:    "X-Forwarded-For",
+		TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"},
+	})
+	cases := []struct {
+		req    *http.Request
+		client string
+	}{
+		{limitRequest("203.0.113.1:4000", "198.51.100.1"), "203.0.113.1"},
+		{limitRequest("192.0.2.1:4000", "198.51.100.1"), "198.51.100.1"},
+		{limitRequest("192.0.2.1:4000", "1.1.1.1, 198.51.100.1, 10.0.0.2"), "198.51.100.1"},
+		{limitRequest("192.0.2.1:4000", "1.1.1.1", "198.51.100.1"), "198.51.100.1"},
+		{limitRequest("192.0.2.1:4000", "invalid, 10.0.0.2"), "10.0.0.2"},
+		{limitRequest("192.0.2.1:4000"), "192.0.2.1"},
+		{limitRequest("@", "198.51.100.1"), "198.51.100.1"},
+	}
+	for _, c := range cases {
+		addr, ok := lim.clientAddr(c.req)
+		require.True(ok)
+		require.Equal(c.client, addr.String())
+	}
+	_, ok := lim.clientAddr(limitRequest("@"))
+	require.False(ok)
+
+	require.Panics(func() { newLimiter(&Configuration{TrustedProxies: []string{"proxy"}}) })
+}
+
+func TestLimitRejectsWithRetryAfter(t *testing.T) {
+	require := require.New(t)
+
+	conf := &Configuration{ClientRate: 1, ClientBurst: 1, MaxConcurrentSign: 1}
+	hdr := &Handler{
+		store:   &stubStore{},
+		conf:    conf,
+		render:  render.New(),
+		limiter: newLimiter(conf),
+	}
+	body := `{"watcher":""}`
+	jsonHeader := map[string]string{"Content-Type": "application/json"}
+
+	rec := serveTest(hdr, http.MethodPost, "/v1/watch", body, jsonHeader)
+	require.NotEqual(http.StatusTooManyRequests, rec.Code)
+	rec = serveTest(hdr, http.MethodPost, "/v1/watch", body, jsonHeader)
+	require.Equal(http.StatusTooManyRequests, rec.Code)
+	require.Equal("1", rec.Header().Get("Retry-After"))
+
+	// the sign slot is taken by another request
+	hdr.limiter = newLimiter(&Configuration{MaxConcurrentSign: 1})
+	require.True(hdr.limiter.acquire())
+	rec = serveTest(hdr, http.MethodPost, "/v1/sign", `{"action":"SIGN"}`, jsonHeader)
+	require.Equal(http.StatusTooManyRequests, rec.Code)
+	require.Equal("1", rec.Header().Get("Retry-After"))
+	hdr.limiter.release()
+	rec = serveTest(hdr, http.MethodPost, "/v1/sign", `{"action":"SIGN"}`, jsonHeader)
+	require.NotEqual(http.StatusTooManyRequests, rec.Code)
+	require.Len(hdr.limiter.sign, 0)
+}
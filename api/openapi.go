@@ -0,0 +1,101 @@
+package api
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPI is built after the routes, see the init in routes.go
+var openAPI map[string]any
+
+// buildOpenAPI generates the OpenAPI 3 document from the route types, all
+// the named structs are components referenced by name.
+func buildOpenAPI(routes []*route) map[string]any {
+	schemas := make(map[string]any)
+	errorRef := schemaOf(reflect.TypeFor[ErrorResponse](), schemas)
+	paths := make(map[string]any)
+	for _, rt := range routes {
+		responses := map[string]any{
+			"default": jsonContent("Error", errorRef),
+		}
+		if rt.Response != nil {
+			responses["200"] = jsonContent("OK", schemaOf(rt.Response, schemas))
+		} else {
+			responses["200"] = jsonContent("OK", map[string]any{"type": "object"})
+		}
+		op := map[string]any{
+			"summary":   rt.Summary,
+			"responses": responses,
+		}
+		if rt.Request != nil {
+			body := jsonContent("", schemaOf(rt.Request, schemas))
+			delete(body, "description")
+			body["required"] = true
+			op["requestBody"] = body
+		}
+		paths[rt.Path] = map[string]any{strings.ToLower(rt.Method): op}
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "TIP Signer API",
+			"version": Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}
+}
+
+func jsonContent(description string, schema any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int" + strconv.Itoa(t.Bits())}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case t.Kind() != reflect.Struct:
+		panic(t.String())
+	}
+
+	ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	if _, found := schemas[t.Name()]; found {
+		return ref
+	}
+	properties := make(map[string]any)
+	schemas[t.Name()] = map[string]any{"type": "object", "properties": properties}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = schemaOf(f.Type, schemas)
+	}
+	return ref
+}
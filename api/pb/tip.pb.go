@@ -0,0 +1,805 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: tip.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// the freshness is at most 32 random bytes, which are echoed back in the
+// signed data with the server timestamp
+type InfoRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Freshness []byte                 `protobuf:"bytes,1,opt,name=freshness,proto3" json:"freshness,omitempty"`
+	// the signature version of the response, 0 for the legacy raw message
+	Version       int32 `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoRequest) Reset() {
+	*x = InfoRequest{}
+	mi := &file_tip_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoRequest) ProtoMessage() {}
+
+func (x *InfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoRequest.ProtoReflect.Descriptor instead.
+func (*InfoRequest) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *InfoRequest) GetFreshness() []byte {
+	if x != nil {
+		return x.Freshness
+	}
+	return nil
+}
+
+func (x *InfoRequest) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type SignerInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Identity      string                 `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	Index         uint32                 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignerInfo) Reset() {
+	*x = SignerInfo{}
+	mi := &file_tip_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignerInfo) ProtoMessage() {}
+
+func (x *SignerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignerInfo.ProtoReflect.Descriptor instead.
+func (*SignerInfo) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SignerInfo) GetIdentity() string {
+	if x != nil {
+		return x.Identity
+	}
+	return ""
+}
+
+func (x *SignerInfo) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+type InfoResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Identity      string                 `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	Signers       []*SignerInfo          `protobuf:"bytes,2,rep,name=signers,proto3" json:"signers,omitempty"`
+	Commitments   []string               `protobuf:"bytes,3,rep,name=commitments,proto3" json:"commitments,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Version       string                 `protobuf:"bytes,5,opt,name=version,proto3" json:"version,omitempty"`
+	Freshness     []byte                 `protobuf:"bytes,6,opt,name=freshness,proto3" json:"freshness,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Ciphers       []int32                `protobuf:"varint,8,rep,packed,name=ciphers,proto3" json:"ciphers,omitempty"`
+	Suite         string                 `protobuf:"bytes,9,opt,name=suite,proto3" json:"suite,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoResponse) Reset() {
+	*x = InfoResponse{}
+	mi := &file_tip_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoResponse) ProtoMessage() {}
+
+func (x *InfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoResponse.ProtoReflect.Descriptor instead.
+func (*InfoResponse) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *InfoResponse) GetIdentity() string {
+	if x != nil {
+		return x.Identity
+	}
+	return ""
+}
+
+func (x *InfoResponse) GetSigners() []*SignerInfo {
+	if x != nil {
+		return x.Signers
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetCommitments() []string {
+	if x != nil {
+		return x.Commitments
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *InfoResponse) GetFreshness() []byte {
+	if x != nil {
+		return x.Freshness
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *InfoResponse) GetCiphers() []int32 {
+	if x != nil {
+		return x.Ciphers
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetSuite() string {
+	if x != nil {
+		return x.Suite
+	}
+	return ""
+}
+
+type ChallengeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeRequest) Reset() {
+	*x = ChallengeRequest{}
+	mi := &file_tip_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeRequest) ProtoMessage() {}
+
+func (x *ChallengeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeRequest.ProtoReflect.Descriptor instead.
+func (*ChallengeRequest) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ChallengeRequest) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type Challenge struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Difficulty    int64                  `protobuf:"varint,1,opt,name=difficulty,proto3" json:"difficulty,omitempty"`
+	Expiry        int64                  `protobuf:"varint,2,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	Seed          []byte                 `protobuf:"bytes,3,opt,name=seed,proto3" json:"seed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Challenge) Reset() {
+	*x = Challenge{}
+	mi := &file_tip_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Challenge) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Challenge) ProtoMessage() {}
+
+func (x *Challenge) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Challenge.ProtoReflect.Descriptor instead.
+func (*Challenge) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Challenge) GetDifficulty() int64 {
+	if x != nil {
+		return x.Difficulty
+	}
+	return 0
+}
+
+func (x *Challenge) GetExpiry() int64 {
+	if x != nil {
+		return x.Expiry
+	}
+	return 0
+}
+
+func (x *Challenge) GetSeed() []byte {
+	if x != nil {
+		return x.Seed
+	}
+	return nil
+}
+
+type ChallengeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Challenge     *Challenge             `protobuf:"bytes,1,opt,name=challenge,proto3" json:"challenge,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChallengeResponse) Reset() {
+	*x = ChallengeResponse{}
+	mi := &file_tip_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChallengeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChallengeResponse) ProtoMessage() {}
+
+func (x *ChallengeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChallengeResponse.ProtoReflect.Descriptor instead.
+func (*ChallengeResponse) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ChallengeResponse) GetChallenge() *Challenge {
+	if x != nil {
+		return x.Challenge
+	}
+	return nil
+}
+
+func (x *ChallengeResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type SignRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Identity  string                 `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	Data      []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Signature []byte                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	Watcher   []byte                 `protobuf:"bytes,4,opt,name=watcher,proto3" json:"watcher,omitempty"`
+	Challenge *Challenge             `protobuf:"bytes,5,opt,name=challenge,proto3" json:"challenge,omitempty"`
+	Solution  uint64                 `protobuf:"varint,6,opt,name=solution,proto3" json:"solution,omitempty"`
+	Freshness []byte                 `protobuf:"bytes,7,opt,name=freshness,proto3" json:"freshness,omitempty"`
+	Cipher    int32                  `protobuf:"varint,8,opt,name=cipher,proto3" json:"cipher,omitempty"`
+	// the one-time public key of the session cipher
+	Session       []byte `protobuf:"bytes,9,opt,name=session,proto3" json:"session,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignRequest) Reset() {
+	*x = SignRequest{}
+	mi := &file_tip_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignRequest) ProtoMessage() {}
+
+func (x *SignRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignRequest.ProtoReflect.Descriptor instead.
+func (*SignRequest) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SignRequest) GetIdentity() string {
+	if x != nil {
+		return x.Identity
+	}
+	return ""
+}
+
+func (x *SignRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *SignRequest) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *SignRequest) GetWatcher() []byte {
+	if x != nil {
+		return x.Watcher
+	}
+	return nil
+}
+
+func (x *SignRequest) GetChallenge() *Challenge {
+	if x != nil {
+		return x.Challenge
+	}
+	return nil
+}
+
+func (x *SignRequest) GetSolution() uint64 {
+	if x != nil {
+		return x.Solution
+	}
+	return 0
+}
+
+func (x *SignRequest) GetFreshness() []byte {
+	if x != nil {
+		return x.Freshness
+	}
+	return nil
+}
+
+func (x *SignRequest) GetCipher() int32 {
+	if x != nil {
+		return x.Cipher
+	}
+	return 0
+}
+
+func (x *SignRequest) GetSession() []byte {
+	if x != nil {
+		return x.Session
+	}
+	return nil
+}
+
+type SignResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cipher        []byte                 `protobuf:"bytes,1,opt,name=cipher,proto3" json:"cipher,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	Freshness     []byte                 `protobuf:"bytes,3,opt,name=freshness,proto3" json:"freshness,omitempty"`
+	Request       []byte                 `protobuf:"bytes,4,opt,name=request,proto3" json:"request,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignResponse) Reset() {
+	*x = SignResponse{}
+	mi := &file_tip_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignResponse) ProtoMessage() {}
+
+func (x *SignResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignResponse.ProtoReflect.Descriptor instead.
+func (*SignResponse) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SignResponse) GetCipher() []byte {
+	if x != nil {
+		return x.Cipher
+	}
+	return nil
+}
+
+func (x *SignResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *SignResponse) GetFreshness() []byte {
+	if x != nil {
+		return x.Freshness
+	}
+	return nil
+}
+
+func (x *SignResponse) GetRequest() []byte {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *SignResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Watcher       []byte                 `protobuf:"bytes,1,opt,name=watcher,proto3" json:"watcher,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_tip_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WatchRequest) GetWatcher() []byte {
+	if x != nil {
+		return x.Watcher
+	}
+	return nil
+}
+
+type WatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Genesis       *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=genesis,proto3" json:"genesis,omitempty"`
+	Counter       int64                  `protobuf:"varint,2,opt,name=counter,proto3" json:"counter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchResponse) Reset() {
+	*x = WatchResponse{}
+	mi := &file_tip_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchResponse) ProtoMessage() {}
+
+func (x *WatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tip_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchResponse.ProtoReflect.Descriptor instead.
+func (*WatchResponse) Descriptor() ([]byte, []int) {
+	return file_tip_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchResponse) GetGenesis() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Genesis
+	}
+	return nil
+}
+
+func (x *WatchResponse) GetCounter() int64 {
+	if x != nil {
+		return x.Counter
+	}
+	return 0
+}
+
+var File_tip_proto protoreflect.FileDescriptor
+
+const file_tip_proto_rawDesc = "" +
+	"\n" +
+	"\ttip.proto\x12\x06tip.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"E\n" +
+	"\vInfoRequest\x12\x1c\n" +
+	"\tfreshness\x18\x01 \x01(\fR\tfreshness\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x05R\aversion\">\n" +
+	"\n" +
+	"SignerInfo\x12\x1a\n" +
+	"\bidentity\x18\x01 \x01(\tR\bidentity\x12\x14\n" +
+	"\x05index\x18\x02 \x01(\rR\x05index\"\x9e\x02\n" +
+	"\fInfoResponse\x12\x1a\n" +
+	"\bidentity\x18\x01 \x01(\tR\bidentity\x12,\n" +
+	"\asigners\x18\x02 \x03(\v2\x12.tip.v1.SignerInfoR\asigners\x12 \n" +
+	"\vcommitments\x18\x03 \x03(\tR\vcommitments\x12\x1c\n" +
+	"\tsignature\x18\x04 \x01(\fR\tsignature\x12\x18\n" +
+	"\aversion\x18\x05 \x01(\tR\aversion\x12\x1c\n" +
+	"\tfreshness\x18\x06 \x01(\fR\tfreshness\x12\x1c\n" +
+	"\ttimestamp\x18\a \x01(\x03R\ttimestamp\x12\x18\n" +
+	"\aciphers\x18\b \x03(\x05R\aciphers\x12\x14\n" +
+	"\x05suite\x18\t \x01(\tR\x05suite\",\n" +
+	"\x10ChallengeRequest\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\"W\n" +
+	"\tChallenge\x12\x1e\n" +
+	"\n" +
+	"difficulty\x18\x01 \x01(\x03R\n" +
+	"difficulty\x12\x16\n" +
+	"\x06expiry\x18\x02 \x01(\x03R\x06expiry\x12\x12\n" +
+	"\x04seed\x18\x03 \x01(\fR\x04seed\"b\n" +
+	"\x11ChallengeResponse\x12/\n" +
+	"\tchallenge\x18\x01 \x01(\v2\x11.tip.v1.ChallengeR\tchallenge\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignature\"\x92\x02\n" +
+	"\vSignRequest\x12\x1a\n" +
+	"\bidentity\x18\x01 \x01(\tR\bidentity\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\x12\x18\n" +
+	"\awatcher\x18\x04 \x01(\fR\awatcher\x12/\n" +
+	"\tchallenge\x18\x05 \x01(\v2\x11.tip.v1.ChallengeR\tchallenge\x12\x1a\n" +
+	"\bsolution\x18\x06 \x01(\x04R\bsolution\x12\x1c\n" +
+	"\tfreshness\x18\a \x01(\fR\tfreshness\x12\x16\n" +
+	"\x06cipher\x18\b \x01(\x05R\x06cipher\x12\x18\n" +
+	"\asession\x18\t \x01(\fR\asession\"\x9a\x01\n" +
+	"\fSignResponse\x12\x16\n" +
+	"\x06cipher\x18\x01 \x01(\fR\x06cipher\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignature\x12\x1c\n" +
+	"\tfreshness\x18\x03 \x01(\fR\tfreshness\x12\x18\n" +
+	"\arequest\x18\x04 \x01(\fR\arequest\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\"(\n" +
+	"\fWatchRequest\x12\x18\n" +
+	"\awatcher\x18\x01 \x01(\fR\awatcher\"_\n" +
+	"\rWatchResponse\x124\n" +
+	"\agenesis\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\agenesis\x12\x18\n" +
+	"\acounter\x18\x02 \x01(\x03R\acounter2\xe6\x01\n" +
+	"\x06Signer\x121\n" +
+	"\x04Info\x12\x13.tip.v1.InfoRequest\x1a\x14.tip.v1.InfoResponse\x12@\n" +
+	"\tChallenge\x12\x18.tip.v1.ChallengeRequest\x1a\x19.tip.v1.ChallengeResponse\x121\n" +
+	"\x04Sign\x12\x13.tip.v1.SignRequest\x1a\x14.tip.v1.SignResponse\x124\n" +
+	"\x05Watch\x12\x14.tip.v1.WatchRequest\x1a\x15.tip.v1.WatchResponseB$Z\"github.com/MixinNetwork/tip/api/pbb\x06proto3"
+
+var (
+	file_tip_proto_rawDescOnce sync.Once
+	file_tip_proto_rawDescData []byte
+)
+
+func file_tip_proto_rawDescGZIP() []byte {
+	file_tip_proto_rawDescOnce.Do(func() {
+		file_tip_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_tip_proto_rawDesc), len(file_tip_proto_rawDesc)))
+	})
+	return file_tip_proto_rawDescData
+}
+
+var file_tip_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_tip_proto_goTypes = []any{
+	(*InfoRequest)(nil),           // 0: tip.v1.InfoRequest
+	(*SignerInfo)(nil),            // 1: tip.v1.SignerInfo
+	(*InfoResponse)(nil),          // 2: tip.v1.InfoResponse
+	(*ChallengeRequest)(nil),      // 3: tip.v1.ChallengeRequest
+	(*Challenge)(nil),             // 4: tip.v1.Challenge
+	(*ChallengeResponse)(nil),     // 5: tip.v1.ChallengeResponse
+	(*SignRequest)(nil),           // 6: tip.v1.SignRequest
+	(*SignResponse)(nil),          // 7: tip.v1.SignResponse
+	(*WatchRequest)(nil),          // 8: tip.v1.WatchRequest
+	(*WatchResponse)(nil),         // 9: tip.v1.WatchResponse
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_tip_proto_depIdxs = []int32{
+	1,  // 0: tip.v1.InfoResponse.signers:type_name -> tip.v1.SignerInfo
+	4,  // 1: tip.v1.ChallengeResponse.challenge:type_name -> tip.v1.Challenge
+	4,  // 2: tip.v1.SignRequest.challenge:type_name -> tip.v1.Challenge
+	10, // 3: tip.v1.WatchResponse.genesis:type_name -> google.protobuf.Timestamp
+	0,  // 4: tip.v1.Signer.Info:input_type -> tip.v1.InfoRequest
+	3,  // 5: tip.v1.Signer.Challenge:input_type -> tip.v1.ChallengeRequest
+	6,  // 6: tip.v1.Signer.Sign:input_type -> tip.v1.SignRequest
+	8,  // 7: tip.v1.Signer.Watch:input_type -> tip.v1.WatchRequest
+	2,  // 8: tip.v1.Signer.Info:output_type -> tip.v1.InfoResponse
+	5,  // 9: tip.v1.Signer.Challenge:output_type -> tip.v1.ChallengeResponse
+	7,  // 10: tip.v1.Signer.Sign:output_type -> tip.v1.SignResponse
+	9,  // 11: tip.v1.Signer.Watch:output_type -> tip.v1.WatchResponse
+	8,  // [8:12] is the sub-list for method output_type
+	4,  // [4:8] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_tip_proto_init() }
+func file_tip_proto_init() {
+	if File_tip_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tip_proto_rawDesc), len(file_tip_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_tip_proto_goTypes,
+		DependencyIndexes: file_tip_proto_depIdxs,
+		MessageInfos:      file_tip_proto_msgTypes,
+	}.Build()
+	File_tip_proto = out.File
+	file_tip_proto_goTypes = nil
+	file_tip_proto_depIdxs = nil
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// route is a versioned endpoint, and the request and response types are
+// also used to generate the OpenAPI document, so they must match what the
+// handler decodes and writes.
+type route struct {
+	Method   string
+	Path     string
+	Summary  string
+	Request  reflect.Type
+	Response reflect.Type
+	handle   func(hdr *Handler, w http.ResponseWriter, r *http.Request)
+}
+
+var routes []*route
+
+// the routes refer to the openapi handler, which refers to the routes, so
+// they are assigned in init to avoid the initialization cycle
+func init() {
+	routes = []*route{{
+		Method:   http.MethodGet,
+		Path:     "/v1/info",
+		Summary:  "The signer identity, group and commitments, signed by the signer",
+		Response: reflect.TypeFor[InfoResponse](),
+		handle:   (*Handler).handleInfo,
+	}, {
+		Method:   http.MethodGet,
+		Path:     "/v1/challenge",
+		Summary:  "The proof-of-work puzzle required by the sign requests, signed by the signer",
+		Response: reflect.TypeFor[ChallengeResponse](),
+		handle:   (*Handler).handleChallenge,
+	}, {
+		Method:   http.MethodPost,
+		Path:     "/v1/sign",
+		Summary:  "Request the partial signature of an identity",
+		Request:  reflect.TypeFor[SignRequest](),
+		Response: reflect.TypeFor[SignResponse](),
+		handle:   (*Handler).handleV1Sign,
+	}, {
+		Method:   http.MethodPost,
+		Path:     "/v1/sign_batch",
+		Summary:  "Request the partial signatures of several identities, each processed in isolation",
+		Request:  reflect.TypeFor[SignBatchRequest](),
+		Response: reflect.TypeFor[SignBatchResponse](),
+		handle:   (*Handler).handleV1SignBatch,
+	}, {
+		Method:   http.MethodPost,
+		Path:     "/v1/watch",
+		Summary:  "The genesis and counter of the identity bound to the watcher",
+		Request:  reflect.TypeFor[WatchRequest](),
+		Response: reflect.TypeFor[WatchResponse](),
+		handle:   (*Handler).handleV1Watch,
+	}, {
+		Method:   http.MethodGet,
+		Path:     "/healthz",
+		Summary:  "The process is alive",
+		Response: reflect.TypeFor[HealthResponse](),
+		handle:   (*Handler).handleHealth,
+	}, {
+		Method:   http.MethodGet,
+		Path:     "/readyz",
+		Summary:  "The share is verified, the store is writable and the group is accepted",
+		Response: reflect.TypeFor[HealthResponse](),
+		handle:   (*Handler).handleReady,
+	}, {
+		Method:  http.MethodGet,
+		Path:    "/v1/openapi.json",
+		Summary: "This OpenAPI document",
+		handle:  (*Handler).handleOpenAPI,
+	}}
+	openAPI = buildOpenAPI(routes)
+}
+
+func (hdr *Handler) handleV1Sign(w http.ResponseWriter, r *http.Request) {
+	var body SignRequest
+	if !hdr.decode(w, r, &body, 4096) {
+		return
+	}
+	// the action is implied by the route, and any other value is rejected
+	if body.Action != "" && body.Action != "SIGN" {
+		hdr.error(w, r, http.StatusBadRequest)
+		return
+	}
+	hdr.handleSign(w, r, &body)
+}
+
+func (hdr *Handler) handleV1Watch(w http.ResponseWriter, r *http.Request) {
+	var body WatchRequest
+	if !hdr.decode(w, r, &body, 4096) {
+		return
+	}
+	hdr.handleWatch(w, r, &body)
+}
+
+func (hdr *Handler) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	hdr.json(w, r, http.StatusOK, openAPI)
+}
+
+func (hdr *Handler) decode(w http.ResponseWriter, r *http.Request, body any, limit int64) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(body)
+	if err != nil {
+		hdr.error(w, r, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// acceptJSON is true when the client accepts JSON, or has no preference.
+func acceptJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+	for part := range strings.SplitSeq(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mt {
+		case "application/json", "application/*", "*/*":
+			return true
+		}
+	}
+	return false
+}
+
+func contentJSON(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mt == "application/json"
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/keeper"
+	"github.com/MixinNetwork/tip/metrics"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func serveTest(hdr *Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	hdr.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestInfoMatchesLegacyPayload(t *testing.T) {
+	require := require.New(t)
+
+	key := testScalar()
+	hdr := testHandler(key, &stubStore{})
+	data, _ := info(key, hdr.conf.Signers, hdr.conf.Poly, "", crypto.SignatureLegacy)
+	typed, err := json.Marshal(data)
+	require.NoError(err)
+
+	// the older versions signed the map, which the clients still verify
+	signers := make([]map[string]any, len(data.Signers))
+	for i, s := range data.Signers {
+		signers[i] = map[string]any{"index": s.Index, "identity": s.Identity}
+	}
+	legacy, err := json.Marshal(map[string]any{
+		"identity":    data.Identity,
+		"signers":     signers,
+		"commitments": data.Commitments,
+	})
+	require.NoError(err)
+	require.Equal(string(legacy), string(typed))
+}
+
+func TestV1Routes(t *testing.T) {
+	require := require.New(t)
+
+	key := testScalar()
+	watcher := bytes.Repeat([]byte{0x42}, 32)
+	genesis := time.Unix(1701000000, 0)
+	store := &stubStore{
+		watchFn: func([]byte) ([]byte, time.Time, int, error) {
+			return []byte("assignor"), genesis, 9, nil
+		},
+	}
+	hdr := testHandler(key, store)
+	jsonHeader := map[string]string{"Content-Type": "application/json"}
+
+	rec := serveTest(hdr, http.MethodGet, "/v1/info", "", map[string]string{"Accept": "text/html, application/json;q=0.9"})
+	require.Equal(http.StatusOK, rec.Code)
+	var infoBody InfoResponse
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &infoBody))
+	require.Equal(Version, infoBody.Version)
+	legacy := serveTest(hdr, http.MethodGet, "/", "", nil)
+	require.Equal(legacy.Body.String(), rec.Body.String())
+	payload, err := json.Marshal(infoBody.Data)
+	require.NoError(err)
+	sig, err := hex.DecodeString(infoBody.Signature)
+	require.NoError(err)
+	require.NoError(crypto.Verify(crypto.PublicKey(key), payload, sig))
+
+	body := `{"watcher":"` + hex.EncodeToString(watcher) + `"}`
+	rec = serveTest(hdr, http.MethodPost, "/v1/watch", body, jsonHeader)
+	require.Equal(http.StatusOK, rec.Code)
+	var watchBody WatchResponse
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &watchBody))
+	require.True(genesis.Equal(watchBody.Genesis))
+	require.Equal(9, watchBody.Counter)
+
+	rec = serveTest(hdr, http.MethodGet, "/v1/watch", "", nil)
+	require.Equal(http.StatusMethodNotAllowed, rec.Code)
+	require.Equal(http.MethodPost, rec.Header().Get("Allow"))
+	var errBody ErrorResponse
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &errBody))
+	require.Equal(http.StatusMethodNotAllowed, errBody.Error.Code)
+
+	rec = serveTest(hdr, http.MethodPost, "/v1/watch", body, nil)
+	require.Equal(http.StatusUnsupportedMediaType, rec.Code)
+	rec = serveTest(hdr, http.MethodGet, "/v1/info", "", map[string]string{"Accept": "text/html"})
+	require.Equal(http.StatusNotAcceptable, rec.Code)
+	rec = serveTest(hdr, http.MethodPost, "/v1/watch", `{"action":"WATCH"}`, jsonHeader)
+	require.Equal(http.StatusBadRequest, rec.Code)
+	rec = serveTest(hdr, http.MethodPost, "/v1/sign", `{"action":"WATCH"}`, jsonHeader)
+	require.Equal(http.StatusBadRequest, rec.Code)
+	rec = serveTest(hdr, http.MethodGet, "/v1/missing", "", nil)
+	require.Equal(http.StatusNotFound, rec.Code)
+}
+
+func TestV1Sign(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteBn256()
+	serverKey := suite.Scalar().Pick(random.New())
+	serverPub := crypto.PublicKey(serverKey)
+	user := suite.Scalar().Pick(random.New())
+	ephmr := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
+	watcher := hex.EncodeToString(bytes.Repeat([]byte{0x23}, 32))
+	req := makeAPISignRequest(user, serverPub, ephmr, nil, 31, uint64(keeper.EphemeralGracePeriod), "", watcher)
+	req.Action = ""
+	body, err := json.Marshal(req)
+	require.NoError(err)
+
+	hdr := testHandler(serverKey, nil)
+	hdr.store = openAPIBadger(t)
+	hdr.conf.Share = &share.PriShare{I: 0, V: suite.Scalar().Pick(random.New())}
+	rec := serveTest(hdr, http.MethodPost, "/v1/sign", string(body), map[string]string{"Content-Type": "application/json; charset=utf-8"})
+	require.Equal(http.StatusOK, rec.Code)
+
+	var res SignResponse
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	payload, err := json.Marshal(res.Data)
+	require.NoError(err)
+	sig, err := hex.DecodeString(res.Signature)
+	require.NoError(err)
+	require.NoError(crypto.Verify(serverPub, payload, sig))
+	cipher, err := hex.DecodeString(res.Data.Cipher)
+	require.NoError(err)
+	require.NotNil(crypto.DecryptECDH(serverPub, user, cipher))
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	require := require.New(t)
+
+	hdr := testHandler(testScalar(), &stubStore{})
+	rec := serveTest(hdr, http.MethodGet, "/v1/openapi.json", "", nil)
+	require.Equal(http.StatusOK, rec.Code)
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &doc))
+	require.Equal("3.0.3", doc.OpenAPI)
+	for _, rt := range routes {
+		require.Contains(doc.Paths[rt.Path], map[string]string{
+			http.MethodGet: "get", http.MethodPost: "post",
+		}[rt.Method])
+	}
+	require.Contains(doc.Paths["/v1/sign"]["post"], "requestBody")
+	require.Equal("#/components/schemas/SignData", doc.Components.Schemas["SignResponse"].Properties["data"]["$ref"])
+	require.Equal("array", doc.Components.Schemas["InfoData"].Properties["signers"]["type"])
+	require.Equal("date-time", doc.Components.Schemas["WatchResponse"].Properties["genesis"]["format"])
+	require.Contains(doc.Components.Schemas["SignRequest"].Properties, "signature")
+	require.Contains(doc.Components.Schemas, "ErrorDetail")
+}
+
+func TestRequestMetrics(t *testing.T) {
+	require := require.New(t)
+
+	count := func(action, status string) float64 {
+		families, err := metrics.Registry.Gather()
+		require.NoError(err)
+		for _, f := range families {
+			if f.GetName() != "tip_api_requests_total" {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				labels := make(map[string]string)
+				for _, l := range m.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				if labels["action"] == action && labels["status"] == status {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+		return 0
+	}
+
+	hdr := testHandler(testScalar(), &stubStore{})
+	info, watch := count("info", "200"), count("watch", "500")
+	serveTest(hdr, http.MethodGet, "/", "", nil)
+	serveTest(hdr, http.MethodGet, "/v1/info", "", nil)
+	serveTest(hdr, http.MethodPost, "/", `{"action":"WATCH","watcher":"bad"}`, nil)
+	require.Equal(info+2, count("info", "200"))
+	require.Equal(watch+1, count("watch", "500"))
+}
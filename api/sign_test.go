@@ -33,6 +33,7 @@ type signStoreStub struct {
 	checkEphemeralNonceFn func([]byte, []byte, uint64, time.Duration) (bool, error)
 	rotateEphemeralFn     func([]byte, []byte, uint64) error
 	writeSignRequestFn    func([]byte, []byte) (time.Time, int, error)
+	writeSignAuditFn      func([]byte, []byte, uint64, int) error
 	watchFn               func([]byte) ([]byte, time.Time, int, error)
 	writeAssigneeFn       func([]byte, []byte) error
 }
@@ -47,12 +48,14 @@ func newSignStoreStub() *signStoreStub {
 		checkEphemeralNonceFn: func([]byte, []byte, uint64, time.Duration) (bool, error) { return true, nil },
 		rotateEphemeralFn:     func([]byte, []byte, uint64) error { return nil },
 		writeSignRequestFn:    func([]byte, []byte) (time.Time, int, error) { return time.Unix(1700000100, 0), 1, nil },
+		writeSignAuditFn:      func([]byte, []byte, uint64, int) error { return nil },
 		watchFn:               func([]byte) ([]byte, time.Time, int, error) { return nil, time.Time{}, 0, nil },
 		writeAssigneeFn:       func([]byte, []byte) error { return nil },
 	}
 }
 
 func (*signStoreStub) CheckPolyGroup([]byte) (bool, error) { return false, nil }
+func (*signStoreStub) ReadPolyGroup() ([]byte, error)      { return nil, nil }
 func (*signStoreStub) ReadPolyPublic() ([]byte, error)     { return nil, nil }
 func (*signStoreStub) ReadPolyShare() ([]byte, error)      { return nil, nil }
 func (*signStoreStub) WritePoly([]byte, []byte) error      { return nil }
@@ -73,6 +76,10 @@ func (s *signStoreStub) RotateEphemeralNonce(key, ephemeral []byte, nonce uint64
 func (s *signStoreStub) WriteSignRequest(key, watcher []byte) (time.Time, int, error) {
 	return s.writeSignRequestFn(key, watcher)
 }
+func (s *signStoreStub) WriteSignAudit(assignor, watcher []byte, nonce uint64, counter int) error {
+	return s.writeSignAuditFn(assignor, watcher, nonce, counter)
+}
+func (*signStoreStub) Ping() error                                        { return nil }
 func (s *signStoreStub) Watch(key []byte) ([]byte, time.Time, int, error) { return s.watchFn(key) }
 
 func openAPIBadger(t *testing.T) *store.BadgerStorage {
@@ -84,13 +91,17 @@ func openAPIBadger(t *testing.T) *store.BadgerStorage {
 		_ = os.RemoveAll(dir)
 	})
 
-	bs, err := store.OpenBadger(context.Background(), &store.BadgerConfiguration{Dir: dir})
+	bs, err := store.OpenBadger(context.Background(), &store.Configuration{Dir: dir})
 	require.NoError(t, err)
 	t.Cleanup(bs.Close)
 	return bs
 }
 
 func makeAPISignRequest(user kyber.Scalar, signer kyber.Point, ephmr, rotate []byte, nonce, grace uint64, assignee, watcher string) *SignRequest {
+	return makeAPISignRequestVersion(user, signer, ephmr, rotate, nonce, grace, assignee, watcher, crypto.SignatureLegacy)
+}
+
+func makeAPISignRequestVersion(user kyber.Scalar, signer kyber.Point, ephmr, rotate []byte, nonce, grace uint64, assignee, watcher string, version int) *SignRequest {
 	pub := crypto.PublicKey(user)
 	msg := crypto.PublicKeyBytes(pub)
 	msg = append(msg, ephmr...)
@@ -117,10 +128,13 @@ func makeAPISignRequest(user kyber.Scalar, signer kyber.Point, ephmr, rotate []b
 		msg = append(msg, ab...)
 		payload["assignee"] = assignee
 	}
+	if version != crypto.SignatureLegacy {
+		payload["version"] = version
+	}
 
 	data, _ := json.Marshal(payload)
-	cipher := crypto.EncryptECDH(signer, user, data)
-	sig, _ := crypto.Sign(user, msg)
+	cipher, _ := crypto.EncryptECDH(signer, user, data)
+	sig, _ := crypto.SignDomain(user, crypto.DomainRequest, version, msg)
 
 	return &SignRequest{
 		Action:    "SIGN",
@@ -169,8 +183,7 @@ func TestSignSuccess(t *testing.T) {
 	require.NoError(err)
 	require.NoError(crypto.Verify(serverPub, payload, sig))
 
-	body := data.(map[string]any)
-	cipher, err := hex.DecodeString(body["cipher"].(string))
+	cipher, err := hex.DecodeString(data.Cipher)
 	require.NoError(err)
 
 	plain := crypto.DecryptECDH(serverPub, user, cipher)
@@ -181,6 +194,135 @@ func TestSignSuccess(t *testing.T) {
 	require.Equal(crypto.PublicKeyBytes(crypto.PublicKey(user)), plain[assignorOffset:assignorOffset+128])
 	require.False(time.Unix(0, int64(binary.BigEndian.Uint64(plain[assignorOffset+128:assignorOffset+136]))).IsZero())
 	require.Equal(uint64(1), binary.BigEndian.Uint64(plain[assignorOffset+136:]))
+
+	audits, head, err := bs.ReadSignAudits(0, 10)
+	require.NoError(err)
+	require.Len(audits, 1)
+	require.Equal(watcher, audits[0].Watcher)
+	require.Equal(uint64(21), audits[0].Nonce)
+	require.Equal(1, audits[0].Counter)
+	require.Equal(head.Hash, audits[0].Hash)
+}
+
+func TestSignFreshness(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteBn256()
+	serverKey := suite.Scalar().Pick(random.New())
+	serverPub := crypto.PublicKey(serverKey)
+	user := suite.Scalar().Pick(random.New())
+	ephmr := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
+	watcher := hex.EncodeToString(bytes.Repeat([]byte{0x14}, 32))
+	req := makeAPISignRequest(user, serverPub, ephmr, nil, 22, uint64(keeper.EphemeralGracePeriod), "", watcher)
+	req.Freshness = "0f0e0d0c0b0a09080706050403020100"
+	priv := &share.PriShare{I: 0, V: suite.Scalar().Pick(random.New())}
+
+	data, sigHex, err := sign(serverKey, openAPIBadger(t), req, priv)
+	require.NoError(err)
+	require.Equal(req.Freshness, data.Freshness)
+	require.Equal(RequestHash(req), data.Request)
+	require.InDelta(time.Now().Unix(), data.Timestamp, 5)
+
+	payload, err := json.Marshal(data)
+	require.NoError(err)
+	sig, err := hex.DecodeString(sigHex)
+	require.NoError(err)
+	require.NoError(crypto.Verify(serverPub, payload, sig))
+
+	other := *req
+	other.Watcher = hex.EncodeToString(bytes.Repeat([]byte{0x15}, 32))
+	require.NotEqual(RequestHash(req), RequestHash(&other))
+	other = *req
+	other.Freshness = ""
+	require.NotEqual(RequestHash(req), RequestHash(&other))
+
+	req.Freshness = "not hex"
+	hdr := &Handler{conf: &Configuration{Key: serverKey, Share: priv}, store: openAPIBadger(t)}
+	_, _, err = hdr.signRequest(req)
+	require.ErrorIs(err, ErrInvalidFreshness)
+	require.Equal(http.StatusBadRequest, signStatus(err))
+}
+
+func TestSignDomainVersion(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteBn256()
+	serverKey := suite.Scalar().Pick(random.New())
+	serverPub := crypto.PublicKey(serverKey)
+	user := suite.Scalar().Pick(random.New())
+	ephmr := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
+	watcher := hex.EncodeToString(bytes.Repeat([]byte{0x16}, 32))
+	priv := &share.PriShare{I: 0, V: suite.Scalar().Pick(random.New())}
+	bs := openAPIBadger(t)
+
+	req := makeAPISignRequestVersion(user, serverPub, ephmr, nil, 23, uint64(keeper.EphemeralGracePeriod), "", watcher, crypto.SignatureDomain)
+	data, sigHex, err := sign(serverKey, bs, req, priv)
+	require.NoError(err)
+	payload, err := json.Marshal(data)
+	require.NoError(err)
+	sig, err := hex.DecodeString(sigHex)
+	require.NoError(err)
+	require.NoError(crypto.VerifyDomain(serverPub, crypto.DomainResponse, crypto.SignatureDomain, payload, sig))
+	require.Error(crypto.Verify(serverPub, payload, sig))
+
+	// the request signed without the prefix is rejected by the version
+	req = makeAPISignRequestVersion(user, serverPub, ephmr, nil, 24, uint64(keeper.EphemeralGracePeriod), "", watcher, crypto.SignatureDomain)
+	legacy := makeAPISignRequest(user, serverPub, ephmr, nil, 24, uint64(keeper.EphemeralGracePeriod), "", watcher)
+	req.Signature = legacy.Signature
+	_, _, err = sign(serverKey, bs, req, priv)
+	require.Error(err)
+}
+
+func TestSignCipherVersion(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteBn256()
+	serverKey := suite.Scalar().Pick(random.New())
+	serverPub := crypto.PublicKey(serverKey)
+	user := suite.Scalar().Pick(random.New())
+	ephmr := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
+	watcher := hex.EncodeToString(bytes.Repeat([]byte{0x17}, 32))
+	priv := &share.PriShare{I: 0, V: suite.Scalar().Pick(random.New())}
+	bs := openAPIBadger(t)
+
+	req := makeAPISignRequest(user, serverPub, ephmr, nil, 25, uint64(keeper.EphemeralGracePeriod), "", watcher)
+	legacy, err := base64.RawURLEncoding.DecodeString(req.Data)
+	require.NoError(err)
+	plain := crypto.DecryptECDH(serverPub, user, legacy)
+	bound, err := crypto.EncryptECDHWithAD(serverPub, user, crypto.DirectionRequest, crypto.CipherBound, plain)
+	require.NoError(err)
+	req.Data = base64.RawURLEncoding.EncodeToString(bound)
+
+	_, _, err = sign(serverKey, bs, req, priv)
+	require.ErrorIs(err, ErrUnknown)
+
+	req.Cipher = crypto.CipherBound
+	data, _, err := sign(serverKey, bs, req, priv)
+	require.NoError(err)
+	cipher, err := hex.DecodeString(data.Cipher)
+	require.NoError(err)
+	require.Nil(crypto.DecryptECDH(serverPub, user, cipher))
+	plain = crypto.DecryptECDHWithAD(serverPub, user, crypto.DirectionResponse, crypto.CipherBound, cipher)
+	require.Equal(uint64(25), binary.BigEndian.Uint64(plain[:8]))
+
+	// the session cipher is only decrypted with the one-time key
+	session := suite.Scalar().Pick(random.New())
+	req = makeAPISignRequest(user, serverPub, ephmr, nil, 26, uint64(keeper.EphemeralGracePeriod), "", watcher)
+	legacy, err = base64.RawURLEncoding.DecodeString(req.Data)
+	require.NoError(err)
+	plain = crypto.DecryptECDH(serverPub, user, legacy)
+	sessioned, err := crypto.EncryptECDHWithAD(serverPub, session, crypto.DirectionRequest, crypto.CipherSession, plain)
+	require.NoError(err)
+	req.Data = base64.RawURLEncoding.EncodeToString(sessioned)
+	req.Cipher = crypto.CipherSession
+	req.Session = crypto.PublicKeyString(crypto.PublicKey(session))
+	data, _, err = sign(serverKey, bs, req, priv)
+	require.NoError(err)
+	cipher, err = hex.DecodeString(data.Cipher)
+	require.NoError(err)
+	require.Nil(crypto.DecryptECDHWithAD(serverPub, user, crypto.DirectionResponse, crypto.CipherSession, cipher))
+	plain = crypto.DecryptECDHWithAD(serverPub, session, crypto.DirectionResponse, crypto.CipherSession, cipher)
+	require.Equal(uint64(26), binary.BigEndian.Uint64(plain[:8]))
 }
 
 func TestSignMatchesLegacyKyberFixture(t *testing.T) {
@@ -239,8 +381,7 @@ func TestSignMatchesLegacyKyberFixture(t *testing.T) {
 	require.NoError(err)
 	require.Equal(legacyResponseSignature, sigHex)
 
-	body := data.(map[string]any)
-	require.Equal(legacyResponseCipher, body["cipher"])
+	require.Equal(legacyResponseCipher, data.Cipher)
 
 	payload, err := json.Marshal(data)
 	require.NoError(err)
@@ -248,7 +389,7 @@ func TestSignMatchesLegacyKyberFixture(t *testing.T) {
 	require.NoError(err)
 	require.NoError(crypto.Verify(serverPub, payload, sig))
 
-	cipher, err := hex.DecodeString(body["cipher"].(string))
+	cipher, err := hex.DecodeString(data.Cipher)
 	require.NoError(err)
 	plain := crypto.DecryptECDH(serverPub, user, cipher)
 	require.Equal(legacyResponsePlain, hex.EncodeToString(plain))
@@ -279,6 +420,14 @@ func TestSignMapsErrors(t *testing.T) {
 	_, _, err := sign(serverKey, store, req, priv)
 	require.ErrorIs(err, ErrTooManyRequest)
 
+	store = newSignStoreStub()
+	store.checkLimitFn = func([]byte, time.Duration, uint32, bool) (int, error) {
+		require.Fail("quota checked without share")
+		return 0, nil
+	}
+	_, _, err = sign(serverKey, store, req, nil)
+	require.ErrorIs(err, ErrNotReady)
+
 	store = newSignStoreStub()
 	store.watchFn = func([]byte) ([]byte, time.Time, int, error) {
 		return []byte("other-assignor"), time.Unix(1700000200, 0), 1, nil
@@ -297,6 +446,17 @@ func TestSignMapsErrors(t *testing.T) {
 	}
 	_, _, err = sign(serverKey, store, req, priv)
 	require.ErrorIs(err, ErrUnknown)
+
+	store = newSignStoreStub()
+	store.writeSignAuditFn = func(gotAssignor, gotWatcher []byte, nonce uint64, counter int) error {
+		require.Equal(assignor, gotAssignor)
+		require.Equal(watcher, gotWatcher)
+		require.Equal(uint64(22), nonce)
+		require.Equal(1, counter)
+		return fmt.Errorf("write-sign-audit")
+	}
+	_, _, err = sign(serverKey, store, req, priv)
+	require.ErrorIs(err, ErrUnknown)
 }
 
 func TestHandleSignStatusMappings(t *testing.T) {
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package api
+
+import (
+	"net"
+	"os"
+)
+
+func listenSocket(path string, _ os.FileMode) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+)
+
+// Certificates holds the TLS config loaded from the configured files, and
+// each handshake uses the latest one, so the files could be replaced and
+// reloaded without restarting the server.
+type Certificates struct {
+	conf    *Configuration
+	current atomic.Pointer[tls.Config]
+}
+
+// LoadCertificates returns nil when no TLS certificate is configured.
+func LoadCertificates(conf *Configuration) (*Certificates, error) {
+	if conf.TLSCert == "" && conf.TLSKey == "" && conf.ClientCA == "" {
+		return nil, nil
+	}
+	if conf.TLSCert == "" || conf.TLSKey == "" {
+		return nil, fmt.Errorf("both tls_cert and tls_key are required")
+	}
+	certs := &Certificates{conf: conf}
+	return certs, certs.Reload()
+}
+
+// Reload reads all the files again, and keeps the current config when any
+// of them is invalid.
+func (certs *Certificates) Reload() error {
+	cert, err := tls.LoadX509KeyPair(certs.conf.TLSCert, certs.conf.TLSKey)
+	if err != nil {
+		return fmt.Errorf("tls certificate %w", err)
+	}
+	tc := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if certs.conf.ClientCA != "" {
+		pem, err := os.ReadFile(certs.conf.ClientCA)
+		if err != nil {
+			return fmt.Errorf("tls client ca %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls client ca %s invalid", certs.conf.ClientCA)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	certs.current.Store(tc)
+	return nil
+}
+
+// config negotiates the protos, which are required by the gRPC server.
+func (certs *Certificates) config(protos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: protos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			tc := certs.current.Load().Clone()
+			tc.NextProtos = protos
+			return tc, nil
+		},
+	}
+}
+
+// Listen binds the unix socket when it's configured, otherwise the port,
+// and serves TLS when the certificates are not nil.
+func Listen(conf *Configuration, certs *Certificates) (net.Listener, error) {
+	var l net.Listener
+	var err error
+	if conf.Socket != "" {
+		l, err = listenUnix(conf.Socket)
+	} else {
+		l, err = net.Listen("tcp", fmt.Sprintf(":%d", conf.Port))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if certs != nil {
+		l = tls.NewListener(l, certs.config())
+	}
+	return l, nil
+}
+
+// listenUnix removes the stale socket of the last run, and the socket is
+// only accessible by the owner and group, which should be the proxy.
+func listenUnix(path string) (net.Listener, error) {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := listenSocket(path, 0o660)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, 0o660)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func makeTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := tpl, key
+	if parent == nil {
+		tpl.IsCA = true
+		tpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (tc *testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.der})
+	require.NoError(t, os.WriteFile(certPath, b, 0o600))
+	kb, err := x509.MarshalECPrivateKey(tc.key)
+	require.NoError(t, err)
+	b = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+	require.NoError(t, os.WriteFile(keyPath, b, 0o600))
+	return certPath, keyPath
+}
+
+func (tc *testCert) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{tc.der}, PrivateKey: tc.key}
+}
+
+func TestLoadCertificatesValidation(t *testing.T) {
+	require := require.New(t)
+
+	certs, err := LoadCertificates(&Configuration{})
+	require.NoError(err)
+	require.Nil(certs)
+
+	_, err = LoadCertificates(&Configuration{TLSCert: "server.crt"})
+	require.ErrorContains(err, "tls_key")
+	_, err = LoadCertificates(&Configuration{ClientCA: "ca.crt"})
+	require.ErrorContains(err, "tls_cert")
+	_, err = LoadCertificates(&Configuration{TLSCert: "missing.crt", TLSKey: "missing.key"})
+	require.ErrorContains(err, "tls certificate")
+}
+
+func TestListenMutualTLS(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	ca := makeTestCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	caPath, _ := ca.write(t, dir, "ca")
+	server := makeTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth)
+	certPath, keyPath := server.write(t, dir, "server")
+	client := makeTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+
+	conf := &Configuration{TLSCert: certPath, TLSKey: keyPath, ClientCA: caPath}
+	certs, err := LoadCertificates(conf)
+	require.NoError(err)
+	l, err := Listen(conf, certs)
+	require.NoError(err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	})}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	addr := l.Addr().String()
+	dial := func(certs ...tls.Certificate) (*tls.Conn, error) {
+		return tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, Certificates: certs, ServerName: "127.0.0.1"})
+	}
+
+	conn, err := dial(client.tls())
+	require.NoError(err)
+	require.Equal("server", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	conn.Close()
+
+	// TLS 1.3 reports the missing client certificate on the first read
+	conn, err = dial()
+	if err == nil {
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	require.Error(err)
+
+	rotated := makeTestCert(t, "rotated", ca, x509.ExtKeyUsageServerAuth)
+	rotated.write(t, dir, "server")
+	require.NoError(certs.Reload())
+	conn, err = dial(client.tls())
+	require.NoError(err)
+	require.Equal("rotated", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	conn.Close()
+
+	// an invalid file keeps the last certificate
+	require.NoError(os.WriteFile(certPath, []byte("invalid"), 0o600))
+	require.Error(certs.Reload())
+	conn, err = dial(client.tls())
+	require.NoError(err)
+	require.Equal("rotated", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	conn.Close()
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "api.sock")
+	require.NoError(os.WriteFile(path, nil, 0o600))
+	l, err := Listen(&Configuration{Socket: path, Port: 7000}, nil)
+	require.NoError(err)
+	defer l.Close()
+	require.Equal("unix", l.Addr().Network())
+
+	info, err := os.Stat(path)
+	require.NoError(err)
+	require.Equal(os.FileMode(0o660), info.Mode().Perm())
+	require.NotZero(info.Mode() & os.ModeSocket)
+}
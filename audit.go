@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/MixinNetwork/tip/config"
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/crypto/encoding"
+	"github.com/MixinNetwork/tip/store"
+	"github.com/urfave/cli/v2"
+)
+
+const signAuditPageSize = 1000
+
+func verifySignAudits(c *cli.Context) error {
+	conf, err := config.ReadConfiguration(c.String("config"))
+	if err != nil {
+		return err
+	}
+	key, err := signAuditKey(conf)
+	if err != nil {
+		return err
+	}
+	defer clear(key)
+
+	verifier := store.NewSignAuditVerifier(key)
+	if input := c.String("input"); input != "" {
+		err := verifySignAuditFile(verifier, input)
+		if err != nil {
+			return err
+		}
+		fmt.Println(input, verifier.Count())
+		return nil
+	}
+
+	head, err := readSignAudits(conf, func(a *store.SignAudit) error {
+		return verifier.Verify(a)
+	})
+	if err != nil {
+		return err
+	}
+	err = verifier.VerifyHead(head)
+	if err != nil {
+		return err
+	}
+	fmt.Println("sign audits", verifier.Count())
+	return nil
+}
+
+// an export has no separate head record, so only the chain is verified,
+// and the investigator should compare the last hash with the database
+func verifySignAuditFile(verifier *store.SignAuditVerifier, input string) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var last *store.SignAudit
+	for scanner.Scan() {
+		var a store.SignAudit
+		err := json.Unmarshal(scanner.Bytes(), &a)
+		if err != nil {
+			return err
+		}
+		err = verifier.Verify(&a)
+		if err != nil {
+			return err
+		}
+		last = &a
+	}
+	if last != nil {
+		fmt.Printf("head %d %x\n", last.Sequence, last.Hash)
+	}
+	return scanner.Err()
+}
+
+func exportSignAudits(c *cli.Context) error {
+	conf, err := config.ReadConfiguration(c.String("config"))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(c.String("output"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	var count int
+	_, err = readSignAudits(conf, func(a *store.SignAudit) error {
+		count++
+		return enc.Encode(a)
+	})
+	if err != nil {
+		return err
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	fmt.Println(c.String("output"), count)
+	return nil
+}
+
+// readSignAudits pages through the log until the head read in the first
+// page, so the records appended during the iteration are excluded.
+func readSignAudits(conf *config.Configuration, fn func(*store.SignAudit) error) (*store.SignAudit, error) {
+	admin, close, err := openAdmin(context.Background(), conf.Store, true)
+	if err != nil {
+		return nil, err
+	}
+	defer close()
+
+	var head *store.SignAudit
+	for offset := uint64(0); ; {
+		audits, h, err := admin.ReadSignAudits(offset, signAuditPageSize)
+		if err != nil {
+			return nil, err
+		}
+		if offset == 0 {
+			head = h
+		}
+		for _, a := range audits {
+			if head == nil || a.Sequence > head.Sequence {
+				return head, nil
+			}
+			err = fn(a)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(audits) < signAuditPageSize {
+			return head, nil
+		}
+		offset = audits[len(audits)-1].Sequence + 1
+	}
+}
+
+// signAuditKey derives the MAC key of the sign audits from the node key,
+// so the verification requires the node key as the writing.
+func signAuditKey(conf *config.Configuration) ([]byte, error) {
+	suite, err := crypto.NewSuite(conf.Node.Suite)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := encoding.ParsePrivateKey(suite, conf.Node.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.WipeScalar(priv)
+	return crypto.DeriveKey(priv, crypto.KeyLabelSignAudit), nil
+}
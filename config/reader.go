@@ -8,6 +8,7 @@ import (
 
 	"github.com/MixinNetwork/tip/api"
 	"github.com/MixinNetwork/tip/messenger"
+	"github.com/MixinNetwork/tip/metrics"
 	"github.com/MixinNetwork/tip/signer"
 	"github.com/MixinNetwork/tip/store"
 	"github.com/pelletier/go-toml"
@@ -16,8 +17,9 @@ import (
 type Configuration struct {
 	API       *api.Configuration            `toml:"api"`
 	Messenger *messenger.MixinConfiguration `toml:"messenger"`
-	Store     *store.BadgerConfiguration    `toml:"store"`
+	Store     *store.Configuration          `toml:"store"`
 	Node      *signer.Configuration         `toml:"node"`
+	Metrics   *metrics.Configuration        `toml:"metrics"`
 }
 
 func ReadConfiguration(path string) (*Configuration, error) {
@@ -5,14 +5,18 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha3"
+	"fmt"
 
 	"go.dedis.ch/kyber/v4"
-	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/util/random"
 )
 
+// ecdh returns nil when the point and scalar are of different suites.
 func ecdh(point kyber.Point, scalar kyber.Scalar) []byte {
-	suite := bn256.NewSuiteG2()
+	suite := PointSuite(point)
+	if SuiteName(suite) != SuiteName(ScalarSuite(scalar)) {
+		return nil
+	}
 	if point.Equal(suite.Point()) {
 		r := suite.Scalar().Pick(random.New())
 		point = point.Mul(r, nil)
@@ -21,10 +25,11 @@ func ecdh(point kyber.Point, scalar kyber.Scalar) []byte {
 
 	b := PublicKeyBytes(point)
 	sum := sha3.Sum256(b)
+	clear(b)
 	return sum[:]
 }
 
-func decrypt(secret, b []byte) []byte {
+func decrypt(secret, b, ad []byte) []byte {
 	aes, err := aes.NewCipher(secret)
 	if err != nil {
 		return nil
@@ -40,11 +45,11 @@ func decrypt(secret, b []byte) []byte {
 	}
 	nonce := b[:aead.NonceSize()]
 	cipher := b[aead.NonceSize():]
-	d, _ := aead.Open(nil, nonce, cipher, nil)
+	d, _ := aead.Open(nil, nonce, cipher, ad)
 	return d
 }
 
-func encrypt(secret, b []byte) []byte {
+func encrypt(secret, b, ad []byte) []byte {
 	aes, err := aes.NewCipher(secret)
 	if err != nil {
 		panic(err)
@@ -58,16 +63,87 @@ func encrypt(secret, b []byte) []byte {
 	if err != nil {
 		panic(err)
 	}
-	cipher := aead.Seal(nil, nonce, b, nil)
+	cipher := aead.Seal(nil, nonce, b, ad)
 	return append(nonce, cipher...)
 }
 
 func DecryptECDH(pub kyber.Point, priv kyber.Scalar, b []byte) []byte {
 	secret := ecdh(pub, priv)
-	return decrypt(secret, b)
+	defer clear(secret)
+	return decrypt(secret, b, nil)
 }
 
-func EncryptECDH(pub kyber.Point, priv kyber.Scalar, b []byte) []byte {
+// EncryptECDH fails when the pub and priv are of different suites, which
+// is possible for the keys from the configuration or command line.
+func EncryptECDH(pub kyber.Point, priv kyber.Scalar, b []byte) ([]byte, error) {
 	secret := ecdh(pub, priv)
-	return encrypt(secret, b)
+	if secret == nil {
+		return nil, suiteMismatch(pub, priv)
+	}
+	defer clear(secret)
+	return encrypt(secret, b, nil), nil
+}
+
+func suiteMismatch(pub kyber.Point, priv kyber.Scalar) error {
+	return fmt.Errorf("invalid suite %s for %s", SuiteName(PointSuite(pub)), SuiteName(ScalarSuite(priv)))
+}
+
+// The directions of the ciphers between a client and a signer, because both
+// directions have the same ECDH secret.
+const (
+	DirectionRequest  = "REQUEST"
+	DirectionResponse = "RESPONSE"
+)
+
+// CipherLegacy has no associated data, the same as the older versions, and
+// CipherBound binds the cipher to the sender, receiver and direction.
+// CipherSession is bound the same, but the client uses a one-time session
+// key instead of the identity key, and the signer responds to the session
+// key, so the ciphers recorded are not decrypted with the identity key.
+const (
+	CipherLegacy  = 0
+	CipherBound   = 1
+	CipherSession = 2
+)
+
+func CheckCipherVersion(version int) error {
+	if version < CipherLegacy || version > CipherSession {
+		return fmt.Errorf("invalid cipher version %d", version)
+	}
+	return nil
+}
+
+// CipherAD is the associated data of the cipher from the sender to the
+// receiver, nil for the legacy version.
+func CipherAD(sender, receiver kyber.Point, direction string, version int) []byte {
+	if version == CipherLegacy {
+		return nil
+	}
+	ad := fmt.Appendf(nil, "TIP/CIPHER/v%d/%s\x00", version, direction)
+	ad = append(ad, PublicKeyBytes(sender)...)
+	return append(ad, PublicKeyBytes(receiver)...)
+}
+
+// DecryptECDHWithAD returns nil when the cipher is not made by the pub for
+// the priv, in the direction and version.
+func DecryptECDHWithAD(pub kyber.Point, priv kyber.Scalar, direction string, version int, b []byte) []byte {
+	if CheckCipherVersion(version) != nil {
+		return nil
+	}
+	secret := ecdh(pub, priv)
+	defer clear(secret)
+	return decrypt(secret, b, CipherAD(pub, PublicKey(priv), direction, version))
+}
+
+func EncryptECDHWithAD(pub kyber.Point, priv kyber.Scalar, direction string, version int, b []byte) ([]byte, error) {
+	err := CheckCipherVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	secret := ecdh(pub, priv)
+	if secret == nil {
+		return nil, suiteMismatch(pub, priv)
+	}
+	defer clear(secret)
+	return encrypt(secret, b, CipherAD(PublicKey(priv), pub, direction, version)), nil
 }
@@ -40,7 +40,8 @@ func TestEncDec(t *testing.T) {
 	p2 := suite.Point().Mul(s2, nil)
 
 	text := []byte("hello")
-	b := EncryptECDH(p2, s1, text)
+	b, err := EncryptECDH(p2, s1, text)
+	require.NoError(err)
 	require.Len(b, 12+16+len(text))
 	dec := DecryptECDH(p1, s2, b)
 	require.Equal(text, dec)
@@ -51,9 +52,42 @@ func TestDecryptShortInput(t *testing.T) {
 
 	secret := make([]byte, 32)
 
-	require.Nil(decrypt(secret, nil))
-	require.Nil(decrypt(secret, []byte{}))
-	require.Nil(decrypt(secret, []byte{1, 2, 3}))
-	require.Nil(decrypt(secret, make([]byte, 11)))
-	require.Nil(decrypt(secret, make([]byte, 27)))
+	require.Nil(decrypt(secret, nil, nil))
+	require.Nil(decrypt(secret, []byte{}, nil))
+	require.Nil(decrypt(secret, []byte{1, 2, 3}, nil))
+	require.Nil(decrypt(secret, make([]byte, 11), nil))
+	require.Nil(decrypt(secret, make([]byte, 27), nil))
+}
+
+func TestEncDecWithAD(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteG2()
+	s1 := suite.Scalar().Pick(random.New())
+	p1 := suite.Point().Mul(s1, nil)
+	s2 := suite.Scalar().Pick(random.New())
+	p2 := suite.Point().Mul(s2, nil)
+	s3 := suite.Scalar().Pick(random.New())
+	p3 := suite.Point().Mul(s3, nil)
+
+	text := []byte("hello")
+	b, err := EncryptECDHWithAD(p2, s1, DirectionRequest, CipherBound, text)
+	require.NoError(err)
+	require.Equal(text, DecryptECDHWithAD(p1, s2, DirectionRequest, CipherBound, b))
+	require.Nil(DecryptECDHWithAD(p1, s2, DirectionResponse, CipherBound, b))
+	require.Nil(DecryptECDHWithAD(p1, s2, DirectionRequest, CipherLegacy, b))
+	require.Nil(DecryptECDHWithAD(p1, s2, DirectionRequest, 2, b))
+	require.Nil(DecryptECDH(p1, s2, b))
+	// the reply of the receiver is not valid as a request of the sender
+	require.Nil(DecryptECDHWithAD(p2, s1, DirectionRequest, CipherBound, b))
+	require.Nil(DecryptECDHWithAD(p3, s2, DirectionRequest, CipherBound, b))
+
+	b, err = EncryptECDHWithAD(p2, s1, DirectionResponse, CipherLegacy, text)
+	require.NoError(err)
+	require.Equal(text, DecryptECDH(p1, s2, b))
+	require.Equal(text, DecryptECDHWithAD(p1, s2, DirectionRequest, CipherLegacy, b))
+	require.Nil(DecryptECDHWithAD(p1, s2, DirectionResponse, CipherBound, b))
+
+	require.Equal("TIP/CIPHER/v1/REQUEST\x00", string(CipherAD(p1, p2, DirectionRequest, CipherBound)[:22]))
+	require.Nil(CipherAD(p1, p2, DirectionRequest, CipherLegacy))
 }
@@ -1,11 +1,9 @@
 package crypto
 
 import (
-	"encoding/hex"
-	"fmt"
-
 	"github.com/btcsuite/btcd/address/v2/base58"
 	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/pairing/bls12381/circl"
 	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/sign/bdn"
 )
@@ -15,23 +13,20 @@ const (
 )
 
 func Sign(scalar kyber.Scalar, msg []byte) ([]byte, error) {
-	scheme := bdn.NewSchemeOnG1(bn256.NewSuiteG2())
+	scheme := bdn.NewSchemeOnG1(ScalarSuite(scalar))
 	return scheme.Sign(scalar, msg)
 }
 
 func Verify(pub kyber.Point, msg, sig []byte) error {
-	scheme := bdn.NewSchemeOnG1(bn256.NewSuiteG2())
+	scheme := bdn.NewSchemeOnG1(PointSuite(pub))
 	return scheme.Verify(pub, msg, sig)
 }
 
+// PrivateKeyFromHex parses the legacy bn256 key, because the scalars of
+// the suites have the same size, the keys of a bls12381 network must be
+// parsed with the suite, e.g. by encoding.ParsePrivateKey.
 func PrivateKeyFromHex(s string) (kyber.Scalar, error) {
-	seed, err := hex.DecodeString(s)
-	if err != nil {
-		return nil, err
-	}
-	suite := bn256.NewSuiteG2()
-	scalar := suite.Scalar().SetBytes(seed)
-	return scalar, nil
+	return PrivateKeyFromHexWithSuite(bn256.NewSuiteG2(), s)
 }
 
 func PrivateKeyBytes(scalar kyber.Scalar) []byte {
@@ -43,13 +38,13 @@ func PrivateKeyBytes(scalar kyber.Scalar) []byte {
 }
 
 func PublicKey(scalar kyber.Scalar) kyber.Point {
-	suite := bn256.NewSuiteG2()
+	suite := ScalarSuite(scalar)
 	return suite.Point().Mul(scalar, nil)
 }
 
 func PublicKeyString(point kyber.Point) string {
 	b := PublicKeyBytes(point)
-	return base58.CheckEncode(b, KeyVersion)
+	return base58.CheckEncode(b, SuiteKeyVersion(PointSuite(point)))
 }
 
 func PublicKeyBytes(point kyber.Point) []byte {
@@ -60,11 +55,14 @@ func PublicKeyBytes(point kyber.Point) []byte {
 	return b
 }
 
+// PubKeyFromBytes parses the key of either suite by the size, because the
+// G2 points of the suites have different sizes.
 func PubKeyFromBytes(b []byte) (kyber.Point, error) {
-	suite := bn256.NewSuiteG2()
-	point := suite.G2().Point()
-	err := point.UnmarshalBinary(b)
-	return point, err
+	var suite Suite = bn256.NewSuiteG2()
+	if bls := circl.NewSuiteBLS12381(); len(b) == bls.PointLen() {
+		suite = bls
+	}
+	return PubKeyFromBytesWithSuite(suite, b)
 }
 
 func PubKeyFromBase58(s string) (kyber.Point, error) {
@@ -72,8 +70,9 @@ func PubKeyFromBase58(s string) (kyber.Point, error) {
 	if err != nil {
 		return nil, err
 	}
-	if ver != KeyVersion {
-		return nil, fmt.Errorf("invalid version %d", ver)
+	suite, err := suiteOfKeyVersion(ver)
+	if err != nil {
+		return nil, err
 	}
-	return PubKeyFromBytes(b)
+	return PubKeyFromBytesWithSuite(suite, b)
 }
@@ -0,0 +1,59 @@
+package crypto
+
+import (
+	"fmt"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+// The purposes of the messages signed by the same key, so a signature of
+// one purpose is never valid for another.
+const (
+	DomainBoard     = "BOARD"
+	DomainInfo      = "INFO"
+	DomainChallenge = "CHALLENGE"
+	DomainRequest   = "SIGN_REQUEST"
+	DomainResponse  = "SIGN_RESPONSE"
+	DomainAssignee  = "ASSIGNEE"
+)
+
+// SignatureLegacy signs the raw messages, the same as the older versions,
+// and SignatureDomain signs them with the purpose and version prefix.
+const (
+	SignatureLegacy = 0
+	SignatureDomain = 1
+)
+
+// DomainMessage returns the message unchanged for the legacy version,
+// otherwise the prefix TIP/<domain>/v<version> and a zero byte, which is
+// never in the domain, so the prefixes are not ambiguous.
+func DomainMessage(domain string, version int, msg []byte) []byte {
+	if version == SignatureLegacy {
+		return msg
+	}
+	prefix := fmt.Sprintf("TIP/%s/v%d\x00", domain, version)
+	return append([]byte(prefix), msg...)
+}
+
+func CheckSignatureVersion(version int) error {
+	if version < SignatureLegacy || version > SignatureDomain {
+		return fmt.Errorf("invalid signature version %d", version)
+	}
+	return nil
+}
+
+func SignDomain(scalar kyber.Scalar, domain string, version int, msg []byte) ([]byte, error) {
+	err := CheckSignatureVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return Sign(scalar, DomainMessage(domain, version, msg))
+}
+
+func VerifyDomain(pub kyber.Point, domain string, version int, msg, sig []byte) error {
+	err := CheckSignatureVersion(version)
+	if err != nil {
+		return err
+	}
+	return Verify(pub, DomainMessage(domain, version, msg), sig)
+}
@@ -0,0 +1,37 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestDomainSignature(t *testing.T) {
+	require := require.New(t)
+
+	priv := bn256.NewSuiteG2().Scalar().Pick(random.New())
+	pub := PublicKey(priv)
+	msg := []byte("tip-domain-message")
+
+	require.Equal(msg, DomainMessage(DomainInfo, SignatureLegacy, msg))
+	require.Equal("TIP/INFO/v1\x00tip-domain-message", string(DomainMessage(DomainInfo, SignatureDomain, msg)))
+
+	legacy, err := SignDomain(priv, DomainInfo, SignatureLegacy, msg)
+	require.NoError(err)
+	require.NoError(Verify(pub, msg, legacy))
+	require.NoError(VerifyDomain(pub, DomainResponse, SignatureLegacy, msg, legacy))
+	require.Error(VerifyDomain(pub, DomainInfo, SignatureDomain, msg, legacy))
+
+	sig, err := SignDomain(priv, DomainInfo, SignatureDomain, msg)
+	require.NoError(err)
+	require.NoError(VerifyDomain(pub, DomainInfo, SignatureDomain, msg, sig))
+	require.Error(VerifyDomain(pub, DomainResponse, SignatureDomain, msg, sig))
+	require.Error(VerifyDomain(pub, DomainInfo, SignatureLegacy, msg, sig))
+	require.Error(Verify(pub, msg, sig))
+
+	_, err = SignDomain(priv, DomainInfo, 2, msg)
+	require.Error(err)
+	require.Error(VerifyDomain(pub, DomainInfo, -1, msg, sig))
+}
@@ -0,0 +1,271 @@
+// Package encoding has the typed text forms of the keys, shares,
+// commitments and signatures, e.g. tippub_2bH8..., so a value is never
+// parsed as another type or on another curve.
+//
+// The text is the type prefix, an underscore and the base58 of the format
+// version, the type, the curve, the data and the first 4 bytes of the sha3
+// of the prefix and all the bytes before, as the checksum.
+package encoding
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/btcsuite/btcd/address/v2/base58"
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/share"
+)
+
+const version = 1
+
+type Type byte
+
+const (
+	TypePublicKey  Type = 1
+	TypePrivateKey Type = 2
+	TypeShare      Type = 3
+	TypeCommitment Type = 4
+	TypeSignature  Type = 5
+)
+
+var prefixes = map[Type]string{
+	TypePublicKey:  "tippub",
+	TypePrivateKey: "tipkey",
+	TypeShare:      "tipshr",
+	TypeCommitment: "tipcmt",
+	TypeSignature:  "tipsig",
+}
+
+// The curve IDs of the suites, which never change.
+const (
+	CurveBN256    byte = 1
+	CurveBLS12381 byte = 2
+)
+
+func curveOf(suite crypto.Suite) byte {
+	if crypto.SuiteName(suite) == crypto.SuiteBLS12381 {
+		return CurveBLS12381
+	}
+	return CurveBN256
+}
+
+func suiteOf(curve byte) (crypto.Suite, error) {
+	switch curve {
+	case CurveBN256:
+		return crypto.NewSuite(crypto.SuiteBN256)
+	case CurveBLS12381:
+		return crypto.NewSuite(crypto.SuiteBLS12381)
+	}
+	return nil, fmt.Errorf("invalid curve %d", curve)
+}
+
+// IsTyped tells whether the text has a type prefix, otherwise it may be a
+// legacy key.
+func IsTyped(s string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+func encode(typ Type, suite crypto.Suite, data []byte) string {
+	prefix := prefixes[typ]
+	b := []byte{version, byte(typ), curveOf(suite)}
+	b = append(b, data...)
+	b = append(b, checksum(prefix, b)...)
+	defer clear(b)
+	return prefix + "_" + base58.Encode(b)
+}
+
+// decode checks the prefix, version, type and checksum, and returns the
+// suite of the curve and the data.
+func decode(typ Type, s string) (crypto.Suite, []byte, error) {
+	prefix, text, found := strings.Cut(s, "_")
+	if !found || prefix != prefixes[typ] {
+		return nil, nil, fmt.Errorf("invalid type prefix %s", prefix)
+	}
+	b := base58.Decode(text)
+	if len(b) < 3+4 {
+		return nil, nil, fmt.Errorf("invalid encoding size %d", len(b))
+	}
+	b, sum := b[:len(b)-4], b[len(b)-4:]
+	if !bytes.Equal(sum, checksum(prefix, b)) {
+		return nil, nil, fmt.Errorf("invalid checksum %x", sum)
+	}
+	if b[0] != version {
+		return nil, nil, fmt.Errorf("invalid version %d", b[0])
+	}
+	if Type(b[1]) != typ {
+		return nil, nil, fmt.Errorf("invalid type %d", b[1])
+	}
+	suite, err := suiteOf(b[2])
+	if err != nil {
+		return nil, nil, err
+	}
+	return suite, b[3:], nil
+}
+
+func checksum(prefix string, b []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte(prefix))
+	h.Write(b)
+	return h.Sum(nil)[:4]
+}
+
+func EncodePublicKey(point kyber.Point) string {
+	return encodePoint(TypePublicKey, point)
+}
+
+func DecodePublicKey(s string) (kyber.Point, error) {
+	return decodePoint(TypePublicKey, s)
+}
+
+func EncodeCommitment(point kyber.Point) string {
+	return encodePoint(TypeCommitment, point)
+}
+
+func DecodeCommitment(s string) (kyber.Point, error) {
+	return decodePoint(TypeCommitment, s)
+}
+
+func encodePoint(typ Type, point kyber.Point) string {
+	return encode(typ, crypto.PointSuite(point), crypto.PublicKeyBytes(point))
+}
+
+func decodePoint(typ Type, s string) (kyber.Point, error) {
+	suite, b, err := decode(typ, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != suite.PointLen() {
+		return nil, fmt.Errorf("invalid point size %d", len(b))
+	}
+	return crypto.PubKeyFromBytesWithSuite(suite, b)
+}
+
+func EncodePrivateKey(scalar kyber.Scalar) string {
+	b := crypto.PrivateKeyBytes(scalar)
+	defer clear(b)
+	return encode(TypePrivateKey, crypto.ScalarSuite(scalar), b)
+}
+
+func DecodePrivateKey(s string) (kyber.Scalar, error) {
+	suite, b, err := decode(TypePrivateKey, s)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(b)
+	return decodeScalar(suite, b)
+}
+
+// EncodeShare has the 4 bytes index before the scalar, the same as the
+// share in the signer database.
+func EncodeShare(ps *share.PriShare) string {
+	b := binary.BigEndian.AppendUint32(nil, ps.I)
+	sb := crypto.PrivateKeyBytes(ps.V)
+	defer clear(sb)
+	b = append(b, sb...)
+	defer clear(b)
+	return encode(TypeShare, crypto.ScalarSuite(ps.V), b)
+}
+
+func DecodeShare(s string) (*share.PriShare, error) {
+	suite, b, err := decode(TypeShare, s)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(b)
+	if len(b) < 4 {
+		return nil, fmt.Errorf("invalid share size %d", len(b))
+	}
+	scalar, err := decodeScalar(suite, b[4:])
+	if err != nil {
+		return nil, err
+	}
+	return &share.PriShare{I: binary.BigEndian.Uint32(b[:4]), V: scalar}, nil
+}
+
+// decodeScalar rejects the scalars not reduced by the curve order, which
+// are never encoded.
+func decodeScalar(suite crypto.Suite, b []byte) (kyber.Scalar, error) {
+	if len(b) != suite.ScalarLen() {
+		return nil, fmt.Errorf("invalid scalar size %d", len(b))
+	}
+	scalar := suite.Scalar().SetBytes(b)
+	sb := crypto.PrivateKeyBytes(scalar)
+	defer clear(sb)
+	if !bytes.Equal(sb, b) {
+		crypto.WipeScalar(scalar)
+		return nil, fmt.Errorf("invalid scalar")
+	}
+	return scalar, nil
+}
+
+// EncodeSignature has the curve of the key, because the signature on G1
+// doesn't tell it.
+func EncodeSignature(suite crypto.Suite, sig []byte) string {
+	return encode(TypeSignature, suite, sig)
+}
+
+func DecodeSignature(s string) (crypto.Suite, []byte, error) {
+	suite, b, err := decode(TypeSignature, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(b) != suite.G1().PointLen() {
+		return nil, nil, fmt.Errorf("invalid signature size %d", len(b))
+	}
+	return suite, b, nil
+}
+
+// ParsePublicKey accepts the typed public key, or the legacy base58 key of
+// either suite.
+func ParsePublicKey(s string) (kyber.Point, error) {
+	if IsTyped(s) {
+		return DecodePublicKey(s)
+	}
+	return crypto.PubKeyFromBase58(s)
+}
+
+// ParseCommitment accepts the typed commitment, or the legacy base58 key.
+func ParseCommitment(s string) (kyber.Point, error) {
+	if IsTyped(s) {
+		return DecodeCommitment(s)
+	}
+	return crypto.PubKeyFromBase58(s)
+}
+
+// ParsePrivateKey accepts the typed private key of the suite, or the legacy
+// hex key, which doesn't tell the suite.
+func ParsePrivateKey(suite crypto.Suite, s string) (kyber.Scalar, error) {
+	if !IsTyped(s) {
+		return crypto.PrivateKeyFromHexWithSuite(suite, s)
+	}
+	scalar, err := DecodePrivateKey(s)
+	if err != nil {
+		return nil, err
+	}
+	if crypto.SuiteName(crypto.ScalarSuite(scalar)) != crypto.SuiteName(suite) {
+		return nil, fmt.Errorf("invalid suite %s", crypto.SuiteName(crypto.ScalarSuite(scalar)))
+	}
+	return scalar, nil
+}
+
+// ParsePublicKeyWithSuite rejects the keys of the other suites, which must
+// be checked before the keys are used with a private key.
+func ParsePublicKeyWithSuite(suite crypto.Suite, s string) (kyber.Point, error) {
+	point, err := ParsePublicKey(s)
+	if err != nil {
+		return nil, err
+	}
+	if crypto.SuiteName(crypto.PointSuite(point)) != crypto.SuiteName(suite) {
+		return nil, fmt.Errorf("invalid suite %s", crypto.SuiteName(crypto.PointSuite(point)))
+	}
+	return point, nil
+}
@@ -0,0 +1,152 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/btcsuite/btcd/address/v2/base58"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	for _, name := range []string{crypto.SuiteBN256, crypto.SuiteBLS12381} {
+		suite, err := crypto.NewSuite(name)
+		require.NoError(err)
+		priv := suite.Scalar().Pick(random.New())
+		pub := crypto.PublicKey(priv)
+
+		ps := EncodePublicKey(pub)
+		require.True(strings.HasPrefix(ps, "tippub_"))
+		require.True(IsTyped(ps))
+		point, err := DecodePublicKey(ps)
+		require.NoError(err)
+		require.True(pub.Equal(point))
+		point, err = ParsePublicKey(ps)
+		require.NoError(err)
+		require.True(pub.Equal(point))
+		point, err = ParsePublicKey(crypto.PublicKeyString(pub))
+		require.NoError(err)
+		require.True(pub.Equal(point))
+		_, err = ParsePublicKeyWithSuite(suite, ps)
+		require.NoError(err)
+
+		cs := EncodeCommitment(pub)
+		require.True(strings.HasPrefix(cs, "tipcmt_"))
+		point, err = ParseCommitment(cs)
+		require.NoError(err)
+		require.True(pub.Equal(point))
+
+		ks := EncodePrivateKey(priv)
+		require.True(strings.HasPrefix(ks, "tipkey_"))
+		scalar, err := DecodePrivateKey(ks)
+		require.NoError(err)
+		require.True(priv.Equal(scalar))
+		scalar, err = ParsePrivateKey(suite, ks)
+		require.NoError(err)
+		require.True(priv.Equal(scalar))
+		scalar, err = ParsePrivateKey(suite, hex.EncodeToString(crypto.PrivateKeyBytes(priv)))
+		require.NoError(err)
+		require.True(priv.Equal(scalar))
+
+		ss := EncodeShare(&share.PriShare{I: 7, V: priv})
+		require.True(strings.HasPrefix(ss, "tipshr_"))
+		ps2, err := DecodeShare(ss)
+		require.NoError(err)
+		require.Equal(uint32(7), ps2.I)
+		require.True(priv.Equal(ps2.V))
+
+		sig, err := crypto.Sign(priv, []byte("tip"))
+		require.NoError(err)
+		gs := EncodeSignature(suite, sig)
+		require.True(strings.HasPrefix(gs, "tipsig_"))
+		gsuite, b, err := DecodeSignature(gs)
+		require.NoError(err)
+		require.Equal(name, crypto.SuiteName(gsuite))
+		require.Equal(sig, b)
+		require.NoError(crypto.Verify(pub, []byte("tip"), b))
+	}
+}
+
+func TestEncodingTypeConfusion(t *testing.T) {
+	require := require.New(t)
+
+	suite, err := crypto.NewSuite(crypto.SuiteBN256)
+	require.NoError(err)
+	bls, err := crypto.NewSuite(crypto.SuiteBLS12381)
+	require.NoError(err)
+	priv := suite.Scalar().Pick(random.New())
+	pub := crypto.PublicKey(priv)
+	ps := EncodePublicKey(pub)
+	cs := EncodeCommitment(pub)
+	ks := EncodePrivateKey(priv)
+
+	_, err = DecodeCommitment(ps)
+	require.ErrorContains(err, "invalid type prefix")
+	_, err = DecodePublicKey(cs)
+	require.ErrorContains(err, "invalid type prefix")
+	_, err = ParsePublicKey(ks)
+	require.ErrorContains(err, "invalid type prefix")
+	_, err = DecodePrivateKey(ps)
+	require.ErrorContains(err, "invalid type prefix")
+	_, err = DecodeShare(ks)
+	require.ErrorContains(err, "invalid type prefix")
+	_, _, err = DecodeSignature(ps)
+	require.ErrorContains(err, "invalid type prefix")
+
+	// a public key relabeled as a commitment fails the checksum of the
+	// prefix, and with the checksum fixed, the type byte
+	_, err = DecodeCommitment("tipcmt_" + strings.TrimPrefix(ps, "tippub_"))
+	require.ErrorContains(err, "invalid checksum")
+	b := base58.Decode(strings.TrimPrefix(ps, "tippub_"))
+	b = b[:len(b)-4]
+	_, err = DecodeCommitment("tipcmt_" + base58.Encode(append(b, checksum("tipcmt", b)...)))
+	require.ErrorContains(err, "invalid type")
+
+	// the data of another curve, or an unknown curve
+	b[2] = CurveBLS12381
+	_, err = DecodePublicKey("tippub_" + base58.Encode(append(b, checksum("tippub", b)...)))
+	require.Error(err)
+	b[2] = 9
+	_, err = DecodePublicKey("tippub_" + base58.Encode(append(b, checksum("tippub", b)...)))
+	require.ErrorContains(err, "invalid curve")
+	b[2], b[0] = CurveBN256, 2
+	_, err = DecodePublicKey("tippub_" + base58.Encode(append(b, checksum("tippub", b)...)))
+	require.ErrorContains(err, "invalid version")
+
+	tampered := []byte(ps)
+	tampered[len(tampered)-3] ^= 1
+	_, err = DecodePublicKey(string(tampered))
+	require.Error(err)
+	_, err = DecodePublicKey("tippub_")
+	require.ErrorContains(err, "invalid encoding size")
+	_, err = DecodePublicKey("tippub")
+	require.ErrorContains(err, "invalid type prefix")
+
+	_, err = ParsePrivateKey(bls, ks)
+	require.ErrorContains(err, "invalid suite")
+	_, err = ParsePublicKeyWithSuite(bls, ps)
+	require.ErrorContains(err, "invalid suite")
+	_, err = ParsePublicKeyWithSuite(bls, crypto.PublicKeyString(pub))
+	require.ErrorContains(err, "invalid suite")
+
+	// the scalar not reduced by the curve order is never encoded
+	max := bytes.Repeat([]byte{0xff}, suite.ScalarLen())
+	_, err = DecodePrivateKey(encode(TypePrivateKey, suite, max))
+	require.ErrorContains(err, "invalid scalar")
+	_, err = DecodePrivateKey(encode(TypePrivateKey, suite, max[1:]))
+	require.ErrorContains(err, "invalid scalar size")
+	_, err = DecodeShare(encode(TypeShare, suite, []byte{1, 2}))
+	require.ErrorContains(err, "invalid share size")
+
+	sig, err := crypto.Sign(priv, []byte("tip"))
+	require.NoError(err)
+	_, _, err = DecodeSignature(EncodeSignature(bls, sig))
+	require.ErrorContains(err, "invalid signature size")
+}
@@ -0,0 +1,37 @@
+package crypto
+
+import (
+	"crypto/sha3"
+	"encoding/binary"
+	"math/bits"
+)
+
+// SolveWork finds the first solution of the proof-of-work puzzle, which
+// takes about 2^difficulty hashes, so the caller must bound the difficulty
+// received from a signer.
+func SolveWork(seed, data []byte, difficulty int) uint64 {
+	for solution := uint64(0); ; solution++ {
+		if VerifyWork(seed, data, difficulty, solution) {
+			return solution
+		}
+	}
+}
+
+// VerifyWork checks the sha3 of the seed, data and solution has at least
+// difficulty leading zero bits.
+func VerifyWork(seed, data []byte, difficulty int, solution uint64) bool {
+	h := sha3.New256()
+	h.Write(seed)
+	h.Write(data)
+	h.Write(binary.BigEndian.AppendUint64(nil, solution))
+	sum := h.Sum(nil)
+
+	zeros := 0
+	for _, b := range sum {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros >= difficulty
+}
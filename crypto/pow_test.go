@@ -0,0 +1,21 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWork(t *testing.T) {
+	require := require.New(t)
+
+	seed, data := []byte("seed"), []byte("data")
+	solution := SolveWork(seed, data, 12)
+	require.True(VerifyWork(seed, data, 12, solution))
+	require.True(VerifyWork(seed, data, 0, solution+1))
+
+	for s := range solution {
+		require.False(VerifyWork(seed, data, 12, s))
+	}
+	require.False(VerifyWork(seed, data, 257, solution))
+}
@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"crypto/hkdf"
+	"crypto/sha3"
+	"encoding/hex"
+	"math/big"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/mod"
+	"go.dedis.ch/kyber/v4/share"
+)
+
+// Secret holds the bytes of a key, an ephemeral or a decrypted body, and
+// zeroes them on release, so they don't stay in the memory after use.
+// The Go runtime may still have copied them, e.g. when a slice grows, so
+// the bytes should never be appended or converted to a string.
+type Secret struct {
+	b []byte
+}
+
+// NewSecret takes the buffer, which is zeroed on release.
+func NewSecret(b []byte) *Secret {
+	return &Secret{b: b}
+}
+
+func SecretFromHex(s string) (*Secret, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewSecret(b), nil
+}
+
+// Bytes returns the buffer itself, which must not be used after release.
+func (s *Secret) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.b
+}
+
+// Scalar returns a new scalar of the suite, which should be wiped by the
+// caller after use.
+func (s *Secret) Scalar(suite Suite) kyber.Scalar {
+	return suite.Scalar().SetBytes(s.Bytes())
+}
+
+func (s *Secret) Release() {
+	if s == nil {
+		return
+	}
+	clear(s.b)
+	s.b = nil
+}
+
+// WipeScalar zeroes the private key or share, and for the big integers the
+// whole backing array, because the zero value only shortens the slice.
+func WipeScalar(scalar kyber.Scalar) {
+	if scalar == nil {
+		return
+	}
+	if v, ok := scalar.(*mod.Int); ok {
+		WipeInt(&v.V.Int)
+	}
+	scalar.Zero()
+}
+
+// WipeInt zeroes the whole backing array of the integer and sets it to zero.
+func WipeInt(v *big.Int) {
+	if v == nil {
+		return
+	}
+	bits := v.Bits()
+	clear(bits[:cap(bits)])
+	v.SetInt64(0)
+}
+
+func WipeShare(ps *share.PriShare) {
+	if ps == nil {
+		return
+	}
+	WipeScalar(ps.V)
+}
+
+// The labels of the keys derived from the node key, e.g. the MAC keys, so
+// the node key itself is never used for another purpose.
+const (
+	KeyLabelSignAudit = "TIP#SIGN_AUDIT"
+	KeyLabelChallenge = "TIP#CHALLENGE"
+)
+
+// DeriveKey returns the 32 bytes HKDF key of the label, and the copy of the
+// private key is zeroed after.
+func DeriveKey(priv kyber.Scalar, label string) []byte {
+	b := PrivateKeyBytes(priv)
+	defer clear(b)
+	key, err := hkdf.Key(sha3.New256, b, nil, label, 32)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/group/mod"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestSecret(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteG2()
+	priv := suite.Scalar().Pick(random.New())
+	b := PrivateKeyBytes(priv)
+	s := NewSecret(b)
+	require.Equal(b, s.Bytes())
+	scalar := s.Scalar(suite)
+	require.True(priv.Equal(scalar))
+	s.Release()
+	require.Nil(s.Bytes())
+	require.Equal(make([]byte, len(b)), b)
+	require.True(priv.Equal(scalar))
+	s.Release()
+
+	var empty *Secret
+	require.Nil(empty.Bytes())
+	empty.Release()
+
+	s, err := SecretFromHex(hex.EncodeToString(PrivateKeyBytes(priv)))
+	require.NoError(err)
+	require.Equal(PrivateKeyBytes(priv), s.Bytes())
+	b = s.Bytes()
+	s.Release()
+	require.Equal(make([]byte, len(b)), b)
+	_, err = SecretFromHex("invalid")
+	require.Error(err)
+}
+
+func TestWipeScalar(t *testing.T) {
+	require := require.New(t)
+
+	priv := bn256.NewSuiteG2().Scalar().Pick(random.New())
+	bits := priv.(*mod.Int).V.Int.Bits()
+	bits = bits[:cap(bits)]
+	require.False(isZeroWords(bits))
+	WipeScalar(priv)
+	require.True(isZeroWords(bits))
+	require.True(priv.Equal(priv.Clone().Zero()))
+
+	suite, err := NewSuite(SuiteBLS12381)
+	require.NoError(err)
+	priv = suite.Scalar().Pick(random.New())
+	ps := &share.PriShare{I: 1, V: priv}
+	WipeShare(ps)
+	require.True(priv.Equal(suite.Scalar().Zero()))
+	require.True(bytes.Equal(make([]byte, suite.ScalarLen()), PrivateKeyBytes(priv)))
+
+	WipeScalar(nil)
+	WipeShare(nil)
+	WipeInt(nil)
+
+	v := new(big.Int).SetBytes(PrivateKeyBytes(bn256.NewSuiteG2().Scalar().Pick(random.New())))
+	bits = v.Bits()
+	bits = bits[:cap(bits)]
+	WipeInt(v)
+	require.True(isZeroWords(bits))
+	require.Equal(0, v.Sign())
+}
+
+func isZeroWords(bits []big.Word) bool {
+	for _, w := range bits {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDeriveKey(t *testing.T) {
+	require := require.New(t)
+
+	priv := bn256.NewSuiteG2().Scalar().Pick(random.New())
+	key := DeriveKey(priv, KeyLabelSignAudit)
+	require.Len(key, 32)
+	require.Equal(key, DeriveKey(priv, KeyLabelSignAudit))
+	require.NotEqual(key, DeriveKey(priv, "TIP#OTHER"))
+	require.NotEqual(key, PrivateKeyBytes(priv)[:32])
+}
@@ -0,0 +1,166 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+// The stream cipher seals every chunk with the counter as nonce, and the
+// final flag as associated data, so reordered, dropped or truncated chunks
+// all fail to open. The secret must be of a one-time ephemeral key, which
+// makes the counter nonce never reused.
+const (
+	streamChunkSize  = 64 * 1024
+	streamChunkFinal = 1
+)
+
+type ecdhWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+type ecdhReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	final   bool
+}
+
+// NewECDHWriter encrypts the plain data written in chunks, and Close must
+// be called to write the final chunk, otherwise the reader rejects it.
+func NewECDHWriter(w io.Writer, pub kyber.Point, priv kyber.Scalar) (io.WriteCloser, error) {
+	aead, err := streamAEAD(pub, priv)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, streamChunkSize)
+	return &ecdhWriter{w: w, aead: aead, buf: buf}, nil
+}
+
+// NewECDHReader decrypts the stream of NewECDHWriter, and returns an error
+// instead of io.EOF when the stream ends before the final chunk.
+func NewECDHReader(r io.Reader, pub kyber.Point, priv kyber.Scalar) (io.Reader, error) {
+	aead, err := streamAEAD(pub, priv)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdhReader{r: r, aead: aead}, nil
+}
+
+func streamAEAD(pub kyber.Point, priv kyber.Scalar) (cipher.AEAD, error) {
+	secret := ecdh(pub, priv)
+	if secret == nil {
+		return nil, suiteMismatch(pub, priv)
+	}
+	defer clear(secret)
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func streamNonce(aead cipher.AEAD, counter uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], counter)
+	return nonce
+}
+
+func (ew *ecdhWriter) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, fmt.Errorf("write to closed stream")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if len(ew.buf) == streamChunkSize {
+			err := ew.seal(0)
+			if err != nil {
+				return n - len(p), err
+			}
+		}
+		c := copy(ew.buf[len(ew.buf):streamChunkSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+c]
+		p = p[c:]
+	}
+	return n, nil
+}
+
+func (ew *ecdhWriter) Close() error {
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	defer clear(ew.buf[:cap(ew.buf)])
+	return ew.seal(streamChunkFinal)
+}
+
+// seal writes the chunk as flag(1) || size(4) || sealed.
+func (ew *ecdhWriter) seal(flag byte) error {
+	ad := []byte{flag}
+	sealed := ew.aead.Seal(nil, streamNonce(ew.aead, ew.counter), ew.buf, ad)
+	clear(ew.buf)
+	ew.buf = ew.buf[:0]
+	ew.counter++
+
+	header := make([]byte, 5)
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sealed)))
+	_, err := ew.w.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = ew.w.Write(sealed)
+	return err
+}
+
+func (er *ecdhReader) Read(p []byte) (int, error) {
+	for len(er.buf) == 0 {
+		if er.final {
+			return 0, io.EOF
+		}
+		err := er.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, er.buf)
+	clear(er.buf[:n])
+	er.buf = er.buf[n:]
+	return n, nil
+}
+
+func (er *ecdhReader) open() error {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(er.r, header)
+	if err == io.EOF {
+		return fmt.Errorf("stream truncated at chunk %d", er.counter)
+	} else if err != nil {
+		return err
+	}
+	flag, size := header[0], binary.BigEndian.Uint32(header[1:])
+	if flag > streamChunkFinal || size > streamChunkSize+uint32(er.aead.Overhead()) {
+		return fmt.Errorf("invalid stream chunk %d header %x", er.counter, header)
+	}
+	sealed := make([]byte, size)
+	_, err = io.ReadFull(er.r, sealed)
+	if err != nil {
+		return err
+	}
+	plain, err := er.aead.Open(sealed[:0], streamNonce(er.aead, er.counter), sealed, []byte{flag})
+	if err != nil {
+		return fmt.Errorf("invalid stream chunk %d", er.counter)
+	}
+	er.buf = plain
+	er.counter++
+	er.final = flag == streamChunkFinal
+	return nil
+}
@@ -0,0 +1,59 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestECDHStream(t *testing.T) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteG2()
+	s1 := suite.Scalar().Pick(random.New())
+	p1 := suite.Point().Mul(s1, nil)
+	s2 := suite.Scalar().Pick(random.New())
+	p2 := suite.Point().Mul(s2, nil)
+
+	for _, size := range []int{0, 1, streamChunkSize, streamChunkSize*2 + 7} {
+		plain := make([]byte, size)
+		random.Bytes(plain, random.New())
+
+		var buf bytes.Buffer
+		w, err := NewECDHWriter(&buf, p2, s1)
+		require.NoError(err)
+		_, err = w.Write(plain)
+		require.NoError(err)
+		require.NoError(w.Close())
+		sealed := buf.Bytes()
+
+		r, err := NewECDHReader(bytes.NewReader(sealed), p1, s2)
+		require.NoError(err)
+		dec, err := io.ReadAll(r)
+		require.NoError(err)
+		require.Equal(plain, dec)
+
+		r, err = NewECDHReader(bytes.NewReader(sealed), p2, s2)
+		require.NoError(err)
+		_, err = io.ReadAll(r)
+		require.Error(err)
+
+		if size <= streamChunkSize {
+			continue
+		}
+		// truncated at the chunk boundary before the final chunk
+		r, err = NewECDHReader(bytes.NewReader(sealed[:5+streamChunkSize+16]), p1, s2)
+		require.NoError(err)
+		_, err = io.ReadAll(r)
+		require.ErrorContains(err, "truncated")
+	}
+
+	other, err := NewSuite(SuiteBLS12381)
+	require.NoError(err)
+	_, err = NewECDHWriter(io.Discard, p2, other.Scalar().Pick(random.New()))
+	require.ErrorContains(err, "invalid suite")
+}
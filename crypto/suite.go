@@ -0,0 +1,115 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/pairing"
+	"go.dedis.ch/kyber/v4/pairing/bls12381/circl"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+)
+
+// The pairing curves of a network, the bn256 is the suite of the older
+// networks, and the BLS12-381 is only for the new networks, because the
+// keys, shares and commitments of a network can't be converted.
+const (
+	SuiteBN256    = "bn256"
+	SuiteBLS12381 = "bls12381"
+)
+
+// KeyVersionBLS12381 is the base58 version of the BLS12-381 public keys,
+// so a key of one suite is never parsed as the other.
+const (
+	KeyVersionBLS12381 = 'B'
+)
+
+// Suite is the G2 adapter of a pairing curve, the points are the public
+// keys on G2 and the signatures are on G1.
+type Suite interface {
+	pairing.Suite
+	kyber.Group
+	kyber.HashFactory
+	kyber.XOFFactory
+	kyber.Random
+}
+
+// NewSuite returns the suite of the name, and the empty name is the bn256
+// suite for the configurations without it.
+func NewSuite(name string) (Suite, error) {
+	switch name {
+	case "", SuiteBN256:
+		return bn256.NewSuiteG2(), nil
+	case SuiteBLS12381:
+		return circl.NewSuiteBLS12381(), nil
+	}
+	return nil, fmt.Errorf("invalid suite %s", name)
+}
+
+func SuiteName(suite Suite) string {
+	if _, ok := suite.(*circl.SuiteBLS12381); ok {
+		return SuiteBLS12381
+	}
+	return SuiteBN256
+}
+
+// PointSuite returns the suite of a public key or commitment.
+func PointSuite(point kyber.Point) Suite {
+	if _, ok := point.(*circl.G2Elt); ok {
+		return circl.NewSuiteBLS12381()
+	}
+	return bn256.NewSuiteG2()
+}
+
+// ScalarSuite returns the suite of a private key or share.
+func ScalarSuite(scalar kyber.Scalar) Suite {
+	if _, ok := scalar.(*circl.Scalar); ok {
+		return circl.NewSuiteBLS12381()
+	}
+	return bn256.NewSuiteG2()
+}
+
+func SuiteKeyVersion(suite Suite) byte {
+	if SuiteName(suite) == SuiteBLS12381 {
+		return KeyVersionBLS12381
+	}
+	return KeyVersion
+}
+
+func suiteOfKeyVersion(ver byte) (Suite, error) {
+	switch ver {
+	case KeyVersion:
+		return bn256.NewSuiteG2(), nil
+	case KeyVersionBLS12381:
+		return circl.NewSuiteBLS12381(), nil
+	}
+	return nil, fmt.Errorf("invalid version %d", ver)
+}
+
+func PrivateKeyFromHexWithSuite(suite Suite, s string) (kyber.Scalar, error) {
+	seed, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(seed)
+	return suite.Scalar().SetBytes(seed), nil
+}
+
+func PubKeyFromBytesWithSuite(suite Suite, b []byte) (kyber.Point, error) {
+	point := suite.G2().Point()
+	err := point.UnmarshalBinary(b)
+	return point, err
+}
+
+// PubKeyFromBase58WithSuite rejects the keys of the other suites, which
+// must be checked before the keys are used with a private key.
+func PubKeyFromBase58WithSuite(suite Suite, s string) (kyber.Point, error) {
+	point, err := PubKeyFromBase58(s)
+	if err != nil {
+		return nil, err
+	}
+	if SuiteName(PointSuite(point)) != SuiteName(suite) {
+		return nil, fmt.Errorf("invalid suite %s", SuiteName(PointSuite(point)))
+	}
+	return point, nil
+}
@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	"go.dedis.ch/kyber/v4/share"
+	"go.dedis.ch/kyber/v4/sign/tbls"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+func TestSuiteBLS12381(t *testing.T) {
+	require := require.New(t)
+
+	suite, err := NewSuite(SuiteBLS12381)
+	require.NoError(err)
+	require.Equal(SuiteBLS12381, SuiteName(suite))
+	_, err = NewSuite("secp256k1")
+	require.Error(err)
+	legacy, err := NewSuite("")
+	require.NoError(err)
+	require.Equal(SuiteBN256, SuiteName(legacy))
+
+	priv := suite.Scalar().Pick(random.New())
+	pub := PublicKey(priv)
+	require.Equal(SuiteBLS12381, SuiteName(ScalarSuite(priv)))
+	require.Equal(SuiteBLS12381, SuiteName(PointSuite(pub)))
+	require.Len(PublicKeyBytes(pub), 96)
+
+	msg := []byte("tip-suite-message")
+	sig, err := Sign(priv, msg)
+	require.NoError(err)
+	require.Len(sig, 48)
+	require.NoError(Verify(pub, msg, sig))
+	require.Error(Verify(pub, []byte("tampered"), sig))
+
+	parsed, err := PrivateKeyFromHexWithSuite(suite, hex.EncodeToString(PrivateKeyBytes(priv)))
+	require.NoError(err)
+	require.True(priv.Equal(parsed))
+
+	ps := PublicKeyString(pub)
+	point, err := PubKeyFromBase58(ps)
+	require.NoError(err)
+	require.True(pub.Equal(point))
+	point, err = PubKeyFromBytes(PublicKeyBytes(pub))
+	require.NoError(err)
+	require.True(pub.Equal(point))
+	_, err = PubKeyFromBase58WithSuite(suite, ps)
+	require.NoError(err)
+	_, err = PubKeyFromBase58WithSuite(bn256.NewSuiteG2(), ps)
+	require.Error(err)
+
+	other := bn256.NewSuiteG2().Scalar().Pick(random.New())
+	_, err = PubKeyFromBase58WithSuite(suite, PublicKeyString(PublicKey(other)))
+	require.Error(err)
+	require.Error(Verify(PublicKey(other), msg, sig))
+
+	// the ECDH of the keys of different suites never has a secret
+	require.Nil(ecdh(PublicKey(other), priv))
+	cipher, err := EncryptECDH(pub, priv, msg)
+	require.NoError(err)
+	require.Nil(DecryptECDH(PublicKey(other), priv, cipher))
+	_, err = EncryptECDH(PublicKey(other), priv, msg)
+	require.ErrorContains(err, "invalid suite bn256 for bls12381")
+	_, err = EncryptECDHWithAD(PublicKey(other), priv, DirectionRequest, CipherBound, msg)
+	require.ErrorContains(err, "invalid suite")
+	_, err = EncryptECDHWithAD(pub, priv, DirectionRequest, CipherSession+1, msg)
+	require.ErrorContains(err, "invalid cipher version")
+	peer := suite.Scalar().Pick(random.New())
+	cipher, err = EncryptECDHWithAD(PublicKey(peer), priv, DirectionRequest, CipherBound, msg)
+	require.NoError(err)
+	require.Equal(msg, DecryptECDHWithAD(pub, peer, DirectionRequest, CipherBound, cipher))
+}
+
+func TestSuiteBLS12381Threshold(t *testing.T) {
+	require := require.New(t)
+
+	suite, err := NewSuite(SuiteBLS12381)
+	require.NoError(err)
+	n, threshold := uint32(4), uint32(3)
+	poly := share.NewPriPoly(suite, threshold, nil, random.New())
+	pub := poly.Commit(suite.Point().Base())
+	_, commits := pub.Info()
+
+	msg := []byte("tip-suite-threshold")
+	scheme := tbls.NewThresholdSchemeOnG1(suite)
+	var partials [][]byte
+	for _, s := range poly.Shares(n)[:threshold] {
+		partial, err := scheme.Sign(s, msg)
+		require.NoError(err)
+		require.Len(partial, 2+suite.G1().PointLen())
+		partials = append(partials, partial)
+	}
+	sig, err := scheme.Recover(share.NewPubPoly(suite, suite.Point().Base(), commits), msg, partials, threshold, n)
+	require.NoError(err)
+	require.NoError(Verify(pub.Commit(), msg, sig))
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/MixinNetwork/tip/crypto"
 	"github.com/MixinNetwork/tip/logger"
+	"github.com/MixinNetwork/tip/metrics"
 	"github.com/MixinNetwork/tip/store"
 	"go.dedis.ch/kyber/v4"
 )
@@ -30,39 +31,66 @@ type Response struct {
 	Identity  kyber.Point
 	Assignor  []byte
 	Watcher   []byte
+	Version   int
+
+	// the response is encrypted to the sender key of the request cipher
+	Receiver kyber.Point
 }
 
-func Guard(store store.Storage, priv kyber.Scalar, identity, signature, data string) (*Response, error) {
+// Guard returns an error for invalid requests and storage failures, or a
+// response without assignor if the request is rejected by the throttle.
+// The data is decrypted with the cipher version chosen by the client, and
+// the session key is required by the session cipher.
+func Guard(store store.Storage, priv kyber.Scalar, identity, signature, data string, cipher int, session string) (res *Response, err error) {
+	var reason string
+	defer func() {
+		if err != nil {
+			metrics.GuardRejection("error")
+		} else if reason != "" {
+			metrics.GuardRejection(reason)
+		}
+	}()
+
 	b, err := base64.RawURLEncoding.DecodeString(data)
 	if err != nil || len(b) == 0 {
 		return nil, fmt.Errorf("invalid data %s", data)
 	}
-	pub, err := crypto.PubKeyFromBase58(identity)
+	// the keys of another suite are rejected before the ECDH
+	suite := crypto.ScalarSuite(priv)
+	pub, err := crypto.PubKeyFromBase58WithSuite(suite, identity)
 	if err != nil {
 		return nil, fmt.Errorf("invalid identity %s", identity)
 	}
-	b = crypto.DecryptECDH(pub, priv, b)
-
-	var body body
-	err = json.Unmarshal(b, &body)
+	sender, err := checkSession(suite, pub, cipher, session)
+	if err != nil {
+		return nil, err
+	}
+	plain := crypto.NewSecret(crypto.DecryptECDHWithAD(sender, priv, crypto.DirectionRequest, cipher, b))
+	body, err := parseBody(plain)
 	if err != nil {
-		return nil, fmt.Errorf("invalid json %s", string(b))
+		return nil, err
 	}
 	if body.Identity != identity {
 		return nil, fmt.Errorf("invalid identity %s", identity)
 	}
+	err = crypto.CheckSignatureVersion(body.Version)
+	if err != nil {
+		return nil, err
+	}
 	var ab []byte
 	if len(body.Assignee) > 0 {
-		ab, err = checkAssignee(body.Assignee)
+		ab, err = checkAssignee(suite, body.Assignee, body.Version)
 		if err != nil {
 			return nil, err
 		}
 	}
 	eb, valid := new(big.Int).SetString(body.Ephemeral, 16)
+	defer crypto.WipeInt(eb)
 	if !valid || len(eb.Bytes()) > 32 || eb.Sign() <= 0 {
-		return nil, fmt.Errorf("invalid ephemeral %s", body.Ephemeral)
+		return nil, fmt.Errorf("invalid ephemeral")
 	}
 	rb, _ := new(big.Int).SetString(body.Rotate, 16)
+	defer crypto.WipeInt(rb)
 	sig, err := hex.DecodeString(signature)
 	if err != nil {
 		return nil, fmt.Errorf("invalid signature %s", signature)
@@ -72,6 +100,7 @@ func Guard(store store.Storage, priv kyber.Scalar, identity, signature, data str
 	if err != nil {
 		return nil, err
 	} else if pb := crypto.PublicKeyBytes(pub); assignee != nil && !bytes.Equal(assignee, pb) {
+		reason = "assignee"
 		lkey := append(pb, "SECRET"...)
 		available, err := store.CheckLimit(lkey, SecretLimitWindow, SecretLimitQuota, true)
 		logger.Debug("keeper.CheckLimit", "ASSIGNEE", true, hex.EncodeToString(assignee), hex.EncodeToString(pb), available, err)
@@ -95,6 +124,7 @@ func Guard(store store.Storage, priv kyber.Scalar, identity, signature, data str
 		return nil, fmt.Errorf("watch %x error %v", watcher, err)
 	}
 	if oas != nil && !bytes.Equal(oas, assignor) {
+		reason = "watcher"
 		lkey := append(oas, "SECRET"...)
 		available, err := store.CheckLimit(lkey, SecretLimitWindow, SecretLimitQuota, true)
 		logger.Debug("keeper.CheckLimit", "WATCHER", true, hex.EncodeToString(oas), hex.EncodeToString(assignor), available, err)
@@ -105,6 +135,9 @@ func Guard(store store.Storage, priv kyber.Scalar, identity, signature, data str
 	available, err := store.CheckLimit(lkey, EphemeralLimitWindow, EphemeralLimitQuota, false)
 	if err != nil || available < 1 {
 		logger.Debug("keeper.CheckLimit", "EPHEMERAL", false, hex.EncodeToString(assignor), available, err)
+		if reason = "quota"; err == nil {
+			metrics.QuotaExhausted("EPHEMERAL")
+		}
 		return &Response{Available: available}, err
 	}
 	nonce, grace := uint64(body.Nonce), time.Duration(body.Grace)
@@ -116,6 +149,7 @@ func Guard(store store.Storage, priv kyber.Scalar, identity, signature, data str
 		return nil, err
 	}
 	if !valid {
+		reason = "nonce"
 		available, err = store.CheckLimit(lkey, EphemeralLimitWindow, EphemeralLimitQuota, true)
 		logger.Debug("keeper.CheckLimit", "EPHEMERAL", true, hex.EncodeToString(assignor), available, err)
 		return &Response{Available: available}, err
@@ -134,13 +168,17 @@ func Guard(store store.Storage, priv kyber.Scalar, identity, signature, data str
 	available, err = store.CheckLimit(lkey, SecretLimitWindow, SecretLimitQuota, false)
 	if err != nil || available < 1 {
 		logger.Debug("keeper.CheckLimit", "SECRET", false, hex.EncodeToString(assignor), available, err)
+		if reason = "quota"; err == nil {
+			metrics.QuotaExhausted("SECRET")
+		}
 		return &Response{Available: available}, err
 	}
-	err = checkSignature(pub, sig, eb, rb, nonce, uint64(grace), ab)
+	err = checkSignature(pub, sig, body.Version, eb, rb, nonce, uint64(grace), ab)
 	if err == nil {
 		if len(ab) > 0 {
-			err := store.WriteAssignee(assignor, ab[:128])
-			logger.Debugf("store.WriteAssignee(%x, %x) => %v", assignor, ab[:128], err)
+			ap := ab[:suite.PointLen()]
+			err := store.WriteAssignee(assignor, ap)
+			logger.Debugf("store.WriteAssignee(%x, %x) => %v", assignor, ap, err)
 			if err != nil {
 				return nil, err
 			}
@@ -152,14 +190,29 @@ func Guard(store store.Storage, priv kyber.Scalar, identity, signature, data str
 			Identity:  pub,
 			Assignor:  assignor,
 			Watcher:   watcher,
+			Version:   body.Version,
+			Receiver:  sender,
 		}, nil
 	}
+	reason = "signature"
 	available, err = store.CheckLimit(lkey, SecretLimitWindow, SecretLimitQuota, true)
 	logger.Debug("keeper.CheckLimit", "SECRET", true, hex.EncodeToString(assignor), available, err)
 	return &Response{Available: available}, err
 }
 
-func checkSignature(pub kyber.Point, sig []byte, eb, rb *big.Int, nonce, grace uint64, ab []byte) error {
+// LimitWindowQuota returns the window and quota of the limit kind suffix
+// used in the CheckLimit key, or zero for unknown kind.
+func LimitWindowQuota(kind string) (time.Duration, int) {
+	switch kind {
+	case "EPHEMERAL":
+		return EphemeralLimitWindow, EphemeralLimitQuota
+	case "SECRET":
+		return SecretLimitWindow, SecretLimitQuota
+	}
+	return 0, 0
+}
+
+func checkSignature(pub kyber.Point, sig []byte, version int, eb, rb *big.Int, nonce, grace uint64, ab []byte) error {
 	if len(eb.Bytes()) > 32 || eb.Sign() <= 0 {
 		return fmt.Errorf("invalid ephemeral %x", eb.Bytes())
 	}
@@ -178,22 +231,54 @@ func checkSignature(pub kyber.Point, sig []byte, eb, rb *big.Int, nonce, grace u
 		msg = append(msg, rbuf...)
 	}
 	msg = append(msg, ab...)
-	return crypto.Verify(pub, msg, sig)
+	return crypto.VerifyDomain(pub, crypto.DomainRequest, version, msg, sig)
+}
+
+// parseBody releases the decrypted request after parsing, and the error
+// never has the plain text, which has the ephemeral secret.
+func parseBody(plain *crypto.Secret) (*body, error) {
+	defer plain.Release()
+
+	var b body
+	err := json.Unmarshal(plain.Bytes(), &b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json %v", err)
+	}
+	return &b, nil
 }
 
-func checkAssignee(as string) ([]byte, error) {
+// checkSession returns the sender key of the cipher, which is the session
+// key for the session cipher, otherwise the identity key.
+func checkSession(suite crypto.Suite, pub kyber.Point, cipher int, session string) (kyber.Point, error) {
+	if cipher != crypto.CipherSession {
+		if session != "" {
+			return nil, fmt.Errorf("invalid session %s", session)
+		}
+		return pub, nil
+	}
+	sp, err := crypto.PubKeyFromBase58WithSuite(suite, session)
+	if err != nil || sp.Equal(sp.Clone().Null()) || sp.Equal(pub) {
+		return nil, fmt.Errorf("invalid session %s", session)
+	}
+	return sp, nil
+}
+
+// checkAssignee parses the assignee public key and its signature, which
+// are both of the suite of the signer.
+func checkAssignee(suite crypto.Suite, as string, version int) ([]byte, error) {
 	ab, err := hex.DecodeString(as)
 	if err != nil {
 		return nil, fmt.Errorf("invalid assignee format %s", err)
 	}
-	if len(ab) != 192 {
+	pl := suite.PointLen()
+	if len(ab) != pl+suite.G1().PointLen() {
 		return nil, fmt.Errorf("invalid assignee format %d", len(as))
 	}
-	ap, err := crypto.PubKeyFromBytes(ab[:128])
+	ap, err := crypto.PubKeyFromBytesWithSuite(suite, ab[:pl])
 	if err != nil {
 		return nil, fmt.Errorf("invalid assignee public key %s", err)
 	}
-	return ab, crypto.Verify(ap, ab[:128], ab[128:])
+	return ab, crypto.VerifyDomain(ap, crypto.DomainAssignee, version, ab[:pl], ab[pl:])
 }
 
 type body struct {
@@ -221,4 +306,8 @@ type body struct {
 
 	// the key to watch the identity state
 	Watcher string `json:"watcher"`
+
+	// the signature version of the request, the assignee and the response,
+	// and the legacy clients sign the raw messages without it
+	Version int `json:"version"`
 }
@@ -40,6 +40,7 @@ func newStubStore() *stubStore {
 }
 
 func (*stubStore) CheckPolyGroup([]byte) (bool, error) { return false, nil }
+func (*stubStore) ReadPolyGroup() ([]byte, error)      { return nil, nil }
 func (*stubStore) ReadPolyPublic() ([]byte, error)     { return nil, nil }
 func (*stubStore) ReadPolyShare() ([]byte, error)      { return nil, nil }
 func (*stubStore) WritePoly([]byte, []byte) error      { return nil }
@@ -60,21 +61,24 @@ func (s *stubStore) RotateEphemeralNonce(key, ephemeral []byte, nonce uint64) er
 func (*stubStore) WriteSignRequest([]byte, []byte) (time.Time, int, error) {
 	return time.Time{}, 0, nil
 }
+func (*stubStore) WriteSignAudit([]byte, []byte, uint64, int) error   { return nil }
+func (*stubStore) Ping() error                                        { return nil }
 func (s *stubStore) Watch(key []byte) ([]byte, time.Time, int, error) { return s.watchFn(key) }
 
 func TestCheckAssigneeValidation(t *testing.T) {
 	require := require.New(t)
+	suite := bn256.NewSuiteG2()
 
-	_, err := checkAssignee("zz")
+	_, err := checkAssignee(suite, "zz", crypto.SignatureLegacy)
 	require.Error(err)
 	require.Contains(err.Error(), "invalid assignee format")
 
-	_, err = checkAssignee("abcd")
+	_, err = checkAssignee(suite, "abcd", crypto.SignatureLegacy)
 	require.Error(err)
 	require.Contains(err.Error(), "invalid assignee format")
 
 	invalidKey := append(bytes.Repeat([]byte{1}, 128), bytes.Repeat([]byte{2}, 64)...)
-	_, err = checkAssignee(hex.EncodeToString(invalidKey))
+	_, err = checkAssignee(suite, hex.EncodeToString(invalidKey), crypto.SignatureLegacy)
 	require.Error(err)
 	require.Contains(err.Error(), "invalid assignee public key")
 
@@ -85,12 +89,28 @@ func TestCheckAssigneeValidation(t *testing.T) {
 	require.NoError(err)
 
 	valid := append(append([]byte{}, assignee...), sig...)
-	decoded, err := checkAssignee(hex.EncodeToString(valid))
+	decoded, err := checkAssignee(suite, hex.EncodeToString(valid), crypto.SignatureLegacy)
 	require.NoError(err)
 	require.Equal(valid, decoded)
 
+	_, err = checkAssignee(suite, hex.EncodeToString(valid), crypto.SignatureDomain)
+	require.Error(err)
+	sig, err = crypto.SignDomain(user, crypto.DomainAssignee, crypto.SignatureDomain, assignee)
+	require.NoError(err)
+	domain := append(append([]byte{}, assignee...), sig...)
+	_, err = checkAssignee(suite, hex.EncodeToString(domain), crypto.SignatureDomain)
+	require.NoError(err)
+	_, err = checkAssignee(suite, hex.EncodeToString(domain), crypto.SignatureLegacy)
+	require.Error(err)
+
+	bls, err := crypto.NewSuite(crypto.SuiteBLS12381)
+	require.NoError(err)
+	_, err = checkAssignee(bls, hex.EncodeToString(domain), crypto.SignatureDomain)
+	require.Error(err)
+	require.Contains(err.Error(), "invalid assignee format")
+
 	valid[len(valid)-1] ^= 0xff
-	_, err = checkAssignee(hex.EncodeToString(valid))
+	_, err = checkAssignee(suite, hex.EncodeToString(valid), crypto.SignatureLegacy)
 	require.Error(err)
 }
 
@@ -117,9 +137,15 @@ func TestCheckSignatureValidation(t *testing.T) {
 
 	sig, err := crypto.Sign(user, msg)
 	require.NoError(err)
-	require.NoError(checkSignature(pub, sig, ephemeral, rotation, nonce, grace, assignee))
+	require.NoError(checkSignature(pub, sig, crypto.SignatureLegacy, ephemeral, rotation, nonce, grace, assignee))
+	require.Error(checkSignature(pub, sig, crypto.SignatureDomain, ephemeral, rotation, nonce, grace, assignee))
 
-	err = checkSignature(pub, sig, big.NewInt(0), rotation, nonce, grace, assignee)
+	domain, err := crypto.SignDomain(user, crypto.DomainRequest, crypto.SignatureDomain, msg)
+	require.NoError(err)
+	require.NoError(checkSignature(pub, domain, crypto.SignatureDomain, ephemeral, rotation, nonce, grace, assignee))
+	require.Error(checkSignature(pub, domain, crypto.SignatureLegacy, ephemeral, rotation, nonce, grace, assignee))
+
+	err = checkSignature(pub, sig, crypto.SignatureLegacy, big.NewInt(0), rotation, nonce, grace, assignee)
 	require.Error(err)
 	require.Contains(err.Error(), "invalid ephemeral")
 }
@@ -144,32 +170,32 @@ func TestGuardStoreErrorPaths(t *testing.T) {
 		return true, nil
 	}
 
-	res, err := Guard(store, signer, identity, signature, data)
+	res, err := Guard(store, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.NoError(err)
 	require.NotNil(res)
 	require.Equal(EphemeralGracePeriod, seenGrace)
 
-	_, err = Guard(store, signer, "invalid", signature, data)
+	_, err = Guard(store, signer, "invalid", signature, data, crypto.CipherLegacy, "")
 	require.Error(err)
 	require.Contains(err.Error(), "invalid identity")
 
-	_, err = Guard(store, signer, identity, "not-hex", data)
+	_, err = Guard(store, signer, identity, "not-hex", data, crypto.CipherLegacy, "")
 	require.Error(err)
 	require.Contains(err.Error(), "invalid signature")
 
 	store = newStubStore()
 	store.readAssigneeFn = func([]byte) ([]byte, error) { return nil, fmt.Errorf("read-assignee") }
-	_, err = Guard(store, signer, identity, signature, data)
+	_, err = Guard(store, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.EqualError(err, "read-assignee")
 
 	store = newStubStore()
 	store.readAssignorFn = func([]byte) ([]byte, error) { return nil, fmt.Errorf("read-assignor") }
-	_, err = Guard(store, signer, identity, signature, data)
+	_, err = Guard(store, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.EqualError(err, "read-assignor")
 
 	store = newStubStore()
 	store.watchFn = func([]byte) ([]byte, time.Time, int, error) { return nil, time.Time{}, 0, fmt.Errorf("watch-error") }
-	_, err = Guard(store, signer, identity, signature, data)
+	_, err = Guard(store, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Error(err)
 	require.Contains(err.Error(), "watch")
 
@@ -180,7 +206,7 @@ func TestGuardStoreErrorPaths(t *testing.T) {
 		}
 		return int(quota), nil
 	}
-	res, err = Guard(store, signer, identity, signature, data)
+	res, err = Guard(store, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.EqualError(err, "ephemeral-limit")
 	require.NotNil(res)
 
@@ -188,14 +214,14 @@ func TestGuardStoreErrorPaths(t *testing.T) {
 	store.checkEphemeralNonceFn = func([]byte, []byte, uint64, time.Duration) (bool, error) {
 		return false, fmt.Errorf("nonce-error")
 	}
-	_, err = Guard(store, signer, identity, signature, data)
+	_, err = Guard(store, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.EqualError(err, "nonce-error")
 
 	rotation := bytes.Repeat([]byte{8}, 32)
 	rotSig, rotData := makeTestRequestWithAssigneeAndRotation(user, node, ephmr, rotation, 12, uint64(EphemeralGracePeriod), "", "", hex.EncodeToString(watcher))
 	store = newStubStore()
 	store.rotateEphemeralFn = func([]byte, []byte, uint64) error { return fmt.Errorf("rotate-error") }
-	_, err = Guard(store, signer, identity, rotSig, rotData)
+	_, err = Guard(store, signer, identity, rotSig, rotData, crypto.CipherLegacy, "")
 	require.EqualError(err, "rotate-error")
 
 	assigneeUser := suite.Scalar().Pick(random.New())
@@ -206,6 +232,25 @@ func TestGuardStoreErrorPaths(t *testing.T) {
 	assignSig, assignData := makeTestRequestWithAssigneeAndRotation(user, node, ephmr, nil, 13, uint64(EphemeralGracePeriod), assigneeHex, "", hex.EncodeToString(watcher))
 	store = newStubStore()
 	store.writeAssigneeFn = func([]byte, []byte) error { return fmt.Errorf("write-assignee") }
-	_, err = Guard(store, signer, identity, assignSig, assignData)
+	_, err = Guard(store, signer, identity, assignSig, assignData, crypto.CipherLegacy, "")
 	require.EqualError(err, "write-assignee")
 }
+
+func TestParseBodyRelease(t *testing.T) {
+	require := require.New(t)
+
+	ephemeral := hex.EncodeToString(bytes.Repeat([]byte{3}, 32))
+	plain := []byte(fmt.Sprintf(`{"identity":"identity","ephemeral":"%s","nonce":9}`, ephemeral))
+	body, err := parseBody(crypto.NewSecret(plain))
+	require.NoError(err)
+	require.Equal("identity", body.Identity)
+	require.Equal(ephemeral, body.Ephemeral)
+	require.Equal(uint64(9), uint64(body.Nonce))
+	require.Equal(make([]byte, len(plain)), plain)
+
+	plain = []byte(fmt.Sprintf(`{"ephemeral":"%s"`, ephemeral))
+	_, err = parseBody(crypto.NewSecret(plain))
+	require.ErrorContains(err, "invalid json")
+	require.NotContains(err.Error(), ephemeral)
+	require.Equal(make([]byte, len(plain)), plain)
+}
@@ -26,7 +26,7 @@ func TestGuard(t *testing.T) {
 	require := require.New(t)
 
 	dir, _ := os.MkdirTemp("/tmp", "tip-keeper-test")
-	conf := &store.BadgerConfiguration{Dir: dir}
+	conf := &store.Configuration{Dir: dir}
 	bs, _ := store.OpenBadger(context.Background(), conf)
 	defer bs.Close()
 
@@ -46,7 +46,7 @@ func TestGuard(t *testing.T) {
 	grace := uint64(time.Hour * 24 * 128)
 	for i := range uint64(10) {
 		signature, data := makeTestRequest(user, node, ephmr, nil, 1024+i, grace)
-		res, err := Guard(bs, signer, identity, signature, data)
+		res, err := Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 		require.Nil(err)
 		require.Equal(SecretLimitQuota, res.Available)
 		require.Equal(1024+i, res.Nonce)
@@ -59,18 +59,18 @@ func TestGuard(t *testing.T) {
 
 	// data should be base64 RawURLEncoding and none blank
 	signature, _ := makeTestRequest(user, node, ephmr, nil, 1024, grace)
-	_, err = Guard(bs, signer, identity, signature, "")
+	_, err = Guard(bs, signer, identity, signature, "", crypto.CipherLegacy, "")
 	require.NotNil(err)
 
 	// identity is not equal
 	signature, data := makeTestRequestWithInvalidIdentity(user, node, ephmr, nil, 1039, grace, "", "", "")
-	_, err = Guard(bs, signer, identity, signature, data)
+	_, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid identity ")
 
 	// invalid nonce
 	signature, data = makeTestRequest(user, node, ephmr, nil, 1024, grace)
-	res, err := Guard(bs, signer, identity, signature, data)
+	res, err := Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Equal(EphemeralLimitQuota-1, res.Available)
 	require.Nil(res.Watcher)
@@ -86,7 +86,7 @@ func TestGuard(t *testing.T) {
 
 	// invalid encryption
 	signature, data = makeTestRequest(user, crypto.PublicKey(user), ephmr, nil, 1034, grace)
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(res)
 	require.Contains(err.Error(), "invalid json ")
 	key = crypto.PublicKeyBytes(crypto.PublicKey(user))
@@ -97,12 +97,12 @@ func TestGuard(t *testing.T) {
 
 	// invalid ephemeral
 	signature, data = makeTestRequest(user, node, crypto.PublicKeyBytes(node), nil, 1034, grace)
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid ephemeral")
 	require.Nil(res)
 	signature, data = makeTestRequest(user, node, bytes.Repeat([]byte{1}, 29), nil, 1034, grace)
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Equal(EphemeralLimitQuota-2, res.Available)
 	require.Nil(res.Watcher)
@@ -115,7 +115,7 @@ func TestGuard(t *testing.T) {
 	// invalid signature
 	for i := 1; i < 6; i++ {
 		_, data = makeTestRequest(user, node, ephmr, nil, uint64(1033+i), grace)
-		res, err := Guard(bs, signer, identity, hex.EncodeToString(ephmr), data)
+		res, err := Guard(bs, signer, identity, hex.EncodeToString(ephmr), data, crypto.CipherLegacy, "")
 		require.Nil(err)
 		require.Equal(res.Available, SecretLimitQuota-i)
 		require.Nil(res.Watcher)
@@ -131,7 +131,7 @@ func TestGuard(t *testing.T) {
 	}
 
 	signature, data = makeTestRequestWithAssigneeAndRotation(user, node, ephmr, nil, 1039, grace, "", "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Equal(SecretLimitQuota-5, res.Available)
 	require.Equal(uint64(1039), res.Nonce)
@@ -148,7 +148,7 @@ func TestGuard(t *testing.T) {
 
 	// invalid assignee
 	signature, data = makeTestRequestWithAssigneeAndRotation(user, node, ephmr, nil, 1039, grace, identity, "", "")
-	_, err = Guard(bs, signer, identity, signature, data)
+	_, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid assignee ")
 	// valid assignee
@@ -157,7 +157,7 @@ func TestGuard(t *testing.T) {
 	require.Nil(err)
 	assignee = append(assignee, sig...)
 	signature, data = makeTestRequestWithAssigneeAndRotation(user, node, ephmr, nil, 1039, grace, hex.EncodeToString(assignee), "", "")
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Contains(err.Error(), "invalid watcher ")
 	require.Nil(res)
 	assignee = crypto.PublicKeyBytes(userPub)
@@ -165,7 +165,7 @@ func TestGuard(t *testing.T) {
 	require.Nil(err)
 	assignee = append(assignee, sig...)
 	signature, data = makeTestRequestWithAssigneeAndRotation(user, node, ephmr, nil, 1040, grace, hex.EncodeToString(assignee), "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.NotNil(res)
 	_, counter, err = bs.WriteSignRequest(res.Assignor, res.Watcher)
@@ -190,7 +190,7 @@ func TestGuard(t *testing.T) {
 	require.Nil(err)
 	assignee = append(assignee, sig...)
 	signature, data = makeTestRequestWithAssigneeAndRotation(user, node, ephmr, nil, 1042, grace, hex.EncodeToString(assignee), "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Equal(SecretLimitQuota-5, res.Available)
 	require.NotNil(res.Watcher)
@@ -219,12 +219,12 @@ func TestGuard(t *testing.T) {
 	require.Nil(err)
 	assignee = append(assignee, sig...)
 	signature, data = makeTestRequestWithAssigneeAndRotation(user, node, ephmr, nil, 1045, grace, hex.EncodeToString(assignee), "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.NotNil(res)
 	// test user pin
 	signature, data = makeTestRequestWithAssigneeAndRotation(newUser, node, ephmr, nil, 1046, grace, "", "", hex.EncodeToString(watcherSeed))
-	resNew, err := Guard(bs, signer, newIdentity, signature, data)
+	resNew, err := Guard(bs, signer, newIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.NotNil(resNew)
 	require.Equal(res.Assignor, resNew.Assignor)
@@ -239,7 +239,7 @@ func TestGuard(t *testing.T) {
 	require.Equal(3, counter)
 	// test user old pin
 	signature, data = makeTestRequestWithAssigneeAndRotation(user, node, ephmr, nil, 1047, grace, "", "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, identity, signature, data)
+	res, err = Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Nil(res.Watcher)
 	require.Equal(SecretLimitQuota-6, res.Available)
@@ -248,7 +248,7 @@ func TestGuard(t *testing.T) {
 	invalidUserPub := crypto.PublicKey(invalidUser)
 	invalidIdentity := crypto.PublicKeyString(invalidUserPub)
 	signature, data = makeTestRequestWithAssigneeAndRotation(invalidUser, node, ephmr, nil, 1047, grace, "", "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, invalidIdentity, signature, data)
+	res, err = Guard(bs, signer, invalidIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Nil(res.Watcher)
 	require.Equal(SecretLimitQuota-7, res.Available)
@@ -257,17 +257,17 @@ func TestGuard(t *testing.T) {
 	require.Equal(3, counter)
 	require.Equal(oas, crypto.PublicKeyBytes(userPub))
 	signature, data = makeTestRequestWithAssigneeAndRotation(newUser, node, ephmr, nil, 1048, grace, "", "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, newIdentity, signature, data)
+	res, err = Guard(bs, signer, newIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.NotNil(res.Watcher)
 	require.Equal(SecretLimitQuota-7, res.Available)
 	signature, data = makeTestRequestWithAssigneeAndRotation(invalidUser, node, ephmr, nil, 1050, grace, "", "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, invalidIdentity, signature, data)
+	res, err = Guard(bs, signer, invalidIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Nil(res.Watcher)
 	require.Equal(SecretLimitQuota-8, res.Available)
 	signature, data = makeTestRequestWithAssigneeAndRotation(newUser, node, ephmr, nil, 1051, grace, "", "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, newIdentity, signature, data)
+	res, err = Guard(bs, signer, newIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.NotNil(res.Watcher)
 	require.Equal(SecretLimitQuota-8, res.Available)
@@ -283,12 +283,12 @@ func TestGuard(t *testing.T) {
 	liPub := crypto.PublicKey(li)
 	liIdentity := crypto.PublicKeyString(liPub)
 	signature, data = makeTestRequestWithAssigneeAndRotation(li, node, ephmr, nil, 100, grace, "", "", hex.EncodeToString(liWatcher))
-	res, err = Guard(bs, signer, liIdentity, signature, data)
+	res, err = Guard(bs, signer, liIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.NotNil(res)
 	// update li' pin with wrong assignee
 	signature, data = makeTestRequestWithAssigneeAndRotation(li, node, ephmr, nil, 105, grace, hex.EncodeToString(assignee), "", hex.EncodeToString(liWatcher))
-	_, err = Guard(bs, signer, liIdentity, signature, data)
+	_, err = Guard(bs, signer, liIdentity, signature, data, crypto.CipherLegacy, "")
 	require.NotNil(err)
 	require.Contains(err.Error(), "invalid assignor as is assignee")
 	// update li pin
@@ -300,23 +300,23 @@ func TestGuard(t *testing.T) {
 	require.Nil(err)
 	assignee = append(assignee, sig...)
 	signature, data = makeTestRequestWithAssigneeAndRotation(li, node, ephmr, nil, 110, grace, hex.EncodeToString(assignee), "", hex.EncodeToString(watcherSeed))
-	res, err = Guard(bs, signer, liIdentity, signature, data)
+	res, err = Guard(bs, signer, liIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.NotNil(res)
 	// test li pin
 	signature, data = makeTestRequestWithAssigneeAndRotation(liNew, node, ephmr, nil, 115, grace, "", "", hex.EncodeToString(liWatcher))
-	res, err = Guard(bs, signer, liNewIdentity, signature, data)
+	res, err = Guard(bs, signer, liNewIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.NotNil(res)
 	// pin should have watcher
 	signature, data = makeTestRequestWithAssigneeAndRotation(liNew, node, ephmr, nil, 117, grace, "", "", "")
-	res, err = Guard(bs, signer, liNewIdentity, signature, data)
+	res, err = Guard(bs, signer, liNewIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Contains(err.Error(), "invalid watcher ")
 	require.Nil(res)
 	// invalid ephmr
 	ephmr = crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
 	signature, data = makeTestRequestWithAssigneeAndRotation(liNew, node, ephmr, nil, 119, grace, "", "", hex.EncodeToString(liWatcher))
-	res, err = Guard(bs, signer, liNewIdentity, signature, data)
+	res, err = Guard(bs, signer, liNewIdentity, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Equal(EphemeralLimitQuota-1, res.Available)
 	require.Nil(res.Watcher)
@@ -326,7 +326,7 @@ func TestAssigneeAndRotation(t *testing.T) {
 	require := require.New(t)
 
 	dir, _ := os.MkdirTemp("/tmp", "tip-keeper-test")
-	conf := &store.BadgerConfiguration{Dir: dir}
+	conf := &store.Configuration{Dir: dir}
 	bs, _ := store.OpenBadger(context.Background(), conf)
 	defer bs.Close()
 
@@ -344,7 +344,7 @@ func TestAssigneeAndRotation(t *testing.T) {
 	ephmr := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
 	grace := uint64(time.Hour * 24 * 128)
 	signature, data := makeTestRequest(u1, node, ephmr, nil, 1024, grace)
-	res, err := Guard(bs, signer, i1, signature, data)
+	res, err := Guard(bs, signer, i1, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Equal(SecretLimitQuota, res.Available)
 	require.Equal(1024, int(res.Nonce))
@@ -352,7 +352,7 @@ func TestAssigneeAndRotation(t *testing.T) {
 	ephmr = crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
 	grace = uint64(time.Hour * 24 * 128)
 	signature, data = makeTestRequest(u2, node, ephmr, nil, 1024, grace)
-	res, err = Guard(bs, signer, i2, signature, data)
+	res, err = Guard(bs, signer, i2, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Equal(SecretLimitQuota, res.Available)
 	require.Equal(1024, int(res.Nonce))
@@ -360,12 +360,76 @@ func TestAssigneeAndRotation(t *testing.T) {
 	ephmr = crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
 	grace = uint64(time.Hour * 24 * 128)
 	signature, data = makeTestRequest(u3, node, ephmr, nil, 1024, grace)
-	res, err = Guard(bs, signer, i3, signature, data)
+	res, err = Guard(bs, signer, i3, signature, data, crypto.CipherLegacy, "")
 	require.Nil(err)
 	require.Equal(SecretLimitQuota, res.Available)
 	require.Equal(1024, int(res.Nonce))
 }
 
+func TestGuardCipherVersion(t *testing.T) {
+	require := require.New(t)
+
+	dir, _ := os.MkdirTemp("/tmp", "tip-keeper-test")
+	conf := &store.Configuration{Dir: dir}
+	bs, _ := store.OpenBadger(context.Background(), conf)
+	defer bs.Close()
+
+	suite := bn256.NewSuiteBn256()
+	signer := suite.Scalar().Pick(random.New())
+	node := crypto.PublicKey(signer)
+	user := suite.Scalar().Pick(random.New())
+	identity := crypto.PublicKeyString(crypto.PublicKey(user))
+	ephmr := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
+
+	signature, data := makeTestRequest(user, node, ephmr, nil, 1024, uint64(EphemeralGracePeriod))
+	legacy, _ := base64.RawURLEncoding.DecodeString(data)
+	plain := crypto.DecryptECDH(node, user, legacy)
+	bound, err := crypto.EncryptECDHWithAD(node, user, crypto.DirectionRequest, crypto.CipherBound, plain)
+	require.NoError(err)
+	reply, err := crypto.EncryptECDHWithAD(node, user, crypto.DirectionResponse, crypto.CipherBound, plain)
+	require.NoError(err)
+
+	_, err = Guard(bs, signer, identity, signature, data, crypto.CipherBound, "")
+	require.ErrorContains(err, "invalid json")
+	_, err = Guard(bs, signer, identity, signature, base64.RawURLEncoding.EncodeToString(bound), crypto.CipherLegacy, "")
+	require.ErrorContains(err, "invalid json")
+	_, err = Guard(bs, signer, identity, signature, base64.RawURLEncoding.EncodeToString(reply), crypto.CipherBound, "")
+	require.ErrorContains(err, "invalid json")
+	_, err = Guard(bs, signer, identity, signature, base64.RawURLEncoding.EncodeToString(bound), 3, "")
+	require.ErrorContains(err, "invalid json")
+	_, err = Guard(bs, signer, identity, signature, base64.RawURLEncoding.EncodeToString(bound), crypto.CipherSession, "")
+	require.ErrorContains(err, "invalid session")
+	_, err = Guard(bs, signer, identity, signature, base64.RawURLEncoding.EncodeToString(bound), crypto.CipherBound, identity)
+	require.ErrorContains(err, "invalid session")
+	_, err = Guard(bs, signer, identity, signature, base64.RawURLEncoding.EncodeToString(bound), crypto.CipherSession, identity)
+	require.ErrorContains(err, "invalid session")
+
+	// the session cipher is made by the one-time key, and the response is
+	// encrypted to it
+	session := suite.Scalar().Pick(random.New())
+	sessionId := crypto.PublicKeyString(crypto.PublicKey(session))
+	sc, err := crypto.EncryptECDHWithAD(node, session, crypto.DirectionRequest, crypto.CipherSession, plain)
+	require.NoError(err)
+	sessioned := base64.RawURLEncoding.EncodeToString(sc)
+	_, err = Guard(bs, signer, identity, signature, base64.RawURLEncoding.EncodeToString(bound), crypto.CipherSession, sessionId)
+	require.ErrorContains(err, "invalid json")
+	_, err = Guard(bs, signer, identity, signature, sessioned, crypto.CipherBound, "")
+	require.ErrorContains(err, "invalid json")
+	res, err := Guard(bs, signer, identity, signature, sessioned, crypto.CipherSession, sessionId)
+	require.NoError(err)
+	require.True(res.Receiver.Equal(crypto.PublicKey(session)))
+
+	signature, data = makeTestRequest(user, node, ephmr, nil, 1025, uint64(EphemeralGracePeriod))
+	legacy, _ = base64.RawURLEncoding.DecodeString(data)
+	plain = crypto.DecryptECDH(node, user, legacy)
+	bound, err = crypto.EncryptECDHWithAD(node, user, crypto.DirectionRequest, crypto.CipherBound, plain)
+	require.NoError(err)
+	res, err = Guard(bs, signer, identity, signature, base64.RawURLEncoding.EncodeToString(bound), crypto.CipherBound, "")
+	require.NoError(err)
+	require.Equal(1025, int(res.Nonce))
+	require.True(res.Receiver.Equal(crypto.PublicKey(user)))
+}
+
 func makeTestRequest(user kyber.Scalar, signer kyber.Point, ephmr, rtt []byte, nonce, grace uint64) (string, string) {
 	seed := make([]byte, 32)
 	_, err := rand.Read(seed)
@@ -402,7 +466,7 @@ func makeTestRequestWithAssigneeAndRotation(user kyber.Scalar, signer kyber.Poin
 		data["assignee"] = assignee
 	}
 	b, _ := json.Marshal(data)
-	cipher := crypto.EncryptECDH(signer, user, b)
+	cipher, _ := crypto.EncryptECDH(signer, user, b)
 	sig, _ := crypto.Sign(user, msg)
 	return hex.EncodeToString(sig), base64.RawURLEncoding.EncodeToString(cipher[:])
 }
@@ -432,7 +496,7 @@ func makeTestRequestWithInvalidIdentity(user kyber.Scalar, signer kyber.Point, e
 		data["rotate"] = hex.EncodeToString(rtt)
 	}
 	b, _ := json.Marshal(data)
-	cipher := crypto.EncryptECDH(signer, user, b)
+	cipher, _ := crypto.EncryptECDH(signer, user, b)
 	sig, _ := crypto.Sign(user, msg)
 	return hex.EncodeToString(sig), base64.RawURLEncoding.EncodeToString(cipher[:])
 }
@@ -0,0 +1,140 @@
+package keeper
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/store"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4/pairing/bn256"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+type simulationClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+func (c *simulationClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(time.Microsecond)
+	return c.now
+}
+
+func (c *simulationClock) Sleep(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestGuardSimulation replays about a year of requests from a user and an
+// attacker against the same identity, with the clock injected to the store.
+func TestGuardSimulation(t *testing.T) {
+	clock := &simulationClock{now: time.Unix(1700000000, 0)}
+	conf := &store.Configuration{Dir: t.TempDir(), Clock: clock}
+	bs, err := store.OpenBadger(context.Background(), conf)
+	require.NoError(t, err)
+	defer bs.Close()
+
+	t.Run("Badger", func(t *testing.T) {
+		testGuardSimulation(t, bs, clock)
+	})
+	t.Run("Bolt", func(t *testing.T) {
+		clock := &simulationClock{now: time.Unix(1700000000, 0)}
+		conf := &store.Configuration{Dir: t.TempDir(), Clock: clock}
+		bs, err := store.OpenBolt(context.Background(), conf)
+		require.NoError(t, err)
+		defer bs.Close()
+		testGuardSimulation(t, bs, clock)
+	})
+	t.Run("Memory", func(t *testing.T) {
+		clock := &simulationClock{now: time.Unix(1700000000, 0)}
+		testGuardSimulation(t, store.NewMemoryStorage(clock), clock)
+	})
+}
+
+func testGuardSimulation(t *testing.T, bs store.Storage, clock *simulationClock) {
+	require := require.New(t)
+
+	suite := bn256.NewSuiteBn256()
+	signer := suite.Scalar().Pick(random.New())
+	node := crypto.PublicKey(signer)
+	user := suite.Scalar().Pick(random.New())
+	identity := crypto.PublicKeyString(crypto.PublicKey(user))
+	ephmr := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
+	grace := uint64(EphemeralGracePeriod)
+	nonce := uint64(1024)
+
+	request := func(ephmr []byte, valid bool) *Response {
+		nonce++
+		signature, data := makeTestRequest(user, node, ephmr, nil, nonce, grace)
+		if !valid {
+			signature = hex.EncodeToString(ephmr)
+		}
+		res, err := Guard(bs, signer, identity, signature, data, crypto.CipherLegacy, "")
+		require.NoError(err)
+		return res
+	}
+
+	// the user requests daily for 200 days, and the attacker tries some
+	// invalid signatures every 10 days, which are recovered in a week
+	for day := range 200 {
+		if day%10 == 5 {
+			for i := range 3 {
+				res := request(ephmr, false)
+				require.Equal(SecretLimitQuota-i-1, res.Available)
+			}
+		}
+		res := request(ephmr, true)
+		require.Equal(uint64(nonce), res.Nonce)
+		if day >= 5 && (day-5)%10 < 7 {
+			require.Equal(SecretLimitQuota-3, res.Available)
+		} else {
+			require.Equal(SecretLimitQuota, res.Available, day)
+		}
+		clock.Sleep(time.Hour * 24)
+	}
+
+	// the attacker exhausts the secret quota, the user is locked for a week
+	clock.Sleep(SecretLimitWindow)
+	for i := range SecretLimitQuota {
+		res := request(ephmr, false)
+		require.Equal(SecretLimitQuota-i-1, res.Available)
+	}
+	res := request(ephmr, true)
+	require.Equal(0, res.Available)
+	clock.Sleep(SecretLimitWindow + time.Hour)
+	res = request(ephmr, true)
+	require.Equal(SecretLimitQuota, res.Available)
+
+	// the user lost the device, and a new ephemeral is rejected during the
+	// grace period, while it's accepted after the grace period expired
+	lost := crypto.PrivateKeyBytes(suite.Scalar().Pick(random.New()))
+	clock.Sleep(EphemeralGracePeriod - time.Hour*24*10)
+	res = request(lost, true)
+	require.Equal(EphemeralLimitQuota-1, res.Available)
+	require.Nil(res.Assignor)
+	for i := 1; i < EphemeralLimitQuota; i++ {
+		res = request(lost, true)
+		require.Equal(EphemeralLimitQuota-i-1, res.Available)
+	}
+	res = request(ephmr, true)
+	require.Equal(0, res.Available)
+	clock.Sleep(EphemeralLimitWindow + time.Hour)
+	res = request(lost, true)
+	require.Equal(EphemeralLimitQuota-1, res.Available)
+	require.Nil(res.Assignor)
+
+	clock.Sleep(time.Hour * 24 * 10)
+	res = request(lost, true)
+	require.Equal(SecretLimitQuota, res.Available)
+	require.NotNil(res.Assignor)
+	res = request(ephmr, true)
+	require.Equal(EphemeralLimitQuota-1, res.Available)
+	require.Nil(res.Assignor)
+}
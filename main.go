@@ -1,23 +1,29 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/MixinNetwork/tip/api"
 	"github.com/MixinNetwork/tip/config"
 	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/crypto/encoding"
+	"github.com/MixinNetwork/tip/logger"
 	"github.com/MixinNetwork/tip/messenger"
+	"github.com/MixinNetwork/tip/metrics"
 	tip "github.com/MixinNetwork/tip/sdk/go"
 	"github.com/MixinNetwork/tip/signer"
 	"github.com/MixinNetwork/tip/store"
 	"github.com/fox-one/mixin-sdk-go/v3"
 	"github.com/urfave/cli/v2"
-	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/sign/bdn"
 	"go.dedis.ch/kyber/v4/util/random"
 )
@@ -57,12 +63,154 @@ func main() {
 				Name:   "key",
 				Usage:  "Generate a key pair",
 				Action: genKey,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "suite",
+						Value: crypto.SuiteBN256,
+						Usage: "The pairing suite, bn256 or bls12381",
+					},
+				},
 			},
 			{
 				Name:   "api",
 				Usage:  "Run the api node",
 				Action: runAPI,
 			},
+			{
+				Name:  "store",
+				Usage: "Manage the signer database",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "migrate",
+						Usage:  "Migrate the database schema to the latest version",
+						Action: migrateStore,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Only print the pending migrations",
+							},
+						},
+					},
+					{
+						Name:   "backup",
+						Usage:  "Backup a consistent snapshot of the database",
+						Action: backupStore,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "output",
+								Usage:    "The backup file path",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "key",
+								Usage: "The operator public key to encrypt the backup",
+							},
+						},
+					},
+					{
+						Name:   "restore",
+						Usage:  "Restore the database from a backup",
+						Action: restoreStore,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "input",
+								Usage:    "The backup file path",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "key",
+								Usage: "The operator private key to decrypt the backup",
+							},
+						},
+					},
+					{
+						Name:   "serve",
+						Usage:  "Serve the database to the api replicas of the remote engine",
+						Action: serveStore,
+					},
+					{
+						Name:   "convert",
+						Usage:  "Copy all records to an empty database of another engine",
+						Action: convertStore,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "engine",
+								Usage:    "The target engine, badger or bolt",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "dir",
+								Usage:    "The target database directory",
+								Required: true,
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "admin",
+				Usage: "Inspect and manage the identity states",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "identity",
+						Usage:     "Print the identity state",
+						ArgsUsage: "<pubkey-or-watcher>",
+						Action:    inspectIdentity,
+					},
+					{
+						Name:      "reset-limit",
+						Usage:     "Reset the identity limit window",
+						ArgsUsage: "<pubkey-or-watcher>",
+						Action:    resetIdentityLimit,
+						Flags: append(adminActionFlags(), &cli.StringFlag{
+							Name:     "kind",
+							Usage:    "The limit kind, EPHEMERAL or SECRET",
+							Required: true,
+						}),
+					},
+					{
+						Name:      "clear-nonce",
+						Usage:     "Clear the identity ephemeral and nonce",
+						ArgsUsage: "<pubkey-or-watcher>",
+						Action:    clearIdentityNonce,
+						Flags:     adminActionFlags(),
+					},
+					{
+						Name:   "log",
+						Usage:  "Print all admin actions",
+						Action: listAdminActions,
+					},
+				},
+			},
+			{
+				Name:  "audit",
+				Usage: "Verify and export the sign audit log",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "verify",
+						Usage:  "Verify the hash chain of the sign audit log",
+						Action: verifySignAudits,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "input",
+								Usage: "Verify an exported file instead of the database",
+							},
+						},
+					},
+					{
+						Name:   "export",
+						Usage:  "Export the sign audit log as JSON lines",
+						Action: exportSignAudits,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "output",
+								Usage:    "The export file path",
+								Required: true,
+							},
+						},
+					},
+				},
+			},
 			{
 				Name:   "sign",
 				Usage:  "Request a signature",
@@ -107,8 +255,14 @@ func main() {
 	}
 }
 
+// shutdownTimeout bounds the drain of the connections and messages, and
+// the storage close after a signal, then the process exits anyway.
+const shutdownTimeout = 30 * time.Second
+
 func runSigner(c *cli.Context) error {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	cp := c.String("config")
@@ -117,10 +271,12 @@ func runSigner(c *cli.Context) error {
 		return err
 	}
 
-	store, err := store.OpenBadger(ctx, conf.Store)
+	db, err := store.Open(ctx, conf.Store)
 	if err != nil {
 		return err
 	}
+	store := store.Instrument(db)
+	metrics.Serve(ctx, conf.Metrics)
 
 	messenger, err := messenger.NewMixinMessenger(ctx, conf.Messenger)
 	if err != nil {
@@ -128,11 +284,24 @@ func runSigner(c *cli.Context) error {
 	}
 
 	node := signer.NewNode(ctx, cancel, store, messenger, conf.Node)
-	return node.Run(ctx)
+	defer node.Release()
+	err = node.Run(ctx)
+	cancel()
+
+	sctx, scancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer scancel()
+	if ferr := messenger.Flush(sctx); ferr != nil {
+		logger.Error("messenger.Flush", ferr)
+	}
+	if cerr := closeStorage(sctx, store); cerr != nil {
+		logger.Error("store.Close", cerr)
+	}
+	return err
 }
 
 func runAPI(c *cli.Context) error {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	cp := c.String("config")
 	conf, err := config.ReadConfiguration(cp)
@@ -140,20 +309,342 @@ func runAPI(c *cli.Context) error {
 		return err
 	}
 
-	store, err := store.OpenBadger(ctx, conf.Store)
+	conf.Store.AuditKey, err = signAuditKey(conf)
+	if err != nil {
+		return err
+	}
+	db, err := store.Open(ctx, conf.Store)
 	if err != nil {
 		return err
 	}
+	store := store.Instrument(db)
+	// the storage is closed after the servers stopped, or any early error
+	defer func() {
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if cerr := closeStorage(sctx, store); cerr != nil {
+			logger.Error("store.Close", cerr)
+		}
+	}()
+	metrics.Serve(ctx, conf.Metrics)
 
 	node := signer.NewNode(ctx, nil, store, nil, conf.Node)
+	defer node.Release()
 
 	ac := conf.API
 	ac.Key = node.GetKey()
 	ac.Signers = node.GetSigners()
 	ac.Poly = node.GetPoly()
 	ac.Share = node.GetShare()
-	server := api.NewServer(store, ac)
-	return server.ListenAndServe()
+	ac.Group = node.GetGroup()
+	certs, err := api.LoadCertificates(ac)
+	if err != nil {
+		return err
+	}
+	listener, err := api.Listen(ac, certs)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	if certs != nil {
+		go reloadCertificates(ctx, certs)
+	}
+	gl, err := api.ListenGRPC(ac)
+	if err != nil {
+		return err
+	}
+	if gl != nil {
+		defer gl.Close()
+	}
+	hdr := api.NewHandler(store, ac)
+	server := hdr.HTTPServer()
+	gs := hdr.GRPCServer(certs)
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- server.Serve(listener)
+	}()
+	if gl != nil {
+		go func() {
+			errc <- gs.Serve(gl)
+		}()
+	}
+	select {
+	case err = <-errc:
+		// either server failed, and the other one must not keep serving
+	case <-ctx.Done():
+	}
+
+	// the in-flight sign requests finish their transactions before the node
+	// key released and the storage closed by the deferred calls, the same
+	// for a failed server, which may still have the other one signing
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(stopped)
+	}()
+	serr := server.Shutdown(sctx)
+	if serr != nil {
+		logger.Error("server.Shutdown", serr)
+	}
+	select {
+	case <-stopped:
+	case <-sctx.Done():
+		gs.Stop()
+	}
+	serr = hdr.Drain(sctx)
+	if serr != nil {
+		logger.Error("api.Drain", serr)
+	}
+	return err
+}
+
+func reloadCertificates(ctx context.Context, certs *api.Certificates) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-hup:
+			err := certs.Reload()
+			logger.Info("api.Certificates.Reload", err)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeStorage returns when the storage is closed or the ctx is done, the
+// badger close may wait for the compaction.
+func closeStorage(ctx context.Context, db store.Database) error {
+	done := make(chan struct{})
+	go func() {
+		db.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("store close %w", ctx.Err())
+	}
+}
+
+func migrateStore(c *cli.Context) error {
+	ctx := context.Background()
+
+	cp := c.String("config")
+	conf, err := config.ReadConfiguration(cp)
+	if err != nil {
+		return err
+	}
+
+	err = requireBadger(conf.Store)
+	if err != nil {
+		return err
+	}
+
+	dryRun := c.Bool("dry-run")
+	current, pending, err := store.MigrateBadger(ctx, conf.Store, dryRun)
+	if err != nil {
+		return err
+	}
+	for _, m := range pending {
+		fmt.Println(m.Version, m.Name)
+	}
+	if dryRun {
+		fmt.Printf("schema version %d, %d migrations pending\n", current, len(pending))
+	} else {
+		fmt.Printf("schema version %d, %d migrations applied\n", current, len(pending))
+	}
+	return nil
+}
+
+func backupStore(c *cli.Context) error {
+	ctx := context.Background()
+
+	cp := c.String("config")
+	conf, err := config.ReadConfiguration(cp)
+	if err != nil {
+		return err
+	}
+
+	admin, close, err := openAdmin(ctx, conf.Store, true)
+	if err != nil {
+		return err
+	}
+	defer close()
+
+	f, err := os.OpenFile(c.String("output"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = writeBackup(f, admin, c.String("key"), conf.Node.Suite)
+	if err != nil {
+		f.Close()
+		os.Remove(c.String("output"))
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	fmt.Println(c.String("output"), info.Size())
+	return nil
+}
+
+// writeBackup streams the snapshot to the file, and never holds the whole
+// plain database in memory, encrypted by the operator key if given.
+func writeBackup(f *os.File, admin store.Admin, key, suiteName string) error {
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	var ew io.WriteCloser
+	if key != "" {
+		// the operator key is of the network suite, the same as the restore
+		suite, err := crypto.NewSuite(suiteName)
+		if err != nil {
+			return err
+		}
+		pub, err := encoding.ParsePublicKeyWithSuite(suite, key)
+		if err != nil {
+			return err
+		}
+		ephemeral := suite.Scalar().Pick(random.New())
+		defer crypto.WipeScalar(ephemeral)
+		ew, err = crypto.NewECDHWriter(bw, pub, ephemeral)
+		if err != nil {
+			return err
+		}
+		_, err = bw.Write(crypto.PublicKeyBytes(crypto.PublicKey(ephemeral)))
+		if err != nil {
+			return err
+		}
+		w = ew
+	}
+	_, err := admin.Backup(w)
+	if err != nil {
+		return err
+	}
+	if ew != nil {
+		err = ew.Close()
+		if err != nil {
+			return err
+		}
+	}
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func restoreStore(c *cli.Context) error {
+	ctx := context.Background()
+
+	cp := c.String("config")
+	conf, err := config.ReadConfiguration(cp)
+	if err != nil {
+		return err
+	}
+	err = requireBadger(conf.Store)
+	if err != nil {
+		return err
+	}
+	group, err := signer.GroupHash(conf.Node)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(c.String("input"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if k := c.String("key"); k != "" {
+		suite, err := crypto.NewSuite(conf.Node.Suite)
+		if err != nil {
+			return err
+		}
+		priv, err := encoding.ParsePrivateKey(suite, k)
+		if err != nil {
+			return err
+		}
+		defer crypto.WipeScalar(priv)
+		b := make([]byte, suite.PointLen())
+		_, err = io.ReadFull(r, b)
+		if err != nil {
+			return fmt.Errorf("invalid backup header %v", err)
+		}
+		pub, err := crypto.PubKeyFromBytesWithSuite(suite, b)
+		if err != nil {
+			return err
+		}
+		r, err = crypto.NewECDHReader(r, pub, priv)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = store.RestoreBadger(ctx, conf.Store, r, group)
+	if err != nil {
+		return err
+	}
+	fmt.Println(conf.Store.Dir, hex.EncodeToString(group))
+	return nil
+}
+
+func serveStore(c *cli.Context) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cp := c.String("config")
+	conf, err := config.ReadConfiguration(cp)
+	if err != nil {
+		return err
+	}
+	if conf.Store.Engine == store.EngineRemote {
+		return fmt.Errorf("invalid store engine %s to serve", conf.Store.Engine)
+	}
+
+	conf.Store.AuditKey, err = signAuditKey(conf)
+	if err != nil {
+		return err
+	}
+	db, err := store.Open(ctx, conf.Store)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	server, err := store.NewStorageServer(db, conf.Store)
+	if err != nil {
+		return err
+	}
+	return server.Serve(ctx, conf.Store.RPCSocket)
+}
+
+func convertStore(c *cli.Context) error {
+	ctx := context.Background()
+
+	cp := c.String("config")
+	conf, err := config.ReadConfiguration(cp)
+	if err != nil {
+		return err
+	}
+
+	to := &store.Configuration{Engine: c.String("engine"), Dir: c.String("dir")}
+	count, err := store.ConvertStorage(ctx, conf.Store, to)
+	if err != nil {
+		return err
+	}
+	fmt.Println(to.Engine, to.Dir, count)
+	return nil
 }
 
 func requestSetup(c *cli.Context) error {
@@ -170,10 +661,15 @@ func requestSetup(c *cli.Context) error {
 		return err
 	}
 
-	key, err := crypto.PrivateKeyFromHex(conf.Node.Key)
+	suite, err := crypto.NewSuite(conf.Node.Suite)
+	if err != nil {
+		return err
+	}
+	key, err := encoding.ParsePrivateKey(suite, conf.Node.Key)
 	if err != nil {
 		panic(conf.Node.Key)
 	}
+	defer crypto.WipeScalar(key)
 
 	s := &mixin.Keystore{
 		ClientID:   conf.Messenger.UserId,
@@ -186,7 +682,7 @@ func requestSetup(c *cli.Context) error {
 		return err
 	}
 
-	msg := signer.MakeSetupMessage(ctx, key, nonce)
+	msg := signer.MakeSetupMessage(ctx, key, conf.Node.SignatureVersion, nonce)
 	mex := hex.EncodeToString(msg)
 	data := base64.RawURLEncoding.EncodeToString(msg)
 	fmt.Println(data, len(msg))
@@ -199,7 +695,10 @@ func requestSetup(c *cli.Context) error {
 }
 
 func genKey(c *cli.Context) error {
-	suite := bn256.NewSuiteG2()
+	suite, err := crypto.NewSuite(c.String("suite"))
+	if err != nil {
+		return err
+	}
 	scalar := suite.Scalar().Pick(random.New())
 	point := suite.Point().Mul(scalar, nil)
 
@@ -214,9 +713,8 @@ func genKey(c *cli.Context) error {
 		return err
 	}
 
-	pub := crypto.PublicKeyString(point)
-	fmt.Println(scalar)
-	fmt.Println(pub, err)
+	fmt.Println(encoding.EncodePrivateKey(scalar))
+	fmt.Println(encoding.EncodePublicKey(point))
 	return nil
 }
 
@@ -234,19 +732,48 @@ func requestSign(c *cli.Context) error {
 		return err
 	}
 	grace := int64(time.Hour * 24 * 128)
-	key := c.String("key")
-	ephemeral := c.String("ephemeral")
 	nonce := c.Int64("nonce")
-	rotate := c.String("rotate")
-	assignee := c.String("assignee")
 	watcher := c.String("watcher")
-	sig, evicted, err := client.Sign(key, ephemeral, nonce, grace, rotate, assignee, watcher)
+	var keys [4]*crypto.Secret
+	for i, name := range []string{"key", "ephemeral", "rotate", "assignee"} {
+		keys[i], err = legacyPrivateKey(client, c.String(name))
+		if err != nil {
+			for _, k := range keys {
+				k.Release()
+			}
+			return fmt.Errorf("invalid %s %v", name, err)
+		}
+	}
+	key, ephemeral, rotate, assignee := keys[0], keys[1], keys[2], keys[3]
+	sig, evicted, err := client.SignSecret(key, ephemeral, nonce, grace, rotate, assignee, watcher)
 	if err != nil {
 		return err
 	}
-	fmt.Println(hex.EncodeToString(sig))
+	fmt.Println(encoding.EncodeSignature(client.Suite(), sig))
 	for _, sp := range evicted {
 		fmt.Println(sp.Identity, sp.API)
 	}
 	return nil
 }
+
+// legacyPrivateKey converts the typed private key to the hex key of the SDK,
+// which is hashed as it is, so the same key has the same ephemeral either
+// way. The empty key is nil.
+func legacyPrivateKey(client *tip.Client, s string) (*crypto.Secret, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !encoding.IsTyped(s) {
+		return crypto.NewSecret([]byte(s)), nil
+	}
+	scalar, err := encoding.ParsePrivateKey(client.Suite(), s)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.WipeScalar(scalar)
+	b := crypto.PrivateKeyBytes(scalar)
+	defer clear(b)
+	hb := make([]byte, hex.EncodedLen(len(b)))
+	hex.Encode(hb, b)
+	return crypto.NewSecret(hb), nil
+}
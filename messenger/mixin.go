@@ -27,8 +27,12 @@ type MixinMessenger struct {
 	conversationId string
 	recv           chan []byte
 	send           chan *mixin.MessageRequest
+	flushed        chan struct{}
 }
 
+// flushTimeout bounds the last batch sent after the context is done.
+const flushTimeout = 10 * time.Second
+
 func NewMixinMessenger(ctx context.Context, conf *MixinConfiguration) (*MixinMessenger, error) {
 	s := &mixin.Keystore{
 		ClientID:   conf.UserId,
@@ -46,6 +50,7 @@ func NewMixinMessenger(ctx context.Context, conf *MixinConfiguration) (*MixinMes
 		conversationId: conf.ConversationId,
 		recv:           make(chan []byte, conf.Buffer),
 		send:           make(chan *mixin.MessageRequest, conf.Buffer),
+		flushed:        make(chan struct{}),
 	}
 	go mm.loopReceive(ctx)
 	go mm.loopSend(ctx, time.Second, conf.Buffer)
@@ -53,6 +58,17 @@ func NewMixinMessenger(ctx context.Context, conf *MixinConfiguration) (*MixinMes
 	return mm, nil
 }
 
+// Flush waits until the send loop sends all queued messages after the
+// messenger context is done, or the ctx is done.
+func (mm *MixinMessenger) Flush(ctx context.Context) error {
+	select {
+	case <-mm.flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (mm *MixinMessenger) ReceiveMessage(ctx context.Context) (string, []byte, error) {
 	select {
 	case b := <-mm.recv:
@@ -110,7 +126,7 @@ func (mm *MixinMessenger) buildMessage(receiver string, b []byte) *mixin.Message
 func (mm *MixinMessenger) loopReceive(ctx context.Context) {
 	for {
 		blaze := bot.NewBlazeClient(mm.conf.UserId, mm.conf.SessionId, mm.conf.Key)
-		err := blaze.Loop(context.Background(), mm)
+		err := blaze.Loop(ctx, mm)
 		logger.Errorf("blaze.Loop %v\n", err)
 		if ctx.Err() != nil {
 			break
@@ -150,10 +166,35 @@ func (mm *MixinMessenger) loopSend(ctx context.Context, period time.Duration, si
 				filter = make(map[string]bool)
 				batch = nil
 			}
+		case <-ctx.Done():
+			mm.flush(batch, filter)
+			return
 		}
 	}
 }
 
+// flush sends the batch with all the messages left in the channel, the
+// context is done already, so it has its own timeout.
+func (mm *MixinMessenger) flush(batch []*mixin.MessageRequest, filter map[string]bool) {
+	defer close(mm.flushed)
+
+	for len(mm.send) > 0 {
+		msg := <-mm.send
+		if filter[msg.MessageID] {
+			continue
+		}
+		filter[msg.MessageID] = true
+		batch = append(batch, msg)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	err := mm.sendMessagesWithoutTimeout(ctx, batch)
+	logger.Verbose("MixinMessenger.flush", len(batch), err)
+}
+
 func (mm *MixinMessenger) OnMessage(ctx context.Context, msg bot.MessageView, userId string) error {
 	if msg.Category != mixin.MessageCategoryPlainText {
 		return nil
@@ -192,7 +233,7 @@ func (mm *MixinMessenger) SyncAck() bool {
 func (mm *MixinMessenger) sendMessagesWithoutTimeout(ctx context.Context, batch []*mixin.MessageRequest) error {
 	for {
 		err := mm.client.SendMessages(ctx, batch)
-		if err != nil && strings.Contains(err.Error(), "Client.Timeout exceeded") {
+		if err != nil && ctx.Err() == nil && strings.Contains(err.Error(), "Client.Timeout exceeded") {
 			continue
 		}
 		return err
@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/tip/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Configuration struct {
+	// the admin port only serves /metrics, and should not be public,
+	// zero disables it
+	Port int `toml:"port"`
+}
+
+// Registry has all the tip metrics, it's not the prometheus default, so
+// only the collectors registered here are exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tip",
+		Subsystem: "api",
+		Name:      "requests_total",
+		Help:      "API requests by action and HTTP status code.",
+	}, []string{"action", "status"})
+
+	apiSignDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tip",
+		Subsystem: "api",
+		Name:      "sign_duration_seconds",
+		Help:      "Latency of the sign requests by result.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"result"})
+
+	apiRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tip",
+		Subsystem: "api",
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected before the guard by the client rate or the sign concurrency.",
+	}, []string{"reason"})
+
+	apiSignConcurrency = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tip",
+		Subsystem: "api",
+		Name:      "sign_concurrency",
+		Help:      "The sign requests in process, only when the concurrency is limited.",
+	})
+
+	guardRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tip",
+		Subsystem: "keeper",
+		Name:      "guard_rejections_total",
+		Help:      "Sign requests rejected by the keeper guard by reason.",
+	}, []string{"reason"})
+
+	quotaExhaustions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tip",
+		Subsystem: "keeper",
+		Name:      "quota_exhaustions_total",
+		Help:      "Sign requests rejected because the limit quota is exhausted by kind.",
+	}, []string{"kind"})
+
+	storeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tip",
+		Subsystem: "store",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the storage operations by name and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 14),
+	}, []string{"operation", "result"})
+
+	badgerSize = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "tip",
+		Subsystem: "store",
+		Name:      "badger_size_bytes",
+		Help:      "Size of the badger LSM tree and value log files.",
+	}, func() float64 {
+		if fn := badgerSizeFunc.Load(); fn != nil {
+			lsm, vlog := (*fn)()
+			return float64(lsm + vlog)
+		}
+		return 0
+	})
+	badgerSizeFunc atomic.Pointer[func() (int64, int64)]
+
+	dkgPhase = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tip",
+		Subsystem: "signer",
+		Name:      "dkg_phase",
+		Help:      "The current DKG phase, 0 init, 1 deal, 2 response, 3 justification, 4 finish.",
+	})
+
+	signerMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tip",
+		Subsystem: "signer",
+		Name:      "messages_total",
+		Help:      "Signer messages by direction, action and result.",
+	}, []string{"direction", "action", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		apiRequests,
+		apiSignDuration,
+		apiRateLimited,
+		apiSignConcurrency,
+		guardRejections,
+		quotaExhaustions,
+		storeDuration,
+		badgerSize,
+		dkgPhase,
+		signerMessages,
+	)
+}
+
+func APIRequest(action string, status int) {
+	apiRequests.WithLabelValues(action, fmt.Sprint(status)).Inc()
+}
+
+func SignDuration(result string, d time.Duration) {
+	apiSignDuration.WithLabelValues(result).Observe(d.Seconds())
+}
+
+func RateLimited(reason string) {
+	apiRateLimited.WithLabelValues(reason).Inc()
+}
+
+func SignConcurrency(n int) {
+	apiSignConcurrency.Set(float64(n))
+}
+
+func GuardRejection(reason string) {
+	guardRejections.WithLabelValues(reason).Inc()
+}
+
+func QuotaExhausted(kind string) {
+	quotaExhaustions.WithLabelValues(kind).Inc()
+}
+
+// StoreOperation is deferred at the start of a storage method, with the
+// pointer to its named error result.
+func StoreOperation(operation string, start time.Time, err *error) {
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	storeDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// BadgerSize sets the function to read the badger size, which is called on
+// each scrape, and only the last opened database is reported.
+func BadgerSize(fn func() (int64, int64)) {
+	badgerSizeFunc.Store(&fn)
+}
+
+func DKGPhase(phase int) {
+	dkgPhase.Set(float64(phase))
+}
+
+func SignerMessage(direction, action, result string) {
+	signerMessages.WithLabelValues(direction, action, result).Inc()
+}
+
+// Serve starts the metrics server in background, and it's shutdown when
+// the context is done.
+func Serve(ctx context.Context, conf *Configuration) {
+	if conf == nil || conf.Port == 0 {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", conf.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(sctx)
+	}()
+	go func() {
+		err := server.ListenAndServe()
+		if err != http.ErrServerClosed {
+			logger.Error("metrics.Serve", conf.Port, err)
+		}
+	}()
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsRecorders(t *testing.T) {
+	require := require.New(t)
+
+	APIRequest("sign", 429)
+	APIRequest("sign", 429)
+	require.Equal(2.0, testutil.ToFloat64(apiRequests.WithLabelValues("sign", "429")))
+	RateLimited("client")
+	require.Equal(1.0, testutil.ToFloat64(apiRateLimited.WithLabelValues("client")))
+	SignConcurrency(3)
+	require.Equal(3.0, testutil.ToFloat64(apiSignConcurrency))
+	GuardRejection("nonce")
+	require.Equal(1.0, testutil.ToFloat64(guardRejections.WithLabelValues("nonce")))
+	QuotaExhausted("SECRET")
+	require.Equal(1.0, testutil.ToFloat64(quotaExhaustions.WithLabelValues("SECRET")))
+	DKGPhase(2)
+	require.Equal(2.0, testutil.ToFloat64(dkgPhase))
+	SignerMessage("sent", "deal", "ok")
+	require.Equal(1.0, testutil.ToFloat64(signerMessages.WithLabelValues("sent", "deal", "ok")))
+
+	BadgerSize(func() (int64, int64) { return 100, 28 })
+	require.Equal(128.0, testutil.ToFloat64(badgerSize))
+
+	var err error
+	StoreOperation("CheckLimit", time.Now(), &err)
+	SignDuration("ok", time.Millisecond)
+	require.Equal(1, testutil.CollectAndCount(storeDuration, "tip_store_operation_duration_seconds"))
+	require.Equal(1, testutil.CollectAndCount(apiSignDuration, "tip_api_sign_duration_seconds"))
+}
+
+func TestMetricsServe(t *testing.T) {
+	require := require.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(l.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	Serve(ctx, &Configuration{Port: port})
+	Serve(ctx, &Configuration{})
+	Serve(ctx, nil)
+
+	APIRequest("info", 200)
+	var body string
+	require.Eventually(func() bool {
+		res, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", port))
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		body = string(b)
+		return res.StatusCode == http.StatusOK
+	}, time.Second*5, time.Millisecond*50)
+	require.Contains(body, `tip_api_requests_total{action="info",status="200"}`)
+	require.Contains(body, "go_goroutines")
+	require.NotContains(body, "promhttp_metric_handler")
+}
@@ -4,16 +4,28 @@ import (
 	"encoding/json"
 
 	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/crypto/encoding"
 )
 
 type signerPair struct {
 	Identity string `json:"identity"`
 	API      string `json:"api"`
+
+	// the cipher version negotiated with the signer info
+	cipher int
 }
 
+// defaultMaxDifficulty is about 16M hashes, the challenge of a higher
+// difficulty is never solved, because the signer could be compromised.
+const defaultMaxDifficulty = 24
+
 type Configuration struct {
 	Commitments []string      `json:"commitments"`
 	Signers     []*signerPair `json:"signers"`
+
+	// the max proof-of-work difficulty of a signer challenge solved by the
+	// client, zero is the default 24
+	MaxDifficulty int `json:"max_difficulty"`
 }
 
 func LoadConfigurationJSON(data string) (*Configuration, error) {
@@ -25,15 +37,32 @@ func LoadConfigurationJSON(data string) (*Configuration, error) {
 	return &conf, conf.validate()
 }
 
+// validate also converts the typed commitments and signer keys to the
+// legacy base58 keys, which are in the signer info and hashed in the sign
+// requests.
 func (conf *Configuration) validate() error {
 	if len(conf.Commitments) != len(conf.Signers)*2/3+1 {
 		return ErrInvalidConfiguration
 	}
-	for _, c := range conf.Commitments {
-		_, err := crypto.PubKeyFromBase58(c)
+	if conf.MaxDifficulty < 0 {
+		return ErrInvalidConfiguration
+	}
+	for i, c := range conf.Commitments {
+		point, err := encoding.ParseCommitment(c)
+		if err != nil {
+			return ErrInvalidConfiguration
+		}
+		conf.Commitments[i] = crypto.PublicKeyString(point)
+	}
+	for _, s := range conf.Signers {
+		if !encoding.IsTyped(s.Identity) {
+			continue
+		}
+		point, err := encoding.DecodePublicKey(s.Identity)
 		if err != nil {
 			return ErrInvalidConfiguration
 		}
+		s.Identity = crypto.PublicKeyString(point)
 	}
 	return nil
 }
@@ -2,4 +2,9 @@ package tip
 
 import "fmt"
 
-var ErrInvalidConfiguration = fmt.Errorf("invalid configuration")
+var (
+	ErrInvalidConfiguration = fmt.Errorf("invalid configuration")
+	ErrChallengeRequired    = fmt.Errorf("challenge required")
+	ErrChallengeTooHard     = fmt.Errorf("challenge too hard")
+	ErrStaleResponse        = fmt.Errorf("stale response")
+)
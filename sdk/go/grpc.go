@@ -0,0 +1,124 @@
+package tip
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/tip/api/pb"
+	"github.com/MixinNetwork/tip/crypto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// isGRPC is true when the signer API is grpc://host:port, or grpcs:// for
+// TLS with the system roots.
+func (sp *signerPair) isGRPC() bool {
+	return strings.HasPrefix(sp.API, "grpc://") || strings.HasPrefix(sp.API, "grpcs://")
+}
+
+// grpcCall dials for each call, the same as the HTTP requests which don't
+// keep the connections.
+func grpcCall[T any](sp *signerPair, call func(context.Context, pb.SignerClient) (T, error)) (T, error) {
+	var zero T
+	creds := insecure.NewCredentials()
+	target, found := strings.CutPrefix(sp.API, "grpcs://")
+	if found {
+		creds = credentials.NewTLS(nil)
+	} else {
+		target = strings.TrimPrefix(sp.API, "grpc://")
+	}
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return zero, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpClient.Timeout)
+	defer cancel()
+	res, err := call(ctx, pb.NewSignerClient(conn))
+	if status.Code(err) == codes.FailedPrecondition {
+		return zero, ErrChallengeRequired
+	}
+	return res, err
+}
+
+func grpcInfo(sp *signerPair, freshness string) (*ResponseData, error) {
+	req := &pb.InfoRequest{Version: signatureVersion}
+	req.Freshness, _ = hex.DecodeString(freshness)
+	res, err := grpcCall(sp, func(ctx context.Context, c pb.SignerClient) (*pb.InfoResponse, error) {
+		return c.Info(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	data := &ResponseData{
+		Commitments: res.Commitments,
+		Freshness:   hex.EncodeToString(res.Freshness),
+		Identity:    res.Identity,
+		Suite:       res.Suite,
+		Timestamp:   res.Timestamp,
+	}
+	for _, c := range res.Ciphers {
+		data.Ciphers = append(data.Ciphers, int(c))
+	}
+	for _, s := range res.Signers {
+		data.Signers = append(data.Signers, struct {
+			Identity string `json:"identity"`
+			Index    int    `json:"index"`
+		}{Identity: s.Identity, Index: int(s.Index)})
+	}
+	return data, verifyResponse(sp, crypto.DomainInfo, data, res.Signature)
+}
+
+func grpcChallenge(sp *signerPair) (*ResponseData, error) {
+	res, err := grpcCall(sp, func(ctx context.Context, c pb.SignerClient) (*pb.ChallengeResponse, error) {
+		return c.Challenge(ctx, &pb.ChallengeRequest{Version: signatureVersion})
+	})
+	if err != nil {
+		return nil, err
+	}
+	ch := res.GetChallenge()
+	data := &ResponseData{
+		Difficulty: int(ch.GetDifficulty()),
+		Expiry:     ch.GetExpiry(),
+		Seed:       hex.EncodeToString(ch.GetSeed()),
+	}
+	return data, verifyResponse(sp, crypto.DomainChallenge, data, res.Signature)
+}
+
+func grpcSign(sp *signerPair, sr *signRequest) (*ResponseData, error) {
+	req := &pb.SignRequest{Identity: sr.Identity}
+	req.Data, _ = base64.RawURLEncoding.DecodeString(sr.Data)
+	req.Signature, _ = hex.DecodeString(sr.Signature)
+	req.Watcher, _ = hex.DecodeString(sr.Watcher)
+	req.Freshness, _ = hex.DecodeString(sr.Freshness)
+	req.Cipher = int32(sr.Cipher)
+	if sr.Session != "" {
+		session, _ := crypto.PubKeyFromBase58(sr.Session)
+		req.Session = crypto.PublicKeyBytes(session)
+	}
+	if ch := sr.Challenge; ch != nil {
+		seed, _ := hex.DecodeString(ch.Seed)
+		req.Challenge = &pb.Challenge{Difficulty: int64(ch.Difficulty), Expiry: ch.Expiry, Seed: seed}
+		req.Solution, _ = strconv.ParseUint(sr.Solution, 16, 64)
+	}
+	res, err := grpcCall(sp, func(ctx context.Context, c pb.SignerClient) (*pb.SignResponse, error) {
+		return c.Sign(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	data := &ResponseData{
+		Cipher:    hex.EncodeToString(res.Cipher),
+		Freshness: hex.EncodeToString(res.Freshness),
+		Request:   hex.EncodeToString(res.Request),
+		Timestamp: res.Timestamp,
+	}
+	return data, verifyResponse(sp, crypto.DomainResponse, data, res.Signature)
+}
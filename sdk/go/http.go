@@ -2,29 +2,50 @@ package tip
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/MixinNetwork/tip/crypto"
 )
 
+// freshnessWindow is the max difference between the signed timestamp and
+// the local time, which allows some clock skew of the signers.
+const freshnessWindow = 5 * time.Minute
+
+// signatureVersion is requested for all the signed responses, and used to
+// sign the requests, the signers must be upgraded before the clients.
+const signatureVersion = crypto.SignatureDomain
+
 var httpClient *http.Client
 
 func init() {
 	httpClient = &http.Client{Timeout: 10 * time.Second}
 }
 
+// ResponseData is the union of all the signed data, and the fields are in
+// alphabetical order, the same as the signer encodes them.
 type ResponseData struct {
+	Cipher      string   `json:"cipher,omitempty"`
+	Ciphers     []int    `json:"ciphers,omitempty"`
 	Commitments []string `json:"commitments,omitempty"`
+	Difficulty  int      `json:"difficulty,omitempty"`
+	Expiry      int64    `json:"expiry,omitempty"`
+	Freshness   string   `json:"freshness,omitempty"`
 	Identity    string   `json:"identity,omitempty"`
+	Request     string   `json:"request,omitempty"`
+	Seed        string   `json:"seed,omitempty"`
 	Signers     []struct {
 		Identity string `json:"identity"`
 		Index    int    `json:"index"`
 	} `json:"signers,omitempty"`
-	Cipher string `json:"cipher,omitempty"`
+	Suite     string `json:"suite,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
 }
 
 type Response struct {
@@ -36,8 +57,8 @@ type Response struct {
 	Signature string        `json:"signature"`
 }
 
-func request(sp *signerPair, method string, data []byte) (*ResponseData, error) {
-	req, err := http.NewRequest(method, sp.API, bytes.NewReader(data))
+func request(sp *signerPair, method, target, domain string, data []byte) (*ResponseData, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +69,9 @@ func request(sp *signerPair, method string, data []byte) (*ResponseData, error)
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionRequired {
+		return nil, ErrChallengeRequired
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("error code %d", resp.StatusCode)
 	}
@@ -65,18 +89,103 @@ func request(sp *signerPair, method string, data []byte) (*ResponseData, error)
 	if err != nil {
 		return nil, err
 	}
+	return body.Data, verifyResponse(sp, domain, body.Data, sig)
+}
+
+// verifyResponse checks the signature of the JSON data, which is encoded
+// to the same bytes signed by the signer.
+func verifyResponse(sp *signerPair, domain string, rd *ResponseData, sig []byte) error {
 	pub, err := crypto.PubKeyFromBase58(sp.Identity)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	data, err := json.Marshal(rd)
+	if err != nil {
+		return err
+	}
+	return crypto.VerifyDomain(pub, domain, signatureVersion, data, sig)
+}
+
+// checkFreshness rejects the response not made for the request, or made
+// too long ago, which could be replayed by a man in the middle.
+func checkFreshness(rd *ResponseData, freshness, request string) error {
+	if rd.Freshness != freshness || rd.Request != request {
+		return ErrStaleResponse
+	}
+	age := time.Since(time.Unix(rd.Timestamp, 0))
+	if age > freshnessWindow || age < -freshnessWindow {
+		return ErrStaleResponse
+	}
+	return nil
+}
+
+// negotiateCipher returns the latest cipher version supported by both the
+// signer and the client, and the legacy one for the older signers.
+func negotiateCipher(ciphers []int) int {
+	version := crypto.CipherLegacy
+	for _, c := range ciphers {
+		if c <= crypto.CipherSession && c > version {
+			version = c
+		}
+	}
+	return version
+}
+
+func newFreshness() string {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func (sp *signerPair) info() (*ResponseData, error) {
+	freshness := newFreshness()
+	var res *ResponseData
+	var err error
+	if sp.isGRPC() {
+		res, err = grpcInfo(sp, freshness)
+	} else {
+		res, err = requestInfo(sp, freshness)
 	}
-	data, err = json.Marshal(body.Data)
 	if err != nil {
 		return nil, err
 	}
-	err = crypto.Verify(pub, data, sig)
+	return res, checkFreshness(res, freshness, "")
+}
+
+func requestInfo(sp *signerPair, freshness string) (*ResponseData, error) {
+	u, err := url.Parse(sp.API)
 	if err != nil {
 		return nil, err
 	}
+	query := u.Query()
+	query.Set("freshness", freshness)
+	query.Set("version", strconv.Itoa(signatureVersion))
+	u.RawQuery = query.Encode()
+	return request(sp, "GET", u.String(), crypto.DomainInfo, nil)
+}
+
+func (sp *signerPair) challenge() (*ResponseData, error) {
+	if sp.isGRPC() {
+		return grpcChallenge(sp)
+	}
+	b, _ := json.Marshal(map[string]any{"action": "CHALLENGE", "version": signatureVersion})
+	return request(sp, "POST", sp.API, crypto.DomainChallenge, b)
+}
 
-	return body.Data, nil
+func (sp *signerPair) sign(req *signRequest) (*ResponseData, error) {
+	var res *ResponseData
+	var err error
+	if sp.isGRPC() {
+		res, err = grpcSign(sp, req)
+	} else {
+		b, _ := json.Marshal(req)
+		res, err = request(sp, "POST", sp.API, crypto.DomainResponse, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, checkFreshness(res, req.Freshness, requestHash(req))
 }
@@ -8,17 +8,20 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/MixinNetwork/tip/crypto"
 	"go.dedis.ch/kyber/v4"
-	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/share"
 	"go.dedis.ch/kyber/v4/sign/tbls"
+	"go.dedis.ch/kyber/v4/util/random"
 )
 
 type Client struct {
-	commitments []kyber.Point
-	signers     []*signerPair
+	suite         crypto.Suite
+	commitments   []kyber.Point
+	signers       []*signerPair
+	maxDifficulty int
 }
 
 func NewClient(conf *Configuration) (*Client, []*signerPair, error) {
@@ -27,7 +30,10 @@ func NewClient(conf *Configuration) (*Client, []*signerPair, error) {
 		return nil, nil, err
 	}
 
-	cli := &Client{signers: conf.Signers}
+	cli := &Client{signers: conf.Signers, maxDifficulty: conf.MaxDifficulty}
+	if cli.maxDifficulty == 0 {
+		cli.maxDifficulty = defaultMaxDifficulty
+	}
 	for _, c := range conf.Commitments {
 		point, _ := crypto.PubKeyFromBase58(c)
 		cli.commitments = append(cli.commitments, point)
@@ -35,11 +41,16 @@ func NewClient(conf *Configuration) (*Client, []*signerPair, error) {
 
 	var evicted []*signerPair
 	for _, s := range conf.Signers {
-		res, err := request(s, "GET", nil)
+		res, err := s.info()
 		if err != nil {
 			evicted = append(evicted, s)
 			continue
 		}
+		s.cipher = negotiateCipher(res.Ciphers)
+		if !cli.detectSuite(res.Suite) {
+			evicted = append(evicted, s)
+			continue
+		}
 		if res.Identity != s.Identity {
 			evicted = append(evicted, s)
 			continue
@@ -72,49 +83,90 @@ func NewClient(conf *Configuration) (*Client, []*signerPair, error) {
 	return cli, evicted, nil
 }
 
+// detectSuite sets the suite of the network with the first signer info,
+// which is rejected if the commitments are of another suite, and the other
+// signers must have the same suite.
+func (c *Client) detectSuite(name string) bool {
+	suite, err := crypto.NewSuite(name)
+	if err != nil {
+		return false
+	}
+	if c.suite == nil {
+		for _, p := range c.commitments {
+			if crypto.SuiteName(crypto.PointSuite(p)) != crypto.SuiteName(suite) {
+				return false
+			}
+		}
+		c.suite = suite
+	}
+	return crypto.SuiteName(c.suite) == crypto.SuiteName(suite)
+}
+
+// Suite returns the suite of the network detected from the signer info.
+func (c *Client) Suite() crypto.Suite {
+	return c.suite
+}
+
+// Sign copies the hex keys to the secrets, which are released after the
+// signing, but the strings can't be zeroed, so SignSecret is preferred.
 func (c *Client) Sign(ks, ephemeral string, nonce, grace int64, rotate, assignee, watcher string) ([]byte, []*signerPair, error) {
-	key, err := crypto.PrivateKeyFromHex(ks)
+	var rs, as *crypto.Secret
+	if rotate != "" {
+		rs = crypto.NewSecret([]byte(rotate))
+	}
+	if assignee != "" {
+		as = crypto.NewSecret([]byte(assignee))
+	}
+	return c.SignSecret(crypto.NewSecret([]byte(ks)), crypto.NewSecret([]byte(ephemeral)), nonce, grace, rs, as, watcher)
+}
+
+// SignSecret has the same hex keys as Sign in the secrets, the rotate and
+// assignee could be nil, and all the secrets are released after the signing.
+// The ephemeral and rotate are hashed as they are, so the same text must
+// be used for an identity.
+func (c *Client) SignSecret(ks, ephemeral *crypto.Secret, nonce, grace int64, rotate, assignee *crypto.Secret, watcher string) ([]byte, []*signerPair, error) {
+	defer ks.Release()
+	defer ephemeral.Release()
+	defer rotate.Release()
+	defer assignee.Release()
+
+	key, err := secretScalar(c.suite, ks)
 	if err != nil {
 		return nil, nil, err
 	}
-	_, err = crypto.PrivateKeyFromHex(ephemeral)
+	defer crypto.WipeScalar(key)
+	err = checkSecret(ephemeral)
 	if err != nil {
 		return nil, nil, err
 	}
-	if rotate != "" {
-		_, err = crypto.PrivateKeyFromHex(rotate)
+	if len(rotate.Bytes()) > 0 {
+		err = checkSecret(rotate)
 		if err != nil {
 			return nil, nil, err
 		}
 	}
 
+	// the partial has the 2 bytes index and the signature on G1, and the
+	// assignor is a public key on G2
+	pl, al := 2+c.suite.G1().PointLen(), c.suite.PointLen()
 	var assignor []byte
 	var partials [][]byte
 	var evicted []*signerPair
 	pam := make(map[string][]byte)
 	acm := make(map[string]int)
 	for _, s := range c.signers {
-		data := sign(key, s.Identity, ephemeral, uint64(nonce), uint64(grace), rotate, assignee, watcher)
-		res, err := request(s, "POST", data)
+		req, err := sign(key, s, ephemeral, uint64(nonce), uint64(grace), rotate, assignee, watcher, nil)
 		if err != nil {
 			evicted = append(evicted, s)
 			continue
 		}
-		enc, err := hex.DecodeString(res.Cipher)
-		if err != nil {
-			evicted = append(evicted, s)
-			continue
-		}
-		if len(enc) < 32 {
-			evicted = append(evicted, s)
-			continue
-		}
-		pub, err := crypto.PubKeyFromBase58(s.Identity)
-		if err != nil {
-			panic(err)
+		res, err := s.sign(req)
+		if err == ErrChallengeRequired {
+			crypto.WipeScalar(req.session)
+			req, res, err = signWithChallenge(s, c.maxDifficulty, key, ephemeral, uint64(nonce), uint64(grace), rotate, assignee, watcher)
 		}
-		dec := crypto.DecryptECDH(pub, key, enc)
-		if len(dec) != 8+66+128+8+8 {
+		dec := decryptResponse(s, key, req, res, err)
+		if len(dec) != 8+pl+al+8+8 {
 			evicted = append(evicted, s)
 			continue
 		}
@@ -122,7 +174,7 @@ func (c *Client) Sign(ks, ephemeral string, nonce, grace int64, rotate, assignee
 			evicted = append(evicted, s)
 			continue
 		}
-		p, a := dec[8:74], dec[74:202]
+		p, a := dec[8:8+pl], dec[8+pl:8+pl+al]
 		as := hex.EncodeToString(a)
 		pam[hex.EncodeToString(p)] = a
 		acm[as] = acm[as] + 1
@@ -146,8 +198,8 @@ func (c *Client) Sign(ks, ephemeral string, nonce, grace int64, rotate, assignee
 	if len(partials) < len(c.commitments) {
 		return nil, evicted, fmt.Errorf("not enough partials %d %d", len(partials), len(c.commitments))
 	}
-	suite := bn256.NewSuiteG2()
-	scheme := tbls.NewThresholdSchemeOnG1(bn256.NewSuiteG2())
+	suite := c.suite
+	scheme := tbls.NewThresholdSchemeOnG1(suite)
 	poly := share.NewPubPoly(suite, suite.Point().Base(), c.commitments)
 	sig, err := scheme.Recover(poly, assignor, partials, uint32(len(c.commitments)), uint32(len(c.signers)))
 	if err != nil {
@@ -160,11 +212,124 @@ func (c *Client) Sign(ks, ephemeral string, nonce, grace int64, rotate, assignee
 	return sig, evicted, nil
 }
 
-func sign(key kyber.Scalar, nodeId, ephemeral string, nonce, grace uint64, rotate, assignee, watcher string) []byte {
+// decryptResponse returns nil for the failed request, and the session key
+// of the request is wiped in any case.
+func decryptResponse(sp *signerPair, key kyber.Scalar, req *signRequest, res *ResponseData, err error) []byte {
+	if req != nil {
+		defer crypto.WipeScalar(req.session)
+	}
+	if err != nil {
+		return nil
+	}
+	enc, err := hex.DecodeString(res.Cipher)
+	if err != nil || len(enc) < 32 {
+		return nil
+	}
+	pub, err := crypto.PubKeyFromBase58(sp.Identity)
+	if err != nil {
+		panic(err)
+	}
+	return crypto.DecryptECDHWithAD(pub, req.receiver(key), crypto.DirectionResponse, sp.cipher, enc)
+}
+
+// signWithChallenge solves the proof-of-work puzzle of the signer, which
+// is only required when the signer is under load, and the difficulty above
+// the max is rejected without any work.
+func signWithChallenge(sp *signerPair, maxDifficulty int, key kyber.Scalar, ephemeral *crypto.Secret, nonce, grace uint64, rotate, assignee *crypto.Secret, watcher string) (*signRequest, *ResponseData, error) {
+	ch, err := sp.challenge()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ch.Difficulty < 1 || ch.Seed == "" {
+		return nil, nil, ErrChallengeRequired
+	}
+	if ch.Difficulty > maxDifficulty {
+		return nil, nil, ErrChallengeTooHard
+	}
+	req, err := sign(key, sp, ephemeral, nonce, grace, rotate, assignee, watcher, ch)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := sp.sign(req)
+	return req, res, err
+}
+
+type challengeData struct {
+	Difficulty int    `json:"difficulty"`
+	Expiry     int64  `json:"expiry"`
+	Seed       string `json:"seed"`
+}
+
+type signRequest struct {
+	Action    string         `json:"action"`
+	Identity  string         `json:"identity"`
+	Data      string         `json:"data"`
+	Signature string         `json:"signature"`
+	Watcher   string         `json:"watcher"`
+	Challenge *challengeData `json:"challenge,omitempty"`
+	Solution  string         `json:"solution,omitempty"`
+	Freshness string         `json:"freshness,omitempty"`
+	Cipher    int            `json:"cipher,omitempty"`
+	Session   string         `json:"session,omitempty"`
+
+	// the one-time key of the session cipher, which is never sent
+	session kyber.Scalar
+}
+
+// receiver returns the key to decrypt the response, the session key for
+// the session cipher, otherwise the identity key.
+func (req *signRequest) receiver(key kyber.Scalar) kyber.Scalar {
+	if req.session != nil {
+		return req.session
+	}
+	return key
+}
+
+// requestHash is the same as the signer hashes the request, which is signed
+// in the response.
+func requestHash(req *signRequest) string {
+	h := sha3.New256()
+	for _, s := range []string{req.Identity, req.Data, req.Signature, req.Watcher, req.Freshness, req.Session} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// secretScalar decodes the hex key of the secret, and the decoded bytes are
+// zeroed after the scalar is made.
+func secretScalar(suite crypto.Suite, s *crypto.Secret) (kyber.Scalar, error) {
+	b := make([]byte, hex.DecodedLen(len(s.Bytes())))
+	defer clear(b)
+	_, err := hex.Decode(b, s.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return suite.Scalar().SetBytes(b), nil
+}
+
+func checkSecret(s *crypto.Secret) error {
+	b := make([]byte, hex.DecodedLen(len(s.Bytes())))
+	defer clear(b)
+	_, err := hex.Decode(b, s.Bytes())
+	return err
+}
+
+// secretSum hashes the seed and the signer identity, without copying the
+// seed to another buffer.
+func secretSum(seed *crypto.Secret, nodeId string) []byte {
+	h := sha3.New256()
+	h.Write(seed.Bytes())
+	h.Write([]byte(nodeId))
+	return h.Sum(nil)
+}
+
+func sign(key kyber.Scalar, sp *signerPair, ephemeral *crypto.Secret, nonce, grace uint64, rotate, assignee *crypto.Secret, watcher string, challenge *ResponseData) (*signRequest, error) {
+	nodeId := sp.Identity
 	pkey := crypto.PublicKey(key)
-	esum := sha3.Sum256(append([]byte(ephemeral), nodeId...))
+	esum := secretSum(ephemeral, nodeId)
 	msg := crypto.PublicKeyBytes(pkey)
-	msg = append(msg, esum[:]...)
+	msg = append(msg, esum...)
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, nonce)
 	msg = append(msg, buf...)
@@ -172,38 +337,67 @@ func sign(key kyber.Scalar, nodeId, ephemeral string, nonce, grace uint64, rotat
 	msg = append(msg, buf...)
 	data := map[string]any{
 		"identity":  crypto.PublicKeyString(pkey),
-		"ephemeral": hex.EncodeToString(esum[:]),
+		"ephemeral": hex.EncodeToString(esum),
 		"watcher":   watcher,
 		"nonce":     nonce,
 		"grace":     grace,
+		"version":   signatureVersion,
 	}
-	if rotate != "" {
-		rsum := sha3.Sum256(append([]byte(rotate), nodeId...))
-		msg = append(msg, rsum[:]...)
-		data["rotate"] = hex.EncodeToString(rsum[:])
+	if len(rotate.Bytes()) > 0 {
+		rsum := secretSum(rotate, nodeId)
+		msg = append(msg, rsum...)
+		data["rotate"] = hex.EncodeToString(rsum)
 	}
-	if len(assignee) > 0 {
-		as, _ := crypto.PrivateKeyFromHex(assignee)
+	if len(assignee.Bytes()) > 0 {
+		as, _ := secretScalar(crypto.ScalarSuite(key), assignee)
+		defer crypto.WipeScalar(as)
 		ap := crypto.PublicKey(as)
 		ab := crypto.PublicKeyBytes(ap)
-		sig, _ := crypto.Sign(as, ab)
+		sig, _ := crypto.SignDomain(as, crypto.DomainAssignee, signatureVersion, ab)
 		ab = append(ab, sig...)
 		msg = append(msg, ab...)
 		data["assignee"] = hex.EncodeToString(ab)
 	}
-	b, _ := json.Marshal(data)
 	spub, err := crypto.PubKeyFromBase58(nodeId)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	cipher := crypto.EncryptECDH(spub, key, b)
-	sig, _ := crypto.Sign(key, msg)
-	b, _ = json.Marshal(map[string]any{
-		"action":    "SIGN",
-		"identity":  crypto.PublicKeyString(pkey),
-		"data":      base64.RawURLEncoding.EncodeToString(cipher[:]),
-		"signature": hex.EncodeToString(sig),
-		"watcher":   watcher,
-	})
-	return b
+	b, _ := json.Marshal(data)
+	sender := key
+	var session kyber.Scalar
+	if sp.cipher == crypto.CipherSession {
+		session = crypto.ScalarSuite(key).Scalar().Pick(random.New())
+		sender = session
+	}
+	cipher, err := crypto.EncryptECDHWithAD(spub, sender, crypto.DirectionRequest, sp.cipher, b)
+	clear(b)
+	if err != nil {
+		crypto.WipeScalar(session)
+		return nil, err
+	}
+	sig, _ := crypto.SignDomain(key, crypto.DomainRequest, signatureVersion, msg)
+	req := &signRequest{
+		Action:    "SIGN",
+		Identity:  crypto.PublicKeyString(pkey),
+		Data:      base64.RawURLEncoding.EncodeToString(cipher[:]),
+		Signature: hex.EncodeToString(sig),
+		Watcher:   watcher,
+		Freshness: newFreshness(),
+		Cipher:    sp.cipher,
+		session:   session,
+	}
+	if session != nil {
+		req.Session = crypto.PublicKeyString(crypto.PublicKey(session))
+	}
+	if challenge != nil {
+		seed, _ := hex.DecodeString(challenge.Seed)
+		solution := crypto.SolveWork(seed, []byte(req.Data), challenge.Difficulty)
+		req.Challenge = &challengeData{
+			Difficulty: challenge.Difficulty,
+			Expiry:     challenge.Expiry,
+			Seed:       challenge.Seed,
+		}
+		req.Solution = strconv.FormatUint(solution, 16)
+	}
+	return req, nil
 }
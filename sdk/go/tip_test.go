@@ -3,6 +3,7 @@ package tip
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -11,9 +12,13 @@ import (
 	"time"
 
 	"github.com/MixinNetwork/tip/api"
+	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/crypto/encoding"
 	"github.com/MixinNetwork/tip/signer"
 	"github.com/MixinNetwork/tip/store"
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/util/random"
 )
 
 func TestTip(t *testing.T) {
@@ -26,6 +31,9 @@ func TestTip(t *testing.T) {
 	client, evicted, err := NewClient(testConfigurationJSON())
 	require.Nil(err)
 	require.Len(evicted, 0)
+	for _, s := range client.signers {
+		require.Equal(crypto.CipherSession, s.cipher)
+	}
 	key := "8bee954d5315684caa46d78fb8456a165bdd0cb44643d335a6b15c21d8c1872b"
 	ephemeral := "2b5a6b0cb9576ea218d081baa14d2cea82a6839165a29b3bdfc6ef8582b0ce5a"
 	watcher := "2b5a6b0cb9576ea218d081baa14d2cea82a6839165a29b3bdfc6ef8582b0ce5a"
@@ -48,6 +56,163 @@ func TestTip(t *testing.T) {
 	require.Len(evicted, 0)
 	require.Equal("8258bc1a22db4865529d7c01a949d303e4d834d6fe79fcf746c6ad3fcb2ee37583975a034eea3ad08105c856f5c302ed02e2b11b71440d9e31da5b06097b691f", hex.EncodeToString(sig))
 	log.Println(hex.EncodeToString(sig))
+
+	nonce = 1235
+	kb, eb := []byte(key), []byte(ephemeral)
+	sig, evicted, err = client.SignSecret(crypto.NewSecret(kb), crypto.NewSecret(eb), nonce, grace, nil, nil, watcher)
+	require.Nil(err)
+	require.Len(evicted, 0)
+	require.Len(sig, 64)
+	require.Equal(make([]byte, len(kb)), kb)
+	require.Equal(make([]byte, len(eb)), eb)
+
+	// the challenge above the max difficulty is never solved, and the
+	// signature is recovered from the other signers
+	client.maxDifficulty = 4
+	nonce = 1236
+	sig, evicted, err = client.Sign(key, ephemeral, nonce, grace, "", "", watcher)
+	require.Nil(err)
+	require.Len(sig, 64)
+	require.Len(evicted, 1)
+	require.Equal("http://127.0.0.1:7024", evicted[0].API)
+	_, _, err = signWithChallenge(evicted[0], 4, nil, nil, 0, 0, nil, nil, "")
+	require.Equal(ErrChallengeTooHard, err)
+}
+
+func TestSignSecretRelease(t *testing.T) {
+	require := require.New(t)
+
+	suite, err := crypto.NewSuite(crypto.SuiteBN256)
+	require.NoError(err)
+	client := &Client{suite: suite, signers: testConfigurationJSON().Signers}
+	kb := []byte("8bee954d5315684caa46d78fb8456a165bdd0cb44643d335a6b15c21d8c1872b")
+	eb := []byte("invalid")
+	rb := []byte("2b5a6b0cb9576ea218d081baa14d2cea82a6839165a29b3bdfc6ef8582b0ce5a")
+	_, _, err = client.SignSecret(crypto.NewSecret(kb), crypto.NewSecret(eb), 1, 2, crypto.NewSecret(rb), nil, "")
+	require.Error(err)
+	require.Equal(make([]byte, len(kb)), kb)
+	require.Equal(make([]byte, len(eb)), eb)
+	require.Equal(make([]byte, len(rb)), rb)
+}
+
+func TestFreshness(t *testing.T) {
+	require := require.New(t)
+
+	req := &signRequest{
+		Identity:  "identity",
+		Data:      "data",
+		Signature: "signature",
+		Watcher:   "watcher",
+		Freshness: newFreshness(),
+		Session:   "session",
+	}
+	hash := api.RequestHash(&api.SignRequest{
+		Identity:  req.Identity,
+		Data:      req.Data,
+		Signature: req.Signature,
+		Watcher:   req.Watcher,
+		Freshness: req.Freshness,
+		Session:   req.Session,
+	})
+	require.Equal(hash, requestHash(req))
+
+	now := time.Now().Unix()
+	rd := &ResponseData{Freshness: req.Freshness, Request: hash, Timestamp: now}
+	require.Nil(checkFreshness(rd, req.Freshness, hash))
+	require.Equal(ErrStaleResponse, checkFreshness(rd, newFreshness(), hash))
+	require.Equal(ErrStaleResponse, checkFreshness(rd, req.Freshness, requestHash(&signRequest{})))
+	rd.Timestamp = now - int64(freshnessWindow.Seconds()) - 10
+	require.Equal(ErrStaleResponse, checkFreshness(rd, req.Freshness, hash))
+	rd.Timestamp = now + int64(freshnessWindow.Seconds()) + 10
+	require.Equal(ErrStaleResponse, checkFreshness(rd, req.Freshness, hash))
+}
+
+func TestNegotiateCipher(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(crypto.CipherLegacy, negotiateCipher(nil))
+	require.Equal(crypto.CipherLegacy, negotiateCipher([]int{crypto.CipherLegacy}))
+	require.Equal(crypto.CipherBound, negotiateCipher([]int{crypto.CipherLegacy, crypto.CipherBound}))
+	require.Equal(crypto.CipherSession, negotiateCipher([]int{crypto.CipherLegacy, crypto.CipherBound, crypto.CipherSession}))
+	require.Equal(crypto.CipherSession, negotiateCipher([]int{crypto.CipherSession + 1, crypto.CipherSession}))
+}
+
+func TestDetectSuite(t *testing.T) {
+	require := require.New(t)
+
+	bls, err := crypto.NewSuite(crypto.SuiteBLS12381)
+	require.NoError(err)
+	commitment := crypto.PublicKey(bls.Scalar().Pick(random.New()))
+
+	cli := &Client{commitments: []kyber.Point{commitment}}
+	require.False(cli.detectSuite(""))
+	require.False(cli.detectSuite("bn254"))
+	require.Nil(cli.suite)
+	require.True(cli.detectSuite(crypto.SuiteBLS12381))
+	require.Equal(crypto.SuiteBLS12381, crypto.SuiteName(cli.suite))
+	require.True(cli.detectSuite(crypto.SuiteBLS12381))
+	require.False(cli.detectSuite(crypto.SuiteBN256))
+
+	legacy, err := crypto.NewSuite(crypto.SuiteBN256)
+	require.NoError(err)
+	cli = &Client{commitments: []kyber.Point{crypto.PublicKey(legacy.Scalar().Pick(random.New()))}}
+	require.True(cli.detectSuite(""))
+	require.Equal(crypto.SuiteBN256, crypto.SuiteName(cli.suite))
+	require.False(cli.detectSuite(crypto.SuiteBLS12381))
+}
+
+func TestConfigurationTyped(t *testing.T) {
+	require := require.New(t)
+
+	legacy := testConfigurationJSON()
+	conf := testConfigurationJSON()
+	for i, c := range conf.Commitments {
+		point, err := crypto.PubKeyFromBase58(c)
+		require.NoError(err)
+		conf.Commitments[i] = encoding.EncodeCommitment(point)
+	}
+	point, err := crypto.PubKeyFromBase58(conf.Signers[0].Identity)
+	require.NoError(err)
+	conf.Signers[0].Identity = encoding.EncodePublicKey(point)
+	require.NoError(conf.validate())
+	require.Equal(legacy.Commitments, conf.Commitments)
+	for i, s := range conf.Signers {
+		require.Equal(legacy.Signers[i].Identity, s.Identity)
+	}
+
+	conf = testConfigurationJSON()
+	conf.Commitments[0] = encoding.EncodePublicKey(point)
+	require.Equal(ErrInvalidConfiguration, conf.validate())
+	conf = testConfigurationJSON()
+	conf.Signers[0].Identity = encoding.EncodeCommitment(point)
+	require.Equal(ErrInvalidConfiguration, conf.validate())
+}
+
+func TestSignSession(t *testing.T) {
+	require := require.New(t)
+
+	key, err := crypto.PrivateKeyFromHex("8bee954d5315684caa46d78fb8456a165bdd0cb44643d335a6b15c21d8c1872b")
+	require.Nil(err)
+	sp := *testConfigurationJSON().Signers[0]
+	watcher := "2b5a6b0cb9576ea218d081baa14d2cea82a6839165a29b3bdfc6ef8582b0ce5a"
+
+	sp.cipher = crypto.CipherBound
+	req, err := sign(key, &sp, crypto.NewSecret([]byte(watcher)), 1, 2, nil, nil, watcher, nil)
+	require.Nil(err)
+	require.Equal("", req.Session)
+	require.Equal(key, req.receiver(key))
+
+	sp.cipher = crypto.CipherSession
+	r1, err := sign(key, &sp, crypto.NewSecret([]byte(watcher)), 1, 2, nil, nil, watcher, nil)
+	require.Nil(err)
+	r2, err := sign(key, &sp, crypto.NewSecret([]byte(watcher)), 1, 2, nil, nil, watcher, nil)
+	require.Nil(err)
+	require.NotEqual("", r1.Session)
+	require.NotEqual(r1.Session, r2.Session)
+	require.NotEqual(key, r1.receiver(key))
+	require.Equal(crypto.PublicKeyString(crypto.PublicKey(r1.receiver(key))), r1.Session)
+	b, _ := json.Marshal(r1)
+	require.NotContains(string(b), hex.EncodeToString(crypto.PrivateKeyBytes(r1.session)))
 }
 
 func testConfigurationJSON() *Configuration {
@@ -64,7 +229,7 @@ func testConfigurationJSON() *Configuration {
 			},
 			{
 				Identity: "5HzufHDbh8kUj3oBiYWeEe4wamNMmQ4BZ5uZULxGsyKYULpWLUdzzBb73EExRDgUxZD5vu6iA61ds7QGSjeCWazSmpXv7sMaHfizSnHjxeoEy1TumWVqGJhtAAYwAJPzUbTdyzEGz5r9hRSYFAmHkhwCwLi8BoSk8V2scv6r7LdfphGbXSWSAV",
-				API:      "http://127.0.0.1:7023",
+				API:      "grpc://127.0.0.1:7123",
 			},
 			{
 				Identity: "5JRrcBgsnUVr8D7tdTHX8nZAbkpPD4C5TS82KEbBMiV3inVp1vSu4gBwB1WwhQFguGbmkgrvA2vmtfY6GXhyFnh4SRoEQT2jVNTsk91pcPUaZ8nQcEdDAUjKXCTFi6TPDYPYPUsAK67kUXEtyNocsYUijKdF9pGRKUk92Rk7iRuJ3eqADYH7NB",
@@ -96,7 +261,21 @@ func testRunServer(port int) {
 	ac.Signers = node.GetSigners()
 	ac.Poly = node.GetPoly()
 	ac.Share = node.GetShare()
-	server := api.NewServer(store, ac)
+	// one signer requires the challenge, which the client solves
+	if port == 7024 {
+		ac.ChallengeDifficulty = 8
+	}
+	hdr := api.NewHandler(store, ac)
+	// and another one is called with gRPC
+	if port == 7023 {
+		ac.GRPCPort = 7123
+		l, err := api.ListenGRPC(ac)
+		if err != nil {
+			panic(err)
+		}
+		go func() { _ = hdr.GRPCServer(nil).Serve(l) }()
+	}
+	server := hdr.HTTPServer()
 	err := server.ListenAndServe()
 	if err != nil {
 		panic(err)
@@ -124,7 +303,7 @@ func testBadgerStore(port int) *store.BadgerStorage {
 	if err != nil {
 		panic(err)
 	}
-	conf := &store.BadgerConfiguration{
+	conf := &store.Configuration{
 		Dir: dir,
 	}
 	bs, err := store.OpenBadger(context.Background(), conf)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/MixinNetwork/tip/logger"
 	"github.com/MixinNetwork/tip/messenger"
+	"github.com/MixinNetwork/tip/metrics"
 	"go.dedis.ch/kyber/v4"
 	"go.dedis.ch/kyber/v4/share/dkg/pedersen"
 )
@@ -17,6 +18,7 @@ type Board struct {
 	justs     chan dkg.JustificationBundle
 	ctx       context.Context
 	key       kyber.Scalar
+	version   int
 }
 
 func (node *Node) NewBoard(ctx context.Context, nonce uint64) *Board {
@@ -28,14 +30,23 @@ func (node *Node) NewBoard(ctx context.Context, nonce uint64) *Board {
 		justs:     make(chan dkg.JustificationBundle),
 		ctx:       ctx,
 		key:       node.key,
+		version:   node.version,
 	}
 }
 
 func (t *Board) PushDeals(db *dkg.DealBundle) {
 	data := encodeDealBundle(db, t.nonce)
-	msg := makeMessage(t.key, MessageActionDKGDeal, data)
+	msg := makeMessage(t.key, t.version, MessageActionDKGDeal, data)
 	err := t.messenger.BroadcastMessage(t.ctx, msg)
 	logger.Verbose("PushDeals", len(msg), err)
+	metrics.SignerMessage("sent", "deal", messageResult(err))
+}
+
+func messageResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
 }
 
 func (t *Board) IncomingDeal() <-chan dkg.DealBundle {
@@ -44,9 +55,10 @@ func (t *Board) IncomingDeal() <-chan dkg.DealBundle {
 
 func (t *Board) PushResponses(rb *dkg.ResponseBundle) {
 	data := encodeResponseBundle(rb)
-	msg := makeMessage(t.key, MessageActionDKGResponse, data)
+	msg := makeMessage(t.key, t.version, MessageActionDKGResponse, data)
 	err := t.messenger.BroadcastMessage(t.ctx, msg)
 	logger.Verbose("PushResponses", len(msg), err)
+	metrics.SignerMessage("sent", "response", messageResult(err))
 }
 
 func (t *Board) IncomingResponse() <-chan dkg.ResponseBundle {
@@ -55,9 +67,10 @@ func (t *Board) IncomingResponse() <-chan dkg.ResponseBundle {
 
 func (t *Board) PushJustifications(jb *dkg.JustificationBundle) {
 	data := encodeJustificationBundle(jb)
-	msg := makeMessage(t.key, MessageActionDKGJustify, data)
+	msg := makeMessage(t.key, t.version, MessageActionDKGJustify, data)
 	err := t.messenger.BroadcastMessage(t.ctx, msg)
 	logger.Verbose("PushJustifications", len(msg), err)
+	metrics.SignerMessage("sent", "justify", messageResult(err))
 }
 
 func (t *Board) IncomingJustification() <-chan dkg.JustificationBundle {
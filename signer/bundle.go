@@ -6,7 +6,6 @@ import (
 	"fmt"
 
 	"github.com/MixinNetwork/tip/crypto"
-	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/share/dkg/pedersen"
 )
 
@@ -27,7 +26,7 @@ func encodeJustificationBundle(jb *dkg.JustificationBundle) []byte {
 	return enc.buf.Bytes()
 }
 
-func decodeJustificationBundle(b []byte) (*dkg.JustificationBundle, error) {
+func decodeJustificationBundle(suite crypto.Suite, b []byte) (*dkg.JustificationBundle, error) {
 	jb := &dkg.JustificationBundle{}
 	dec := NewDecoder(b)
 
@@ -41,7 +40,6 @@ func decodeJustificationBundle(b []byte) (*dkg.JustificationBundle, error) {
 	if err != nil {
 		return nil, err
 	}
-	suite := bn256.NewSuiteG2()
 	for ; jl > 0; jl-- {
 		si, err := dec.ReadUint32()
 		if err != nil {
@@ -159,7 +157,7 @@ func encodeDealBundle(db *dkg.DealBundle, nonce uint64) []byte {
 	return enc.buf.Bytes()
 }
 
-func decodeDealBundle(b []byte) (uint64, *dkg.DealBundle, error) {
+func decodeDealBundle(suite crypto.Suite, b []byte) (uint64, *dkg.DealBundle, error) {
 	db := &dkg.DealBundle{}
 	dec := NewDecoder(b)
 
@@ -202,7 +200,7 @@ func decodeDealBundle(b []byte) (uint64, *dkg.DealBundle, error) {
 		if err != nil {
 			return 0, nil, err
 		}
-		point, err := crypto.PubKeyFromBytes(pb)
+		point, err := crypto.PubKeyFromBytesWithSuite(suite, pb)
 		if err != nil {
 			return 0, nil, err
 		}
@@ -19,6 +19,20 @@ const (
 	MessageSetupPeriodSeconds = 300
 )
 
+func messageActionName(action int) string {
+	switch action {
+	case MessageActionSetup:
+		return "setup"
+	case MessageActionDKGDeal:
+		return "deal"
+	case MessageActionDKGResponse:
+		return "response"
+	case MessageActionDKGJustify:
+		return "justify"
+	}
+	return "unknown"
+}
+
 type Message struct {
 	Action    int
 	Sender    string
@@ -56,12 +70,12 @@ func decodeSetupBundle(b []byte) (*SetupBundle, error) {
 	return sb, nil
 }
 
-func MakeSetupMessage(ctx context.Context, key kyber.Scalar, nonce uint64) []byte {
+func MakeSetupMessage(ctx context.Context, key kyber.Scalar, version int, nonce uint64) []byte {
 	data := encodeSetupBundle(&SetupBundle{
 		Nonce:     nonce,
 		Timestamp: time.Now(),
 	})
-	return makeMessage(key, MessageActionSetup, data)
+	return makeMessage(key, version, MessageActionSetup, data)
 }
 
 func (node *Node) handleSetupMessage(ctx context.Context, msg *Message) error {
@@ -94,7 +108,7 @@ func (node *Node) handleSetupMessage(ctx context.Context, msg *Message) error {
 	return nil
 }
 
-func makeMessage(key kyber.Scalar, action int, data []byte) []byte {
+func makeMessage(key kyber.Scalar, version, action int, data []byte) []byte {
 	point := crypto.PublicKey(key)
 	msg := &Message{
 		Action: action,
@@ -102,7 +116,7 @@ func makeMessage(key kyber.Scalar, action int, data []byte) []byte {
 		Data:   data,
 	}
 	b := encodeMessage(msg)
-	sig, err := crypto.Sign(key, b)
+	sig, err := crypto.SignDomain(key, crypto.DomainBoard, version, b)
 	if err != nil {
 		panic(err)
 	}
@@ -161,7 +175,11 @@ func (node *Node) verifyMessage(msg *Message) error {
 		Data:   msg.Data,
 	})
 
-	return crypto.Verify(sender, b, msg.Signature)
+	// the legacy signers may still sign the raw messages in the transition
+	if node.version == crypto.SignatureLegacy && crypto.Verify(sender, b, msg.Signature) == nil {
+		return nil
+	}
+	return crypto.VerifyDomain(sender, crypto.DomainBoard, crypto.SignatureDomain, b, msg.Signature)
 }
 
 func (node *Node) checkSigner(sender string) kyber.Point {
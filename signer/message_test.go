@@ -46,7 +46,7 @@ func TestMakeSetupMessageProducesVerifiableMessage(t *testing.T) {
 		},
 	}
 
-	msg, err := decodeMessage(MakeSetupMessage(context.Background(), key, 99))
+	msg, err := decodeMessage(MakeSetupMessage(context.Background(), key, crypto.SignatureLegacy, 99))
 	require.NoError(err)
 	require.Equal(MessageActionSetup, msg.Action)
 	require.Equal(crypto.PublicKeyString(pub), msg.Sender)
@@ -58,12 +58,32 @@ func TestMakeSetupMessageProducesVerifiableMessage(t *testing.T) {
 	require.WithinDuration(time.Now(), sb.Timestamp, 2*time.Second)
 }
 
+func TestVerifyMessageSignatureVersions(t *testing.T) {
+	require := require.New(t)
+
+	key := signerTestScalar()
+	signers := []dkg.Node{{Index: 0, Public: crypto.PublicKey(key)}}
+	legacy, err := decodeMessage(MakeSetupMessage(context.Background(), key, crypto.SignatureLegacy, 7))
+	require.NoError(err)
+	domain, err := decodeMessage(MakeSetupMessage(context.Background(), key, crypto.SignatureDomain, 7))
+	require.NoError(err)
+	require.NotEqual(legacy.Signature, domain.Signature)
+
+	transition := &Node{signers: signers, version: crypto.SignatureLegacy}
+	require.NoError(transition.verifyMessage(legacy))
+	require.NoError(transition.verifyMessage(domain))
+
+	strict := &Node{signers: signers, version: crypto.SignatureDomain}
+	require.Error(strict.verifyMessage(legacy))
+	require.NoError(strict.verifyMessage(domain))
+}
+
 func TestVerifyMessageRejectsUnauthorizedOrTamperedMessages(t *testing.T) {
 	require := require.New(t)
 
 	key := signerTestScalar()
 	pub := crypto.PublicKey(key)
-	msg, err := decodeMessage(MakeSetupMessage(context.Background(), key, 7))
+	msg, err := decodeMessage(MakeSetupMessage(context.Background(), key, crypto.SignatureLegacy, 7))
 	require.NoError(err)
 
 	authorized := &Node{signers: []dkg.Node{{Index: 0, Public: pub}}}
@@ -97,7 +117,7 @@ func TestDealBundleRoundTrip(t *testing.T) {
 		Signature: []byte("signature"),
 	}
 
-	nonce, decoded, err := decodeDealBundle(encodeDealBundle(db, 123))
+	nonce, decoded, err := decodeDealBundle(bn256.NewSuiteG2(), encodeDealBundle(db, 123))
 	require.NoError(err)
 	require.Equal(uint64(123), nonce)
 	require.Equal(db.DealerIndex, decoded.DealerIndex)
@@ -122,7 +142,7 @@ func TestDecodeDealBundleRejectsInvalidPublicKey(t *testing.T) {
 	enc.WriteFixedBytes([]byte("session"))
 	enc.WriteFixedBytes([]byte("signature"))
 
-	_, _, err := decodeDealBundle(enc.buf.Bytes())
+	_, _, err := decodeDealBundle(bn256.NewSuiteG2(), enc.buf.Bytes())
 	require.Error(err)
 }
 
@@ -154,7 +174,7 @@ func TestResponseAndJustificationBundleRoundTrip(t *testing.T) {
 		SessionID: []byte("session"),
 		Signature: []byte("signature"),
 	}
-	decodedJustifications, err := decodeJustificationBundle(encodeJustificationBundle(jb))
+	decodedJustifications, err := decodeJustificationBundle(bn256.NewSuiteG2(), encodeJustificationBundle(jb))
 	require.NoError(err)
 	require.Equal(jb.DealerIndex, decodedJustifications.DealerIndex)
 	require.Equal(jb.SessionID, decodedJustifications.SessionID)
@@ -8,8 +8,10 @@ import (
 	"slices"
 
 	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/crypto/encoding"
 	"github.com/MixinNetwork/tip/logger"
 	"github.com/MixinNetwork/tip/messenger"
+	"github.com/MixinNetwork/tip/metrics"
 	"github.com/MixinNetwork/tip/store"
 	"go.dedis.ch/kyber/v4"
 	"go.dedis.ch/kyber/v4/share"
@@ -19,6 +21,17 @@ import (
 type Configuration struct {
 	Key     string   `toml:"key"`
 	Signers []string `toml:"signers"`
+
+	// the signature version of the board messages, the version 0 signs the
+	// legacy raw messages and accepts both, and the version 1 only accepts
+	// the domain separated messages, so all signers upgrade with 0, then
+	// switch to 1
+	SignatureVersion int `toml:"signature_version"`
+
+	// the pairing suite of the network, bn256 or bls12381, which is bound
+	// to the group and can't be changed after the DKG, and the empty suite
+	// is the bn256 of the older networks
+	Suite string `toml:"suite"`
 }
 
 type Node struct {
@@ -30,8 +43,10 @@ type Node struct {
 	dkgDone      context.CancelFunc
 	board        *Board
 
+	suite    crypto.Suite
 	key      kyber.Scalar
 	identity kyber.Point
+	version  int
 	index    int
 	signers  []dkg.Node
 	phaser   chan dkg.Phase
@@ -50,30 +65,33 @@ func NewNode(ctx context.Context, cancel context.CancelFunc, store store.Storage
 		phaser:       make(chan dkg.Phase),
 		index:        -1,
 	}
-	scalar, err := crypto.PrivateKeyFromHex(conf.Key)
+	suite, err := crypto.NewSuite(conf.Suite)
+	if err != nil {
+		panic(err)
+	}
+	node.suite = suite
+	scalar, err := encoding.ParsePrivateKey(suite, conf.Key)
 	if err != nil {
 		panic(conf.Key)
 	}
 	node.key = scalar
 	node.identity = crypto.PublicKey(scalar)
-	var group []byte
-	slices.Sort(conf.Signers)
-	for i, s := range conf.Signers {
-		point, err := crypto.PubKeyFromBase58(s)
-		if err != nil {
-			panic(s)
-		}
-		group = append(group, crypto.PublicKeyBytes(point)...)
-		node.signers = append(node.signers, dkg.Node{
-			Index:  uint32(i),
-			Public: point,
-		})
-		if node.identity.Equal(point) {
+	err = crypto.CheckSignatureVersion(conf.SignatureVersion)
+	if err != nil {
+		panic(err)
+	}
+	node.version = conf.SignatureVersion
+	signers, err := parseSigners(suite, conf.Signers)
+	if err != nil {
+		panic(err)
+	}
+	node.signers = signers
+	for i, s := range signers {
+		if node.identity.Equal(s.Public) {
 			node.index = i
 		}
 	}
-	groupId := sha3.Sum256(group)
-	valid, err := store.CheckPolyGroup(groupId[:])
+	valid, err := store.CheckPolyGroup(groupHash(suite, node.signers))
 	if err != nil || !valid {
 		panic(fmt.Errorf("group check failed %v %v", valid, err))
 	}
@@ -88,23 +106,83 @@ func NewNode(ctx context.Context, cancel context.CancelFunc, store store.Storage
 		panic(err)
 	} else if len(poly) > 0 {
 		logger.Infof("Poly public: %s\n", hex.EncodeToString(poly))
-		node.poly = unmarshalCommitments(poly)
+		node.poly = unmarshalCommitments(suite, poly)
 	}
 
 	priv, err := store.ReadPolyShare()
 	if err != nil {
 		panic(err)
 	} else if len(priv) > 0 {
-		logger.Infof("Poly share: %s\n", hex.EncodeToString(priv))
-		node.share = unmarshalPrivShare(priv)
+		node.share = unmarshalPrivShare(suite, priv)
+		clear(priv)
+		// only the index, the share itself must never be logged
+		logger.Infof("Poly share: %d\n", node.share.I)
+	}
+	if node.share != nil && node.poly != nil {
+		metrics.DKGPhase(int(dkg.FinishPhase))
 	}
 	return node
 }
 
+// GroupHash returns the poly group of the signers and the suite, which is
+// checked against the database to ensure the node never runs with a
+// different signers list or suite.
+func GroupHash(conf *Configuration) ([]byte, error) {
+	suite, err := crypto.NewSuite(conf.Suite)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := parseSigners(suite, conf.Signers)
+	if err != nil {
+		return nil, err
+	}
+	return groupHash(suite, nodes), nil
+}
+
+// parseSigners accepts the typed or legacy keys, and sorts them by the
+// legacy base58 keys, so all nodes have the same indexes with either form.
+func parseSigners(suite crypto.Suite, signers []string) ([]dkg.Node, error) {
+	keys := make([]string, len(signers))
+	for i, s := range signers {
+		point, err := encoding.ParsePublicKeyWithSuite(suite, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer %s", s)
+		}
+		keys[i] = crypto.PublicKeyString(point)
+	}
+	slices.Sort(keys)
+	nodes := make([]dkg.Node, len(keys))
+	for i, k := range keys {
+		point, _ := crypto.PubKeyFromBase58(k)
+		nodes[i] = dkg.Node{Index: uint32(i), Public: point}
+	}
+	return nodes, nil
+}
+
+// groupHash is unchanged for the bn256 groups made before the suites, and
+// the other suites are prefixed.
+func groupHash(suite crypto.Suite, signers []dkg.Node) []byte {
+	var group []byte
+	if name := crypto.SuiteName(suite); name != crypto.SuiteBN256 {
+		group = append([]byte(name), 0)
+	}
+	for _, s := range signers {
+		group = append(group, crypto.PublicKeyBytes(s.Public)...)
+	}
+	sum := sha3.Sum256(group)
+	return sum[:]
+}
+
 func (node *Node) GetKey() kyber.Scalar {
 	return node.key
 }
 
+// Release wipes the private key and share, and the node can't be used after.
+func (node *Node) Release() {
+	crypto.WipeScalar(node.key)
+	crypto.WipeShare(node.share)
+}
+
 func (node *Node) GetSigners() []dkg.Node {
 	return node.signers
 }
@@ -117,6 +195,10 @@ func (node *Node) GetPoly() []kyber.Point {
 	return node.poly
 }
 
+func (node *Node) GetGroup() []byte {
+	return groupHash(node.suite, node.signers)
+}
+
 func (node *Node) Run(ctx context.Context) error {
 	if node.share != nil || node.poly != nil {
 		return nil
@@ -129,19 +211,22 @@ func (node *Node) Run(ctx context.Context) error {
 		msg, err := decodeMessage(b)
 		if err != nil {
 			logger.Errorf("msg decode error %d %s", len(b), err)
+			metrics.SignerMessage("received", "unknown", "invalid")
 			continue
 		}
 		err = node.verifyMessage(msg)
 		if err != nil {
 			logger.Errorf("msg verify error %d %s", len(b), err)
+			metrics.SignerMessage("received", messageActionName(msg.Action), "invalid")
 			continue
 		}
+		metrics.SignerMessage("received", messageActionName(msg.Action), "ok")
 		switch msg.Action {
 		case MessageActionSetup:
 			err = node.handleSetupMessage(ctx, msg)
 			logger.Verbose("SETUP", err)
 		case MessageActionDKGDeal:
-			nonce, db, err := decodeDealBundle(msg.Data)
+			nonce, db, err := decodeDealBundle(node.suite, msg.Data)
 			logger.Verbose("DEAL", nonce, err)
 			if err != nil {
 				continue
@@ -156,7 +241,7 @@ func (node *Node) Run(ctx context.Context) error {
 			node.counter += 1
 			logger.Verbose("DEAL COUNTER", node.counter)
 			if node.counter+1 == len(node.signers) {
-				node.phaser <- dkg.ResponsePhase
+				node.nextPhase(dkg.ResponsePhase)
 				node.counter = 0
 			}
 		case MessageActionDKGResponse:
@@ -169,11 +254,11 @@ func (node *Node) Run(ctx context.Context) error {
 			node.counter += 1
 			logger.Verbose("RESPONSE COUNTER", node.counter)
 			if node.counter+1 == len(node.signers) {
-				node.phaser <- dkg.JustifPhase
+				node.nextPhase(dkg.JustifPhase)
 				node.counter = 0
 			}
 		case MessageActionDKGJustify:
-			jb, err := decodeJustificationBundle(msg.Data)
+			jb, err := decodeJustificationBundle(node.suite, msg.Data)
 			logger.Verbose("JUSTIFICATION", err)
 			if err != nil || node.board == nil {
 				continue
@@ -182,13 +267,20 @@ func (node *Node) Run(ctx context.Context) error {
 			node.counter += 1
 			logger.Verbose("JUSTIFICATION COUNTER", node.counter)
 			if node.counter+1 == len(node.signers) {
-				node.phaser <- dkg.FinishPhase
+				node.nextPhase(dkg.FinishPhase)
 				node.counter = 0
 			}
 		}
 	}
 }
 
+// nextPhase sets the phase gauge before it's accepted by the protocol, so
+// a stuck DKG shows the phase it's waiting for.
+func (node *Node) nextPhase(phase dkg.Phase) {
+	metrics.DKGPhase(int(phase))
+	node.phaser <- phase
+}
+
 func (node *Node) Threshold() int {
 	return len(node.signers)*2/3 + 1
 }
@@ -3,18 +3,21 @@ package signer
 import (
 	"bytes"
 	"context"
+	"crypto/sha3"
 	"encoding/hex"
 	"errors"
 	"testing"
 	"time"
 
 	"github.com/MixinNetwork/tip/crypto"
+	"github.com/MixinNetwork/tip/crypto/encoding"
 	"github.com/MixinNetwork/tip/messenger"
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/kyber/v4"
 	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/share"
 	"go.dedis.ch/kyber/v4/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v4/util/random"
 )
 
 type signerStoreStub struct {
@@ -36,6 +39,7 @@ func newSignerStoreStub() *signerStoreStub {
 func (s *signerStoreStub) CheckPolyGroup(group []byte) (bool, error) {
 	return s.checkPolyGroupFn(group)
 }
+func (*signerStoreStub) ReadPolyGroup() ([]byte, error)         { return nil, nil }
 func (s *signerStoreStub) ReadPolyPublic() ([]byte, error)      { return s.readPolyPublicFn() }
 func (s *signerStoreStub) ReadPolyShare() ([]byte, error)       { return s.readPolyShareFn() }
 func (s *signerStoreStub) WritePoly(public, share []byte) error { return s.writePolyFn(public, share) }
@@ -52,6 +56,8 @@ func (*signerStoreStub) RotateEphemeralNonce([]byte, []byte, uint64) error { ret
 func (*signerStoreStub) WriteSignRequest([]byte, []byte) (time.Time, int, error) {
 	return time.Time{}, 0, nil
 }
+func (*signerStoreStub) WriteSignAudit([]byte, []byte, uint64, int) error { return nil }
+func (*signerStoreStub) Ping() error                                      { return nil }
 func (*signerStoreStub) Watch([]byte) ([]byte, time.Time, int, error) {
 	return nil, time.Time{}, 0, nil
 }
@@ -135,6 +141,101 @@ func TestNewNodeGettersAndThreshold(t *testing.T) {
 	require.True(node.signers[node.index].Public.Equal(crypto.PublicKey(self)))
 }
 
+func TestNodeRelease(t *testing.T) {
+	require := require.New(t)
+
+	self := signerTestScalar()
+	ps := &share.PriShare{I: 0, V: signerTestScalar()}
+	shareBytes := marshalPrivShare(ps)
+	store := newSignerStoreStub()
+	store.checkPolyGroupFn = func([]byte) (bool, error) { return true, nil }
+	store.readPolyShareFn = func() ([]byte, error) { return shareBytes, nil }
+	conf := &Configuration{
+		Key:     hex.EncodeToString(crypto.PrivateKeyBytes(self)),
+		Signers: []string{crypto.PublicKeyString(crypto.PublicKey(self))},
+	}
+
+	node := NewNode(context.Background(), func() {}, store, &signerMessengerStub{}, conf)
+	require.Equal(make([]byte, len(shareBytes)), shareBytes)
+	require.True(node.GetShare().V.Equal(ps.V))
+	require.True(node.GetKey().Equal(self))
+
+	zero := self.Clone().Zero()
+	node.Release()
+	require.True(node.GetKey().Equal(zero))
+	require.True(node.GetShare().V.Equal(zero))
+	require.Equal(make([]byte, len(crypto.PrivateKeyBytes(self))), crypto.PrivateKeyBytes(node.GetKey()))
+}
+
+func TestNewNodeSuite(t *testing.T) {
+	require := require.New(t)
+
+	suite, err := crypto.NewSuite(crypto.SuiteBLS12381)
+	require.NoError(err)
+	self := suite.Scalar().Pick(random.New())
+	other := suite.Scalar().Pick(random.New())
+	share := &share.PriShare{I: 0, V: suite.Scalar().Pick(random.New())}
+	poly := []kyber.Point{crypto.PublicKey(suite.Scalar().Pick(random.New()))}
+
+	var checkedGroup []byte
+	store := newSignerStoreStub()
+	store.checkPolyGroupFn = func(group []byte) (bool, error) {
+		checkedGroup = append([]byte(nil), group...)
+		return true, nil
+	}
+	store.readPolyPublicFn = func() ([]byte, error) { return marshalCommitments(poly), nil }
+	store.readPolyShareFn = func() ([]byte, error) { return marshalPrivShare(share), nil }
+
+	conf := &Configuration{
+		Key:   hex.EncodeToString(crypto.PrivateKeyBytes(self)),
+		Suite: crypto.SuiteBLS12381,
+		Signers: []string{
+			crypto.PublicKeyString(crypto.PublicKey(self)),
+			crypto.PublicKeyString(crypto.PublicKey(other)),
+		},
+	}
+	node := NewNode(context.Background(), func() {}, store, &signerMessengerStub{}, conf)
+	require.True(node.GetShare().V.Equal(share.V))
+	require.Len(node.GetPoly(), 1)
+	require.True(node.GetPoly()[0].Equal(poly[0]))
+
+	group, err := GroupHash(conf)
+	require.NoError(err)
+	require.Equal(checkedGroup, group)
+	require.Equal(group, node.GetGroup())
+
+	// the signers must be of the suite, which is bound to the group
+	_, err = GroupHash(&Configuration{Signers: conf.Signers})
+	require.Error(err)
+	_, err = GroupHash(&Configuration{Signers: conf.Signers, Suite: "bn254"})
+	require.Error(err)
+
+	// the typed keys have the same group and indexes as the legacy keys
+	typed := make([]string, len(conf.Signers))
+	for i, k := range conf.Signers {
+		point, err := crypto.PubKeyFromBase58(k)
+		require.NoError(err)
+		typed[i] = encoding.EncodePublicKey(point)
+	}
+	tg, err := GroupHash(&Configuration{Signers: typed, Suite: conf.Suite})
+	require.NoError(err)
+	require.Equal(group, tg)
+	typed[0] = encoding.EncodeCommitment(crypto.PublicKey(self))
+	_, err = GroupHash(&Configuration{Signers: typed, Suite: conf.Suite})
+	require.Error(err)
+
+	// the bn256 group is unchanged for the networks before the suites
+	pub := crypto.PublicKey(signerTestScalar())
+	legacy, err := GroupHash(&Configuration{Signers: []string{crypto.PublicKeyString(pub)}})
+	require.NoError(err)
+	sum := sha3.Sum256(crypto.PublicKeyBytes(pub))
+	require.Equal(sum[:], legacy)
+	conf.Suite = ""
+	require.Panics(func() {
+		NewNode(context.Background(), func() {}, store, &signerMessengerStub{}, conf)
+	})
+}
+
 func TestBoardPushAndIncomingChannels(t *testing.T) {
 	require := require.New(t)
 
@@ -164,7 +265,7 @@ func TestBoardPushAndIncomingChannels(t *testing.T) {
 	msg, err := decodeMessage(msgs.broadcasted[0])
 	require.NoError(err)
 	require.Equal(MessageActionDKGDeal, msg.Action)
-	nonce, decodedDeal, err := decodeDealBundle(msg.Data)
+	nonce, decodedDeal, err := decodeDealBundle(bn256.NewSuiteG2(), msg.Data)
 	require.NoError(err)
 	require.Equal(uint64(55), nonce)
 	require.Equal(deal.DealerIndex, decodedDeal.DealerIndex)
@@ -179,7 +280,7 @@ func TestBoardPushAndIncomingChannels(t *testing.T) {
 	msg, err = decodeMessage(msgs.broadcasted[2])
 	require.NoError(err)
 	require.Equal(MessageActionDKGJustify, msg.Action)
-	decodedJust, err := decodeJustificationBundle(msg.Data)
+	decodedJust, err := decodeJustificationBundle(bn256.NewSuiteG2(), msg.Data)
 	require.NoError(err)
 	require.Equal(just.DealerIndex, decodedJust.DealerIndex)
 }
@@ -243,7 +344,7 @@ func TestMarshalHelpersRunDKGAndSetup(t *testing.T) {
 
 	priv := &share.PriShare{I: 4, V: signerTestScalar()}
 	encodedPriv := marshalPrivShare(priv)
-	decodedPriv := unmarshalPrivShare(encodedPriv)
+	decodedPriv := unmarshalPrivShare(bn256.NewSuiteG2(), encodedPriv)
 	require.Equal(priv.I, decodedPriv.I)
 	require.Equal(crypto.PrivateKeyBytes(priv.V), crypto.PrivateKeyBytes(decodedPriv.V))
 
@@ -252,13 +353,13 @@ func TestMarshalHelpersRunDKGAndSetup(t *testing.T) {
 		crypto.PublicKey(signerTestScalar()),
 	}
 	encodedCommits := marshalCommitments(commits)
-	decodedCommits := unmarshalCommitments(encodedCommits)
+	decodedCommits := unmarshalCommitments(bn256.NewSuiteG2(), encodedCommits)
 	require.Len(decodedCommits, len(commits))
 	for i := range commits {
 		require.True(decodedCommits[i].Equal(commits[i]))
 	}
 	require.Panics(func() {
-		unmarshalCommitments(bytes.Repeat([]byte{1}, 128))
+		unmarshalCommitments(bn256.NewSuiteG2(), bytes.Repeat([]byte{1}, 128))
 	})
 
 	node := &Node{
@@ -282,12 +383,13 @@ func TestMarshalHelpersRunDKGAndSetup(t *testing.T) {
 	})
 
 	runNode := &Node{index: 1}
+	resShare := &share.PriShare{I: 1, V: signerTestScalar()}
 	waitDKGResult = func(*dkg.Protocol) <-chan dkg.OptionResult {
 		ch := make(chan dkg.OptionResult, 1)
 		ch <- dkg.OptionResult{Result: &dkg.Result{
 			Key: &dkg.DistKeyShare{
 				Commits: commits,
-				Share:   &share.PriShare{I: 1, V: signerTestScalar()},
+				Share:   resShare,
 			},
 		}}
 		return ch
@@ -296,6 +398,7 @@ func TestMarshalHelpersRunDKGAndSetup(t *testing.T) {
 	require.NoError(err)
 	require.Equal(marshalCommitments(commits), pub)
 	require.NotEmpty(shareBytes)
+	require.True(resShare.V.Equal(resShare.V.Clone().Zero()))
 
 	waitDKGResult = func(*dkg.Protocol) <-chan dkg.OptionResult {
 		ch := make(chan dkg.OptionResult, 1)
@@ -349,6 +452,7 @@ func TestMarshalHelpersRunDKGAndSetup(t *testing.T) {
 	setupNode = &Node{
 		store:     setupStore,
 		messenger: &signerMessengerStub{},
+		suite:     bn256.NewSuiteG2(),
 		key:       signerTestScalar(),
 		signers:   []dkg.Node{{Index: 0, Public: crypto.PublicKey(signerTestScalar())}},
 		phaser:    make(chan dkg.Phase, 1),
@@ -372,6 +476,7 @@ func TestMarshalHelpersRunDKGAndSetup(t *testing.T) {
 	setupNode = &Node{
 		store:     setupStore,
 		messenger: &signerMessengerStub{},
+		suite:     bn256.NewSuiteG2(),
 		key:       signerTestScalar(),
 		signers:   []dkg.Node{{Index: 0, Public: crypto.PublicKey(signerTestScalar())}},
 		phaser:    make(chan dkg.Phase, 1),
@@ -411,19 +516,19 @@ func TestRunPaths(t *testing.T) {
 	msgs := &signerMessengerStub{
 		received: []receivedMessage{
 			{body: []byte{1, 2, 3}},
-			{body: MakeSetupMessage(context.Background(), signerTestScalar(), 1)},
-			{body: MakeSetupMessage(context.Background(), peer, 2)},
-			{body: makeMessage(peer, MessageActionDKGDeal, encodeDealBundle(&dkg.DealBundle{
+			{body: MakeSetupMessage(context.Background(), signerTestScalar(), crypto.SignatureLegacy, 1)},
+			{body: MakeSetupMessage(context.Background(), peer, crypto.SignatureLegacy, 2)},
+			{body: makeMessage(peer, crypto.SignatureLegacy, MessageActionDKGDeal, encodeDealBundle(&dkg.DealBundle{
 				DealerIndex: 1,
 				SessionID:   []byte("deal"),
 				Signature:   []byte("sig"),
 			}, 9))},
-			{body: makeMessage(peer, MessageActionDKGResponse, encodeResponseBundle(&dkg.ResponseBundle{
+			{body: makeMessage(peer, crypto.SignatureLegacy, MessageActionDKGResponse, encodeResponseBundle(&dkg.ResponseBundle{
 				ShareIndex: 1,
 				SessionID:  []byte("resp"),
 				Signature:  []byte("sig"),
 			}))},
-			{body: makeMessage(peer, MessageActionDKGJustify, encodeJustificationBundle(&dkg.JustificationBundle{
+			{body: makeMessage(peer, crypto.SignatureLegacy, MessageActionDKGJustify, encodeJustificationBundle(&dkg.JustificationBundle{
 				DealerIndex: 1,
 				SessionID:   []byte("just"),
 				Signature:   []byte("sig"),
@@ -482,17 +587,17 @@ func TestRunSkipsSetupAndBoardErrors(t *testing.T) {
 	peer := signerTestScalar()
 	msgs := &signerMessengerStub{
 		received: []receivedMessage{
-			{body: makeMessage(peer, MessageActionDKGDeal, encodeDealBundle(&dkg.DealBundle{
+			{body: makeMessage(peer, crypto.SignatureLegacy, MessageActionDKGDeal, encodeDealBundle(&dkg.DealBundle{
 				DealerIndex: 1,
 				SessionID:   []byte("deal"),
 				Signature:   []byte("sig"),
 			}, 5))},
-			{body: makeMessage(peer, MessageActionDKGResponse, encodeResponseBundle(&dkg.ResponseBundle{
+			{body: makeMessage(peer, crypto.SignatureLegacy, MessageActionDKGResponse, encodeResponseBundle(&dkg.ResponseBundle{
 				ShareIndex: 1,
 				SessionID:  []byte("resp"),
 				Signature:  []byte("sig"),
 			}))},
-			{body: makeMessage(peer, MessageActionDKGJustify, encodeJustificationBundle(&dkg.JustificationBundle{
+			{body: makeMessage(peer, crypto.SignatureLegacy, MessageActionDKGJustify, encodeJustificationBundle(&dkg.JustificationBundle{
 				DealerIndex: 1,
 				SessionID:   []byte("just"),
 				Signature:   []byte("sig"),
@@ -10,7 +10,6 @@ import (
 	"github.com/MixinNetwork/tip/crypto"
 	"github.com/MixinNetwork/tip/logger"
 	"go.dedis.ch/kyber/v4"
-	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/share"
 	"go.dedis.ch/kyber/v4/share/dkg/pedersen"
 	"go.dedis.ch/kyber/v4/sign/bdn"
@@ -39,7 +38,7 @@ func (node *Node) setup(ctx context.Context, nonce uint64) error {
 		return err
 	}
 
-	suite := bn256.NewSuiteG2()
+	suite := node.suite
 	conf := &dkg.Config{
 		Suite:     suite,
 		Threshold: uint32(node.Threshold()),
@@ -56,15 +55,16 @@ func (node *Node) setup(ctx context.Context, nonce uint64) error {
 	if err != nil {
 		return err
 	}
-	node.phaser <- dkg.DealPhase
+	node.nextPhase(dkg.DealPhase)
 	go func() {
 		defer node.dkgDone()
 		pub, priv, err = runDKGProtocol(node, ctx, protocol)
-		logger.Verbose("runDKG", hex.EncodeToString(pub), hex.EncodeToString(priv), err)
+		logger.Verbose("runDKG", hex.EncodeToString(pub), len(priv), err)
 		if err != nil {
 			panic(err)
 		}
 		err = node.store.WritePoly(pub, priv)
+		clear(priv)
 		if err != nil {
 			panic(err)
 		}
@@ -86,14 +86,16 @@ func (node *Node) runDKG(_ context.Context, protocol *dkg.Protocol) ([]byte, []b
 	if i := res.Key.PriShare().I; int(i) != node.index {
 		return nil, nil, fmt.Errorf("private share index malformed %d %d", node.index, i)
 	}
-	priv := marshalPrivShare(res.Key.PriShare())
+	ps := res.Key.PriShare()
+	priv := marshalPrivShare(ps)
+	crypto.WipeShare(ps)
 	pub := marshalCommitments(res.Key.Commitments())
 	return pub, priv, nil
 }
 
-func unmarshalPrivShare(b []byte) *share.PriShare {
+func unmarshalPrivShare(suite crypto.Suite, b []byte) *share.PriShare {
 	var ps share.PriShare
-	ps.V = bn256.NewSuiteG2().Scalar().SetBytes(b[4:])
+	ps.V = suite.Scalar().SetBytes(b[4:])
 	ps.I = binary.BigEndian.Uint32(b[:4])
 	return &ps
 }
@@ -102,13 +104,15 @@ func marshalPrivShare(ps *share.PriShare) []byte {
 	var buf [4]byte
 	binary.BigEndian.PutUint32(buf[:], ps.I)
 	b := crypto.PrivateKeyBytes(ps.V)
+	defer clear(b)
 	return append(buf[:], b...)
 }
 
-func unmarshalCommitments(b []byte) []kyber.Point {
+func unmarshalCommitments(suite crypto.Suite, b []byte) []kyber.Point {
 	var commits []kyber.Point
-	for i, l := 0, len(b)/128; i < l; i++ {
-		point, err := crypto.PubKeyFromBytes(b[i*128 : (i+1)*128])
+	size := suite.PointLen()
+	for i, l := 0, len(b)/size; i < l; i++ {
+		point, err := crypto.PubKeyFromBytesWithSuite(suite, b[i*size:(i+1)*size])
 		if err != nil {
 			panic(err)
 		}
@@ -128,6 +132,9 @@ func marshalCommitments(commits []kyber.Point) []byte {
 
 func (node *Node) getNonce(nonce uint64) []byte {
 	var data []byte
+	if name := crypto.SuiteName(node.suite); name != crypto.SuiteBN256 {
+		data = append([]byte(name), 0)
+	}
 	for _, s := range node.signers {
 		b := crypto.PublicKeyBytes(s.Public)
 		data = append(data, b...)
@@ -0,0 +1,322 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha3"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/tip/logger"
+)
+
+// Admin is implemented by the database, and by the AdminClient to reach
+// the database opened by another process.
+type Admin interface {
+	Backup(w io.Writer) (uint64, error)
+	InspectIdentity(key []byte) (*IdentityState, error)
+	ResetLimit(key []byte, audit *AdminAction) (*AdminAction, error)
+	ClearNonce(key []byte, audit *AdminAction) (*AdminAction, error)
+	ListAdminActions() ([]*AdminAction, error)
+	ReadSignAudits(offset uint64, limit int) ([]*SignAudit, *SignAudit, error)
+}
+
+// The admin socket lets the operator commands reach the database while the
+// signer or API process holds the exclusive directory lock. It's a unix
+// socket only accessible by the process owner, there is no other auth.
+func (bs *BadgerStorage) serveAdmin(path string) error {
+	// the badger directory lock is held, so no other process uses this socket
+	l, err := listenUnix(path)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /backup", bs.handleAdminBackup)
+	mux.HandleFunc("GET /identity", bs.handleAdminIdentity)
+	mux.HandleFunc("GET /actions", bs.handleAdminActions)
+	mux.HandleFunc("POST /actions", bs.handleAdminAction)
+	mux.HandleFunc("GET /audits", bs.handleAdminAudits)
+	bs.admin = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := bs.admin.Serve(l)
+		if err != http.ErrServerClosed {
+			logger.Error("store.serveAdmin", path, err)
+		}
+	}()
+	return nil
+}
+
+// listenUnix removes the stale socket file left by a crashed process, so
+// it must be called with the database lock held.
+func listenUnix(path string) (net.Listener, error) {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := listenSocket(path, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, 0o600)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (bs *BadgerStorage) closeAdmin() {
+	if bs.admin == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := bs.admin.Shutdown(ctx)
+	if err != nil {
+		logger.Error("store.closeAdmin", err)
+	}
+}
+
+// The backup is streamed after the status sent, so the result is in the
+// trailers, and the client must check the length and checksum of the body.
+const (
+	adminTrailerVersion  = "Tip-Backup-Version"
+	adminTrailerLength   = "Tip-Backup-Length"
+	adminTrailerChecksum = "Tip-Backup-Checksum"
+	adminTrailerError    = "Tip-Backup-Error"
+)
+
+func (bs *BadgerStorage) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Trailer", strings.Join([]string{adminTrailerVersion, adminTrailerLength, adminTrailerChecksum, adminTrailerError}, ", "))
+	bw := newBackupWriter(w)
+	version, err := bs.Backup(bw)
+	if err != nil {
+		w.Header().Set(adminTrailerError, err.Error())
+	} else {
+		w.Header().Set(adminTrailerVersion, strconv.FormatUint(version, 10))
+		w.Header().Set(adminTrailerLength, strconv.FormatInt(bw.length, 10))
+		w.Header().Set(adminTrailerChecksum, bw.checksum())
+	}
+	logger.Info("store.handleAdminBackup", version, bw.length, err)
+}
+
+// backupWriter counts and hashes the backup stream.
+type backupWriter struct {
+	w      io.Writer
+	hash   hash.Hash
+	length int64
+}
+
+func newBackupWriter(w io.Writer) *backupWriter {
+	return &backupWriter{w: w, hash: sha3.New256()}
+}
+
+func (bw *backupWriter) Write(p []byte) (int, error) {
+	n, err := bw.w.Write(p)
+	bw.hash.Write(p[:n])
+	bw.length += int64(n)
+	return n, err
+}
+
+func (bw *backupWriter) checksum() string {
+	return hex.EncodeToString(bw.hash.Sum(nil))
+}
+
+func (bs *BadgerStorage) handleAdminIdentity(w http.ResponseWriter, r *http.Request) {
+	key, err := hex.DecodeString(r.URL.Query().Get("key"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	state, err := bs.InspectIdentity(key)
+	writeAdminResponse(w, state, err)
+}
+
+func (bs *BadgerStorage) handleAdminAction(w http.ResponseWriter, r *http.Request) {
+	var audit AdminAction
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&audit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var action *AdminAction
+	switch audit.Action {
+	case AdminActionResetLimit:
+		action, err = bs.ResetLimit(audit.Key, &audit)
+	case AdminActionClearNonce:
+		action, err = bs.ClearNonce(audit.Key, &audit)
+	default:
+		err = fmt.Errorf("invalid admin action %s", audit.Action)
+	}
+	logger.Info("store.handleAdminAction", audit.Action, hex.EncodeToString(audit.Key), audit.Operator, audit.Reason, err)
+	writeAdminResponse(w, action, err)
+}
+
+func (bs *BadgerStorage) handleAdminActions(w http.ResponseWriter, r *http.Request) {
+	actions, err := bs.ListAdminActions()
+	writeAdminResponse(w, actions, err)
+}
+
+type adminSignAudits struct {
+	Audits []*SignAudit `json:"audits"`
+	Head   *SignAudit   `json:"head"`
+}
+
+func (bs *BadgerStorage) handleAdminAudits(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseUint(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 1000 {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	audits, head, err := bs.ReadSignAudits(offset, limit)
+	writeAdminResponse(w, &adminSignAudits{Audits: audits, Head: head}, err)
+}
+
+func writeAdminResponse(w http.ResponseWriter, data any, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// AdminClient implements the Admin interface through the admin socket of
+// the process which opens the database.
+type AdminClient struct {
+	path string
+}
+
+func NewAdminClient(path string) *AdminClient {
+	return &AdminClient{path: path}
+}
+
+// Ping checks whether some process is serving the admin socket.
+func (ac *AdminClient) Ping() error {
+	conn, err := net.DialTimeout("unix", ac.path, time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (ac *AdminClient) Backup(w io.Writer) (uint64, error) {
+	res, err := ac.request(http.MethodGet, "/backup", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	bw := newBackupWriter(w)
+	_, err = io.Copy(bw, res.Body)
+	if err != nil {
+		return 0, err
+	}
+	if msg := res.Trailer.Get(adminTrailerError); msg != "" {
+		return 0, fmt.Errorf("admin backup error %s", msg)
+	}
+	length := res.Trailer.Get(adminTrailerLength)
+	if length != strconv.FormatInt(bw.length, 10) {
+		return 0, fmt.Errorf("admin backup length %d not match %s", bw.length, length)
+	}
+	checksum := res.Trailer.Get(adminTrailerChecksum)
+	if checksum != bw.checksum() {
+		return 0, fmt.Errorf("admin backup checksum %s not match %s", bw.checksum(), checksum)
+	}
+	return strconv.ParseUint(res.Trailer.Get(adminTrailerVersion), 10, 64)
+}
+
+func (ac *AdminClient) InspectIdentity(key []byte) (*IdentityState, error) {
+	var state *IdentityState
+	err := ac.call(http.MethodGet, "/identity?key="+hex.EncodeToString(key), nil, &state)
+	return state, err
+}
+
+func (ac *AdminClient) ResetLimit(key []byte, audit *AdminAction) (*AdminAction, error) {
+	audit.Action, audit.Key = AdminActionResetLimit, key
+	var action *AdminAction
+	err := ac.call(http.MethodPost, "/actions", audit, &action)
+	return action, err
+}
+
+func (ac *AdminClient) ClearNonce(key []byte, audit *AdminAction) (*AdminAction, error) {
+	audit.Action, audit.Key = AdminActionClearNonce, key
+	var action *AdminAction
+	err := ac.call(http.MethodPost, "/actions", audit, &action)
+	return action, err
+}
+
+func (ac *AdminClient) ListAdminActions() ([]*AdminAction, error) {
+	var actions []*AdminAction
+	err := ac.call(http.MethodGet, "/actions", nil, &actions)
+	return actions, err
+}
+
+func (ac *AdminClient) ReadSignAudits(offset uint64, limit int) ([]*SignAudit, *SignAudit, error) {
+	var res adminSignAudits
+	uri := fmt.Sprintf("/audits?offset=%d&limit=%d", offset, limit)
+	err := ac.call(http.MethodGet, uri, nil, &res)
+	return res.Audits, res.Head, err
+}
+
+func (ac *AdminClient) call(method, uri string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	res, err := ac.request(method, uri, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (ac *AdminClient) request(method, uri string, body io.Reader) (*http.Response, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", ac.path)
+			},
+		},
+	}
+	req, err := http.NewRequest(method, "http://admin"+uri, body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		return nil, fmt.Errorf("admin %s %s error %d %s", method, uri, res.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return res, nil
+}
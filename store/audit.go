@@ -0,0 +1,275 @@
+package store
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha3"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	badgerKeyPrefixAudit = "AUDIT#SIGN#"
+	badgerKeyAuditHead   = "AUDIT#HEAD"
+
+	signAuditBatchSize = 256
+)
+
+// SignAudit is appended for each partial signature produced by api.sign,
+// and each record hashes the previous one, so any modified, removed or
+// reordered record breaks the chain from that point. The MAC of each record
+// is keyed by the audit key derived from the node key, so the chain and its
+// head could not be recomputed by someone with only the database.
+type SignAudit struct {
+	Sequence uint64 `json:"sequence"`
+	// sha3 of the assignor, the signer never logs the identity itself
+	Assignor  []byte    `json:"assignor"`
+	Watcher   []byte    `json:"watcher"`
+	Nonce     uint64    `json:"nonce"`
+	Counter   int       `json:"counter"`
+	CreatedAt time.Time `json:"created_at"`
+	Previous  []byte    `json:"previous"`
+	Hash      []byte    `json:"hash"`
+	MAC       []byte    `json:"mac"`
+}
+
+func newSignAudit(key, head []byte, assignor, watcher []byte, nonce uint64, counter int, now time.Time) *SignAudit {
+	audit := &SignAudit{
+		Watcher:   watcher,
+		Nonce:     nonce,
+		Counter:   counter,
+		CreatedAt: time.Unix(0, now.UnixNano()),
+		Previous:  make([]byte, 32),
+	}
+	ah := sha3.Sum256(assignor)
+	audit.Assignor = ah[:]
+	if len(head) == 40 {
+		audit.Sequence = binary.BigEndian.Uint64(head[:8]) + 1
+		audit.Previous = head[8:]
+	}
+	audit.Hash = audit.digest()
+	audit.MAC = audit.mac(key)
+	return audit
+}
+
+func (a *SignAudit) digest() []byte {
+	msg := uint64ToBytes(a.Sequence)
+	msg = append(msg, a.Previous...)
+	msg = append(msg, a.Assignor...)
+	msg = append(msg, uint64ToBytes(uint64(len(a.Watcher)))...)
+	msg = append(msg, a.Watcher...)
+	msg = append(msg, uint64ToBytes(a.Nonce)...)
+	msg = append(msg, uint64ToBytes(uint64(a.Counter))...)
+	msg = append(msg, uint64ToBytes(uint64(a.CreatedAt.UnixNano()))...)
+	h := sha3.Sum256(msg)
+	return h[:]
+}
+
+func (a *SignAudit) mac(key []byte) []byte {
+	h := hmac.New(func() hash.Hash { return sha3.New256() }, key)
+	h.Write(a.head())
+	return h.Sum(nil)
+}
+
+func (a *SignAudit) head() []byte {
+	return append(uint64ToBytes(a.Sequence), a.Hash...)
+}
+
+func (a *SignAudit) key() []byte {
+	return append([]byte(badgerKeyPrefixAudit), uint64ToBytes(a.Sequence)...)
+}
+
+// SignAuditVerifier checks the records one by one in sequence order, so an
+// export could be verified without loading all records at once.
+type SignAuditVerifier struct {
+	key      []byte
+	next     uint64
+	previous []byte
+}
+
+func NewSignAuditVerifier(key []byte) *SignAuditVerifier {
+	return &SignAuditVerifier{key: key, previous: make([]byte, 32)}
+}
+
+func (v *SignAuditVerifier) Verify(a *SignAudit) error {
+	if a.Sequence != v.next {
+		return fmt.Errorf("sign audit sequence %d not match %d", a.Sequence, v.next)
+	}
+	if !bytes.Equal(a.Previous, v.previous) {
+		return fmt.Errorf("sign audit %d previous %x not match %x", a.Sequence, a.Previous, v.previous)
+	}
+	if !bytes.Equal(a.Hash, a.digest()) {
+		return fmt.Errorf("sign audit %d hash %x not match", a.Sequence, a.Hash)
+	}
+	if !hmac.Equal(a.MAC, a.mac(v.key)) {
+		return fmt.Errorf("sign audit %d mac %x not match", a.Sequence, a.MAC)
+	}
+	v.next, v.previous = a.Sequence+1, a.Hash
+	return nil
+}
+
+// Count is the number of records verified.
+func (v *SignAuditVerifier) Count() uint64 {
+	return v.next
+}
+
+// VerifyHead checks that the last verified record is the chain head, so
+// records truncated from the end are detected.
+func (v *SignAuditVerifier) VerifyHead(head *SignAudit) error {
+	if head == nil {
+		if v.next != 0 {
+			return fmt.Errorf("sign audit head missing after %d", v.next)
+		}
+		return nil
+	}
+	if head.Sequence+1 != v.next || !bytes.Equal(head.Hash, v.previous) {
+		return fmt.Errorf("sign audit head %d not match %d", head.Sequence, v.next)
+	}
+	if !hmac.Equal(head.MAC, head.mac(v.key)) {
+		return fmt.Errorf("sign audit head %d mac %x not match", head.Sequence, head.MAC)
+	}
+	return nil
+}
+
+// signAuditAppend is a record queued to the single writer of the chain.
+type signAuditAppend struct {
+	assignor []byte
+	watcher  []byte
+	nonce    uint64
+	counter  int
+	done     chan error
+}
+
+// WriteSignAudit queues the record to the single writer, which owns the
+// chain head, so the concurrent sign requests never conflict on it, and
+// returns after the record committed.
+func (bs *BadgerStorage) WriteSignAudit(assignor, watcher []byte, nonce uint64, counter int) error {
+	if bs.audits == nil {
+		return fmt.Errorf("sign audit writer not running")
+	}
+	a := &signAuditAppend{
+		assignor: assignor,
+		watcher:  watcher,
+		nonce:    nonce,
+		counter:  counter,
+		done:     make(chan error, 1),
+	}
+	select {
+	case bs.audits <- a:
+	case <-bs.done:
+		return fmt.Errorf("store closed")
+	}
+	return <-a.done
+}
+
+// loopSignAudits appends all the records queued meanwhile in one txn.
+func (bs *BadgerStorage) loopSignAudits() {
+	defer bs.wg.Done()
+
+	for {
+		var batch []*signAuditAppend
+		select {
+		case <-bs.done:
+			return
+		case a := <-bs.audits:
+			batch = append(batch, a)
+		}
+	drain:
+		for len(batch) < signAuditBatchSize {
+			select {
+			case a := <-bs.audits:
+				batch = append(batch, a)
+			default:
+				break drain
+			}
+		}
+		err := bs.appendSignAudits(batch)
+		for _, a := range batch {
+			a.done <- err
+		}
+	}
+}
+
+func (bs *BadgerStorage) appendSignAudits(batch []*signAuditAppend) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		head, err := readKey(txn, badgerKeyAuditHead, nil)
+		if err != nil {
+			return err
+		}
+		for _, a := range batch {
+			audit := newSignAudit(bs.conf.AuditKey, head, a.assignor, a.watcher, a.nonce, a.counter, bs.clock.Now())
+			val, err := json.Marshal(audit)
+			if err != nil {
+				return err
+			}
+			err = txn.Set(audit.key(), val)
+			if err != nil {
+				return err
+			}
+			head = audit.head()
+		}
+		return txn.Set([]byte(badgerKeyAuditHead), head)
+	})
+}
+
+// ReadSignAudits returns at most limit records from the offset sequence,
+// and the current head, which is nil when no record written yet.
+func (bs *BadgerStorage) ReadSignAudits(offset uint64, limit int) ([]*SignAudit, *SignAudit, error) {
+	txn := bs.db.NewTransaction(false)
+	defer txn.Discard()
+
+	head, err := readSignAuditHead(txn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prefix := []byte(badgerKeyPrefixAudit)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var audits []*SignAudit
+	start := append(prefix, uint64ToBytes(offset)...)
+	for it.Seek(start); it.ValidForPrefix(prefix) && len(audits) < limit; it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		var audit SignAudit
+		err = json.Unmarshal(v, &audit)
+		if err != nil {
+			return nil, nil, err
+		}
+		audits = append(audits, &audit)
+	}
+	return audits, head, nil
+}
+
+func readSignAuditHead(txn *badger.Txn) (*SignAudit, error) {
+	hb, err := readKey(txn, badgerKeyAuditHead, nil)
+	if err != nil || len(hb) != 40 {
+		return nil, err
+	}
+	v, err := readKey(txn, badgerKeyPrefixAudit, hb[:8])
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("sign audit head %x missing", hb)
+	}
+	var audit SignAudit
+	err = json.Unmarshal(v, &audit)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(audit.Hash, hb[8:]) {
+		return nil, fmt.Errorf("sign audit head %x not match %x", hb, audit.Hash)
+	}
+	return &audit, nil
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"crypto/sha3"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAuditChain(t *testing.T) {
+	require := require.New(t)
+
+	key := sha3.Sum256([]byte("audit key"))
+	conf := &Configuration{Dir: t.TempDir(), Clock: newTestClock(), AuditKey: key[:]}
+	bs, err := OpenBadger(context.Background(), conf)
+	require.NoError(err)
+	defer bs.Close()
+
+	audits, head, err := bs.ReadSignAudits(0, 10)
+	require.NoError(err)
+	require.Len(audits, 0)
+	require.Nil(head)
+	require.NoError(NewSignAuditVerifier(key[:]).VerifyHead(head))
+
+	for i := range 5 {
+		err = bs.WriteSignAudit([]byte("assignor"), []byte("watcher"), uint64(i+10), i+1)
+		require.NoError(err)
+	}
+	audits, head, err = bs.ReadSignAudits(0, 10)
+	require.NoError(err)
+	require.Len(audits, 5)
+	require.Equal(uint64(4), head.Sequence)
+	require.NotEqual([]byte("assignor"), audits[0].Assignor)
+	verifier := NewSignAuditVerifier(key[:])
+	for _, a := range audits {
+		require.NoError(verifier.Verify(a))
+	}
+	require.NoError(verifier.VerifyHead(head))
+	require.Equal(uint64(5), verifier.Count())
+
+	page, _, err := bs.ReadSignAudits(3, 1)
+	require.NoError(err)
+	require.Len(page, 1)
+	require.Equal(audits[3].Hash, page[0].Hash)
+
+	// a truncated log is detected by the head
+	verifier = NewSignAuditVerifier(key[:])
+	for _, a := range audits[:4] {
+		require.NoError(verifier.Verify(a))
+	}
+	require.ErrorContains(verifier.VerifyHead(head), "sign audit head 4 not match 4")
+
+	// a modified record breaks its own hash, and a removed one the sequence
+	tampered := *audits[2]
+	tampered.Counter = 100
+	val, err := json.Marshal(&tampered)
+	require.NoError(err)
+	err = bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(tampered.key(), val)
+	})
+	require.NoError(err)
+	audits, _, err = bs.ReadSignAudits(0, 10)
+	require.NoError(err)
+	verifier = NewSignAuditVerifier(key[:])
+	require.NoError(verifier.Verify(audits[0]))
+	require.NoError(verifier.Verify(audits[1]))
+	require.ErrorContains(verifier.Verify(audits[2]), "sign audit 2 hash")
+
+	verifier = NewSignAuditVerifier(key[:])
+	require.NoError(verifier.Verify(audits[0]))
+	require.ErrorContains(verifier.Verify(audits[2]), "sign audit sequence 2 not match 1")
+
+	// a record recomputed without the audit key is rejected, and so is a
+	// forged head, or a verifier without the key
+	forged := *audits[2]
+	forged.Hash = forged.digest()
+	forged.MAC = forged.mac([]byte("forged"))
+	verifier = NewSignAuditVerifier(key[:])
+	require.NoError(verifier.Verify(audits[0]))
+	require.NoError(verifier.Verify(audits[1]))
+	require.ErrorContains(verifier.Verify(&forged), "sign audit 2 mac")
+	head = audits[1]
+	head.MAC = head.mac([]byte("forged"))
+	require.ErrorContains(verifier.VerifyHead(head), "sign audit head 1 mac")
+	require.ErrorContains(NewSignAuditVerifier(nil).Verify(audits[0]), "sign audit 0 mac")
+}
+
+func TestSignAuditConcurrent(t *testing.T) {
+	require := require.New(t)
+
+	key := sha3.Sum256([]byte("audit key"))
+	bs, err := OpenBadger(context.Background(), &Configuration{Dir: t.TempDir(), AuditKey: key[:]})
+	require.NoError(err)
+
+	// the concurrent appends never conflict on the head
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- bs.WriteSignAudit([]byte("assignor"), []byte("watcher"), uint64(i), 1)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(err)
+	}
+
+	audits, head, err := bs.ReadSignAudits(0, 1000)
+	require.NoError(err)
+	require.Len(audits, 100)
+	verifier := NewSignAuditVerifier(key[:])
+	for _, a := range audits {
+		require.NoError(verifier.Verify(a))
+	}
+	require.NoError(verifier.VerifyHead(head))
+
+	bs.Close()
+	require.ErrorContains(bs.WriteSignAudit([]byte("assignor"), []byte("watcher"), 100, 1), "store closed")
+	ro, err := OpenBadgerReadOnly(&Configuration{Dir: bs.conf.Dir})
+	require.NoError(err)
+	defer ro.Close()
+	require.ErrorContains(ro.WriteSignAudit([]byte("assignor"), []byte("watcher"), 100, 1), "not running")
+}
+
+func TestSignAuditEngines(t *testing.T) {
+	require := require.New(t)
+
+	key := sha3.Sum256([]byte("audit key"))
+	boltConf := &Configuration{Engine: EngineBolt, Dir: t.TempDir(), AuditKey: key[:]}
+	db, err := Open(context.Background(), boltConf)
+	require.NoError(err)
+	for i := range 3 {
+		err = db.WriteSignAudit([]byte("assignor"), []byte("watcher"), uint64(i), 1)
+		require.NoError(err)
+	}
+	db.Close()
+
+	// the chain written by bolt is verified after converted to badger
+	badgerConf := &Configuration{Dir: t.TempDir(), AuditKey: key[:]}
+	_, err = ConvertStorage(context.Background(), boltConf, badgerConf)
+	require.NoError(err)
+	bs, err := OpenBadger(context.Background(), badgerConf)
+	require.NoError(err)
+	defer bs.Close()
+	err = bs.WriteSignAudit([]byte("assignor"), []byte("watcher"), 3, 1)
+	require.NoError(err)
+
+	audits, head, err := bs.ReadSignAudits(0, 10)
+	require.NoError(err)
+	require.Len(audits, 4)
+	verifier := NewSignAuditVerifier(key[:])
+	for _, a := range audits {
+		require.NoError(verifier.Verify(a))
+	}
+	require.NoError(verifier.VerifyHead(head))
+
+	db, err = Open(context.Background(), &Configuration{Engine: EngineMemory, AuditKey: key[:]})
+	require.NoError(err)
+	ms := db.(*memoryDatabase).MemoryStorage
+	for i := range 3 {
+		require.NoError(ms.WriteSignAudit([]byte("assignor"), []byte("watcher"), uint64(i), 1))
+	}
+	verifier = NewSignAuditVerifier(key[:])
+	for _, a := range ms.audits {
+		require.NoError(verifier.Verify(a))
+	}
+}
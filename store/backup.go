@@ -0,0 +1,80 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Backup streams a consistent snapshot of all records at the current read
+// timestamp, so it's safe to run while the API is serving.
+func (bs *BadgerStorage) Backup(w io.Writer) (uint64, error) {
+	return bs.db.Backup(w, 0)
+}
+
+// RestoreBadger loads the backup to an empty directory, and only accepts
+// the data when the restored poly group matches the configured signers.
+// A failed restore removes only what it created, and keeps the directory
+// if it existed before.
+func RestoreBadger(ctx context.Context, conf *Configuration, r io.Reader, group []byte) error {
+	entries, err := os.ReadDir(conf.Dir)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	} else if len(entries) > 0 {
+		return fmt.Errorf("restore directory %s not empty", conf.Dir)
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(conf.Dir))
+	if err != nil {
+		return err
+	}
+	err = restoreBadger(db, r, group)
+	if err != nil {
+		db.Close()
+		cleanRestore(conf.Dir, existed)
+		return err
+	}
+	err = db.Close()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = MigrateBadger(ctx, conf, false)
+	return err
+}
+
+func cleanRestore(dir string, existed bool) {
+	if !existed {
+		os.RemoveAll(dir)
+		return
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		os.RemoveAll(filepath.Join(dir, e.Name()))
+	}
+}
+
+func restoreBadger(db *badger.DB, r io.Reader, group []byte) error {
+	err := db.Load(r, 256)
+	if err != nil {
+		return err
+	}
+
+	txn := db.NewTransaction(false)
+	defer txn.Discard()
+
+	old, err := readKey(txn, badgerKeyPolyGroup, nil)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(old, group) {
+		return fmt.Errorf("restored poly group %x not match %x", old, group)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerBackupAndRestore(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("/tmp", "tip-backup-test")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "admin.sock")
+	conf := &Configuration{Dir: filepath.Join(dir, "db"), AdminSocket: socket}
+	bs, err := OpenBadger(ctx, conf)
+	require.NoError(err)
+
+	group := []byte("group")
+	valid, err := bs.CheckPolyGroup(group)
+	require.NoError(err)
+	require.True(valid)
+	err = bs.WritePoly([]byte("public"), []byte("share"))
+	require.NoError(err)
+
+	fi, err := os.Stat(socket)
+	require.NoError(err)
+	require.Equal(os.FileMode(0o600), fi.Mode().Perm())
+
+	var buf bytes.Buffer
+	client := NewAdminClient(socket)
+	require.NoError(client.Ping())
+	version, err := client.Backup(&buf)
+	require.NoError(err)
+	require.Greater(version, uint64(0))
+	require.Greater(buf.Len(), 0)
+	bs.Close()
+	_, err = os.Stat(socket)
+	require.True(os.IsNotExist(err))
+	require.Error(client.Ping())
+
+	// the offline backup never writes the database
+	ro, err := OpenBadgerReadOnly(conf)
+	require.NoError(err)
+	var offline bytes.Buffer
+	_, err = ro.Backup(&offline)
+	require.NoError(err)
+	require.Greater(offline.Len(), 0)
+	require.Error(ro.WritePoly([]byte("public"), []byte("other")))
+	ro.Close()
+
+	err = RestoreBadger(ctx, conf, bytes.NewReader(buf.Bytes()), group)
+	require.ErrorContains(err, "not empty")
+
+	invalid := &Configuration{Dir: filepath.Join(dir, "invalid")}
+	err = RestoreBadger(ctx, invalid, bytes.NewReader(buf.Bytes()), []byte("other"))
+	require.ErrorContains(err, "not match")
+	_, err = os.Stat(invalid.Dir)
+	require.True(os.IsNotExist(err))
+
+	// the empty directory existed is kept, and only emptied again
+	require.NoError(os.Mkdir(invalid.Dir, 0o700))
+	err = RestoreBadger(ctx, invalid, bytes.NewReader(buf.Bytes()), []byte("other"))
+	require.ErrorContains(err, "not match")
+	entries, err := os.ReadDir(invalid.Dir)
+	require.NoError(err)
+	require.Len(entries, 0)
+
+	restored := &Configuration{Dir: filepath.Join(dir, "restored")}
+	err = RestoreBadger(ctx, restored, bytes.NewReader(buf.Bytes()), group)
+	require.NoError(err)
+	bs, err = OpenBadger(ctx, restored)
+	require.NoError(err)
+	defer bs.Close()
+	share, err := bs.ReadPolyShare()
+	require.NoError(err)
+	require.Equal([]byte("share"), share)
+	valid, err = bs.CheckPolyGroup(group)
+	require.NoError(err)
+	require.True(valid)
+}
+
+func TestAdminBackupTrailer(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := os.MkdirTemp("/tmp", "tip-backup-test")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "admin.sock")
+	l, err := listenUnix(socket)
+	require.NoError(err)
+
+	var trailers map[string]string
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k := range trailers {
+			w.Header().Add("Trailer", k)
+		}
+		bw := newBackupWriter(w)
+		_, _ = bw.Write([]byte("backup"))
+		for k, v := range trailers {
+			w.Header().Set(k, v)
+		}
+	})}
+	go server.Serve(l)
+	defer server.Close()
+
+	checksum := newBackupWriter(io.Discard)
+	_, _ = checksum.Write([]byte("backup"))
+	client := NewAdminClient(socket)
+	trailers = map[string]string{
+		adminTrailerVersion:  "9",
+		adminTrailerLength:   "6",
+		adminTrailerChecksum: checksum.checksum(),
+	}
+	var buf bytes.Buffer
+	version, err := client.Backup(&buf)
+	require.NoError(err)
+	require.Equal(uint64(9), version)
+	require.Equal("backup", buf.String())
+
+	trailers = map[string]string{adminTrailerError: "backup failed"}
+	_, err = client.Backup(io.Discard)
+	require.ErrorContains(err, "admin backup error backup failed")
+
+	// the stream cut off has no trailers
+	trailers = nil
+	_, err = client.Backup(io.Discard)
+	require.ErrorContains(err, "admin backup length 6 not match")
+
+	trailers = map[string]string{
+		adminTrailerVersion:  "9",
+		adminTrailerLength:   "6",
+		adminTrailerChecksum: "invalid",
+	}
+	_, err = client.Backup(io.Discard)
+	require.ErrorContains(err, "admin backup checksum")
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -20,69 +22,126 @@ const (
 	badgerKeyPrefixWatcher  = "WATCHER#"
 	badgerKeyPrefixLimit    = "LIMIT#"
 	badgerKeyPrefixNonce    = "NONCE#"
+	badgerKeyPrefixGrace    = "GRACE#"
 	badgerKeyPrefixGenesis  = "GENESIS#"
 	badgerKeyPrefixCounter  = "COUNTER#"
+	badgerKeyPing           = "PING#"
 	maxUint64               = ^uint64(0)
 )
 
-type BadgerConfiguration struct {
-	Dir string `toml:"dir"`
-}
-
 type BadgerStorage struct {
-	db *badger.DB
+	db    *badger.DB
+	conf  *Configuration
+	clock Clock
+	admin *http.Server
+	// the sign audits are appended by a single writer
+	audits chan *signAuditAppend
+	done   chan struct{}
+	wg     sync.WaitGroup
 }
 
 func (bs *BadgerStorage) CheckLimit(key []byte, window time.Duration, quota uint32, increase bool) (int, error) {
-	now := uint64(time.Now().UnixNano())
+	clock := bs.clock.Now()
+	now := uint64(clock.UnixNano())
 	if now >= maxUint64/2 || now <= uint64(window) {
-		panic(time.Now())
+		return 0, fmt.Errorf("invalid clock %s for window %s", clock, window)
 	}
 	now = maxUint64 - now
 	threshold := now + uint64(window)
 	available := quota
 
 	prefix := append([]byte(badgerKeyPrefixLimit), key...)
+	count := func(ts uint64) {
+		if ts <= threshold {
+			available = max(available, 1) - 1
+		}
+	}
+	if !increase {
+		err := bs.db.View(func(txn *badger.Txn) error {
+			_, err := scanLimitEntries(txn, prefix, clock, count)
+			return err
+		})
+		return int(available), err
+	}
+
 	err := bs.db.Update(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		opts.Prefix = prefix
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Seek(prefix); available > 0 && it.ValidForPrefix(prefix); it.Next() {
-			ts := it.Item().Key()[len(prefix):]
-			if binary.BigEndian.Uint64(ts) > threshold {
-				break
+		expired, err := scanLimitEntries(txn, prefix, clock, count)
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			err := txn.Delete(k)
+			if err != nil {
+				return err
 			}
-			available--
 		}
-		if available == 0 || !increase {
+		if available == 0 {
 			return nil
 		}
 
+		// the TTL removes the entries never checked again, while the clock
+		// expiry makes the window independent of the badger wall time
 		available--
-		buf := uint64ToBytes(now)
-		entry := badger.NewEntry(append(prefix, buf...), []byte{1})
-		entry = entry.WithTTL(window * 2)
-		return txn.SetEntry(entry)
+		expiry := uint64(clock.Add(window * 2).Unix())
+		entry := badger.NewEntry(append(prefix, uint64ToBytes(now)...), uint64ToBytes(expiry))
+		return txn.SetEntry(entry.WithTTL(window * 2))
 	})
 	return int(available), err
 }
 
+// scanLimitEntries calls fn with the inverted timestamp of each entry, and
+// returns the keys expired by the clock, the same as the bolt layout, the
+// value is the expiry in unix seconds. All entries have the badger TTL too,
+// and the ones written before have the value 1.
+func scanLimitEntries(txn *badger.Txn, prefix []byte, clock time.Time, fn func(ts uint64)) ([][]byte, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var expired [][]byte
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		k := item.Key()
+		if len(k) < len(prefix)+8 {
+			continue
+		}
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) == 8 && binary.BigEndian.Uint64(v) <= uint64(clock.Unix()) {
+			expired = append(expired, item.KeyCopy(nil))
+			continue
+		}
+		fn(binary.BigEndian.Uint64(k[len(prefix):]))
+	}
+	return expired, nil
+}
+
+// CheckEphemeralNonce records the grace of the request with the nonce, so
+// the sweep never removes a nonce still in the grace the client asked.
 func (bs *BadgerStorage) CheckEphemeralNonce(key, ephemeral []byte, nonce uint64, grace time.Duration) (bool, error) {
 	var valid bool
-	now := time.Now().UnixNano()
+	now := bs.clock.Now().UnixNano()
 	val := uint64ToBytes(uint64(now))
 	val = append(val, ephemeral...)
 	buf := uint64ToBytes(nonce)
 	val = append(val, buf...)
+	gkey := append([]byte(badgerKeyPrefixGrace), key...)
 	key = append([]byte(badgerKeyPrefixNonce), key...)
 	err := bs.db.Update(func(txn *badger.Txn) error {
+		set := func() error {
+			valid = true
+			err := txn.Set(key, val)
+			if err != nil {
+				return err
+			}
+			return txn.Set(gkey, uint64ToBytes(uint64(grace)))
+		}
 		item, err := txn.Get(key)
 		if err == badger.ErrKeyNotFound {
-			valid = true
-			return txn.Set(key, val)
+			return set()
 		} else if err != nil {
 			return err
 		}
@@ -92,8 +151,7 @@ func (bs *BadgerStorage) CheckEphemeralNonce(key, ephemeral []byte, nonce uint64
 		}
 		old := binary.BigEndian.Uint64(v[:8])
 		if old+uint64(grace) < uint64(now) {
-			valid = true
-			return txn.Set(key, val)
+			return set()
 		}
 		if !bytes.Equal(v[8:len(v)-8], ephemeral) {
 			return nil
@@ -102,14 +160,13 @@ func (bs *BadgerStorage) CheckEphemeralNonce(key, ephemeral []byte, nonce uint64
 		if old >= nonce {
 			return nil
 		}
-		valid = true
-		return txn.Set(key, val)
+		return set()
 	})
 	return valid, err
 }
 
 func (bs *BadgerStorage) RotateEphemeralNonce(key, ephemeral []byte, nonce uint64) error {
-	now := time.Now().UnixNano()
+	now := bs.clock.Now().UnixNano()
 	key = append([]byte(badgerKeyPrefixNonce), key...)
 
 	val := uint64ToBytes(uint64(now))
@@ -146,6 +203,20 @@ func (bs *BadgerStorage) CheckPolyGroup(group []byte) (bool, error) {
 	return valid, err
 }
 
+func (bs *BadgerStorage) ReadPolyGroup() ([]byte, error) {
+	txn := bs.db.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get([]byte(badgerKeyPolyGroup))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
 func (bs *BadgerStorage) ReadPolyShare() ([]byte, error) {
 	txn := bs.db.NewTransaction(false)
 	defer txn.Discard()
@@ -300,7 +371,7 @@ func (bs *BadgerStorage) WriteSignRequest(assignor, watcher []byte) (time.Time,
 		} else if old != nil {
 			genesis = time.Unix(0, int64(binary.BigEndian.Uint64(old)))
 		} else {
-			genesis = time.Now()
+			genesis = bs.clock.Now()
 		}
 
 		key := append([]byte(badgerKeyPrefixGenesis), assignor...)
@@ -329,23 +400,96 @@ func (bs *BadgerStorage) WriteSignRequest(assignor, watcher []byte) (time.Time,
 	return genesis, counter, err
 }
 
-func OpenBadger(ctx context.Context, conf *BadgerConfiguration) (*BadgerStorage, error) {
+func OpenBadger(ctx context.Context, conf *Configuration) (*BadgerStorage, error) {
+	err := conf.validate()
+	if err != nil {
+		return nil, err
+	}
 	db, err := badger.Open(badger.DefaultOptions(conf.Dir))
 	if err != nil {
 		return nil, err
 	}
-	return &BadgerStorage{
-		db: db,
-	}, nil
+	// the migrated layout is synced to disk before any request served
+	_, _, err = migrateSchema(db, false)
+	if err == nil {
+		err = db.Sync()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	bs := &BadgerStorage{
+		db:     db,
+		conf:   conf,
+		clock:  conf.Clock,
+		audits: make(chan *signAuditAppend),
+		done:   make(chan struct{}),
+	}
+	if bs.clock == nil {
+		bs.clock = SystemClock
+	}
+	if conf.AdminSocket != "" {
+		err = bs.serveAdmin(conf.AdminSocket)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	bs.wg.Add(1)
+	go bs.loopSignAudits()
+	if conf.GCInterval > 0 {
+		bs.wg.Add(1)
+		go bs.loopMaintenance(ctx, time.Duration(conf.GCInterval)*time.Second)
+	}
+	return bs, nil
+}
+
+// OpenBadgerReadOnly opens the database for the operator commands which
+// never write, so no migration, admin socket or background routine runs,
+// and the schema must already be the version of this code base.
+func OpenBadgerReadOnly(conf *Configuration) (*BadgerStorage, error) {
+	db, err := badger.Open(badger.DefaultOptions(conf.Dir).WithReadOnly(true))
+	if err != nil {
+		return nil, err
+	}
+	current, err := readSchemaVersion(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if latest := SchemaVersion(); current != latest {
+		db.Close()
+		return nil, fmt.Errorf("schema version %d not match %d, migrate it first", current, latest)
+	}
+	bs := &BadgerStorage{
+		db:    db,
+		conf:  conf,
+		clock: conf.Clock,
+		done:  make(chan struct{}),
+	}
+	if bs.clock == nil {
+		bs.clock = SystemClock
+	}
+	return bs, nil
 }
 
 func (bs *BadgerStorage) Close() {
+	bs.closeAdmin()
+	close(bs.done)
+	bs.wg.Wait()
 	err := bs.db.Close()
 	if err != nil {
 		panic(err)
 	}
 }
 
+func (bs *BadgerStorage) Ping() error {
+	now := uint64(bs.clock.Now().UnixNano())
+	return bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(badgerKeyPing), uint64ToBytes(now))
+	})
+}
+
 func readKey(txn *badger.Txn, prefix string, key []byte) ([]byte, error) {
 	key = append([]byte(prefix), key...)
 	item, err := txn.Get(key)
@@ -96,11 +96,15 @@ func TestOpenBadgerInvalidPathAndClosePanic(t *testing.T) {
 	file := filepath.Join(dir, "not-a-dir")
 	require.NoError(os.WriteFile(file, []byte("x"), 0o600))
 
-	_, err := OpenBadger(context.Background(), &BadgerConfiguration{Dir: file})
+	_, err := OpenBadger(context.Background(), &Configuration{Dir: file})
 	require.Error(err)
 
+	// reopen so the schema migrated already, and no record in memory
 	closeDir := filepath.Join(t.TempDir(), "badger-close")
-	bs, err := OpenBadger(context.Background(), &BadgerConfiguration{Dir: closeDir})
+	bs, err := OpenBadger(context.Background(), &Configuration{Dir: closeDir})
+	require.NoError(err)
+	bs.Close()
+	bs, err = OpenBadger(context.Background(), &Configuration{Dir: closeDir})
 	require.NoError(err)
 
 	moved := closeDir + "-moved"
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/MixinNetwork/tip/crypto"
+	"github.com/dgraph-io/badger/v4"
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/kyber/v4/pairing/bn256"
 	"go.dedis.ch/kyber/v4/util/random"
@@ -45,6 +47,72 @@ func TestBadgerLimit(t *testing.T) {
 	require.Equal(0, available)
 }
 
+func TestBadgerLimitExpiry(t *testing.T) {
+	require := require.New(t)
+
+	clock := newTestClock()
+	bs, err := OpenBadger(context.Background(), &Configuration{Dir: t.TempDir(), Clock: clock})
+	require.NoError(err)
+	defer bs.Close()
+
+	countEntries := func(key []byte) int {
+		var count int
+		err := bs.exportRecords(func(r *record) error {
+			if bytes.HasPrefix(r.key, append([]byte(badgerKeyPrefixLimit), key...)) {
+				count++
+			}
+			return nil
+		})
+		require.NoError(err)
+		return count
+	}
+
+	// the clock is years before the wall time, and the entries never
+	// expire until the clock passes the two windows
+	window := time.Hour
+	for _, key := range [][]byte{[]byte("checked"), []byte("swept")} {
+		available, err := bs.CheckLimit(key, window, 3, true)
+		require.NoError(err)
+		require.Equal(2, available)
+	}
+	clock.Sleep(window)
+	available, err := bs.CheckLimit([]byte("checked"), window, 3, false)
+	require.NoError(err)
+	require.Equal(3, available)
+	require.Equal(1, countEntries([]byte("checked")))
+
+	// the check without increase never writes, and the expired entry is
+	// only removed by the next increase
+	clock.Sleep(window)
+	available, err = bs.CheckLimit([]byte("checked"), window, 3, false)
+	require.NoError(err)
+	require.Equal(3, available)
+	require.Equal(1, countEntries([]byte("checked")))
+	available, err = bs.CheckLimit([]byte("checked"), window, 3, true)
+	require.NoError(err)
+	require.Equal(2, available)
+	require.Equal(1, countEntries([]byte("checked")))
+	require.Equal(1, countEntries([]byte("swept")))
+
+	// the badger TTL still removes the entries never checked again
+	err = bs.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(badgerKeyPrefixLimit)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			require.NotZero(it.Item().ExpiresAt())
+		}
+		return nil
+	})
+	require.NoError(err)
+
+	report, err := bs.RunMaintenance()
+	require.NoError(err)
+	require.Equal(1, report.LimitExpired)
+	require.Equal(0, countEntries([]byte("swept")))
+}
+
 func TestBadgerNonce(t *testing.T) {
 	require := require.New(t)
 	bs := testBadgerStore()
@@ -218,7 +286,7 @@ func testBadgerStore() *BadgerStorage {
 	if err != nil {
 		panic(err)
 	}
-	conf := &BadgerConfiguration{
+	conf := &Configuration{
 		Dir: dir,
 	}
 	bs, err := OpenBadger(context.Background(), conf)
@@ -0,0 +1,380 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	boltFileName = "tip.db"
+)
+
+var boltBucket = []byte("tip")
+
+// BoltStorage uses the same key layout as the BadgerStorage in a single
+// bucket, and the LIMIT# value is also the expiry in unix seconds by the
+// clock, the expired entries are deleted by CheckLimit.
+type BoltStorage struct {
+	db       *bolt.DB
+	clock    Clock
+	auditKey []byte
+}
+
+// OpenBoltReadOnly opens the database for the copy source of the convert,
+// which must be of the latest schema.
+func OpenBoltReadOnly(conf *Configuration) (*BoltStorage, error) {
+	path := filepath.Join(conf.Dir, boltFileName)
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		if b == nil {
+			return fmt.Errorf("bolt bucket %s missing", boltBucket)
+		}
+		v := b.Get([]byte(badgerKeySchemaVersion))
+		if latest := SchemaVersion(); len(v) != 8 || binary.BigEndian.Uint64(v) != latest {
+			return fmt.Errorf("bolt schema version %x not match %d", v, latest)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return newBoltStorage(db, conf), nil
+}
+
+func OpenBolt(ctx context.Context, conf *Configuration) (*BoltStorage, error) {
+	err := os.MkdirAll(conf.Dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(conf.Dir, boltFileName)
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err
+		}
+		// the migrations are written for badger, so bolt only accepts the
+		// latest schema, which is converted from a migrated badger
+		latest := SchemaVersion()
+		v := b.Get([]byte(badgerKeySchemaVersion))
+		if v == nil {
+			return b.Put([]byte(badgerKeySchemaVersion), uint64ToBytes(latest))
+		}
+		if current := binary.BigEndian.Uint64(v); current != latest {
+			return fmt.Errorf("bolt schema version %d not match %d", current, latest)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return newBoltStorage(db, conf), nil
+}
+
+func newBoltStorage(db *bolt.DB, conf *Configuration) *BoltStorage {
+	bs := &BoltStorage{db: db, clock: conf.Clock, auditKey: conf.AuditKey}
+	if bs.clock == nil {
+		bs.clock = SystemClock
+	}
+	return bs
+}
+
+func (bs *BoltStorage) Close() {
+	err := bs.db.Close()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (bs *BoltStorage) CheckLimit(key []byte, window time.Duration, quota uint32, increase bool) (int, error) {
+	clock := bs.clock.Now()
+	now := uint64(clock.UnixNano())
+	if now >= maxUint64/2 || now <= uint64(window) {
+		return 0, fmt.Errorf("invalid clock %s for window %s", clock, window)
+	}
+	now = maxUint64 - now
+	threshold := now + uint64(window)
+	available := quota
+
+	prefix := append([]byte(badgerKeyPrefixLimit), key...)
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		c := b.Cursor()
+		var expired [][]byte
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			// the same as scanLimitEntries, skip the malformed records
+			// instead of panic on the short key or value
+			if len(k) < len(prefix)+8 {
+				continue
+			}
+			ts := binary.BigEndian.Uint64(k[len(prefix):])
+			if ts <= threshold {
+				available = max(available, 1) - 1
+			} else if len(v) == 8 && binary.BigEndian.Uint64(v) <= uint64(clock.Unix()) {
+				expired = append(expired, bytes.Clone(k))
+			}
+		}
+		for _, k := range expired {
+			err := b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		if available == 0 || !increase {
+			return nil
+		}
+
+		available--
+		expiry := uint64(clock.Add(window * 2).Unix())
+		return b.Put(append(prefix, uint64ToBytes(now)...), uint64ToBytes(expiry))
+	})
+	return int(available), err
+}
+
+func (bs *BoltStorage) CheckEphemeralNonce(key, ephemeral []byte, nonce uint64, grace time.Duration) (bool, error) {
+	var valid bool
+	now := bs.clock.Now().UnixNano()
+	val := uint64ToBytes(uint64(now))
+	val = append(val, ephemeral...)
+	val = append(val, uint64ToBytes(nonce)...)
+	gkey := append([]byte(badgerKeyPrefixGrace), key...)
+	key = append([]byte(badgerKeyPrefixNonce), key...)
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		set := func() error {
+			valid = true
+			err := b.Put(key, val)
+			if err != nil {
+				return err
+			}
+			return b.Put(gkey, uint64ToBytes(uint64(grace)))
+		}
+		v := b.Get(key)
+		if v == nil {
+			return set()
+		}
+		old := binary.BigEndian.Uint64(v[:8])
+		if old+uint64(grace) < uint64(now) {
+			return set()
+		}
+		if !bytes.Equal(v[8:len(v)-8], ephemeral) {
+			return nil
+		}
+		old = binary.BigEndian.Uint64(v[len(v)-8:])
+		if old >= nonce {
+			return nil
+		}
+		return set()
+	})
+	return valid, err
+}
+
+func (bs *BoltStorage) RotateEphemeralNonce(key, ephemeral []byte, nonce uint64) error {
+	now := bs.clock.Now().UnixNano()
+	key = append([]byte(badgerKeyPrefixNonce), key...)
+	val := uint64ToBytes(uint64(now))
+	val = append(val, ephemeral...)
+	val = append(val, uint64ToBytes(nonce)...)
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key, val)
+	})
+}
+
+func (bs *BoltStorage) CheckPolyGroup(group []byte) (bool, error) {
+	var valid bool
+	key := []byte(badgerKeyPolyGroup)
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		old := b.Get(key)
+		if old == nil {
+			valid = true
+			return b.Put(key, group)
+		}
+		valid = bytes.Equal(old, group)
+		return nil
+	})
+	return valid, err
+}
+
+func (bs *BoltStorage) ReadPolyGroup() ([]byte, error) {
+	return bs.read(badgerKeyPolyGroup, nil)
+}
+
+func (bs *BoltStorage) ReadPolyShare() ([]byte, error) {
+	return bs.read(badgerKeyPolyShare, nil)
+}
+
+func (bs *BoltStorage) ReadPolyPublic() ([]byte, error) {
+	return bs.read(badgerKeyPolyPublic, nil)
+}
+
+func (bs *BoltStorage) WritePoly(public, share []byte) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		err := b.Put([]byte(badgerKeyPolyPublic), public)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(badgerKeyPolyShare), share)
+	})
+}
+
+func (bs *BoltStorage) WriteAssignee(key []byte, assignee []byte) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		if oa := boltGet(b, badgerKeyPrefixAssignee, key); oa != nil {
+			err := b.Delete(append([]byte(badgerKeyPrefixAssignor), oa...))
+			if err != nil {
+				return err
+			}
+		}
+
+		if !bytes.Equal(key, assignee) {
+			if boltGet(b, badgerKeyPrefixAssignee, assignee) != nil {
+				return fmt.Errorf("invalid assignee as is assignee")
+			}
+			if boltGet(b, badgerKeyPrefixAssignor, assignee) != nil {
+				return fmt.Errorf("invalid assignor as is assignee")
+			}
+		}
+
+		err := b.Put(append([]byte(badgerKeyPrefixAssignee), key...), assignee)
+		if err != nil {
+			return err
+		}
+		err = b.Put(append([]byte(badgerKeyPrefixAssignor), assignee...), key)
+		if err != nil {
+			return err
+		}
+
+		var counter uint64
+		if cb := boltGet(b, badgerKeyPrefixCounter, key); cb != nil {
+			counter = binary.BigEndian.Uint64(cb)
+		}
+		return b.Put(append([]byte(badgerKeyPrefixCounter), key...), uint64ToBytes(counter+1))
+	})
+}
+
+func (bs *BoltStorage) ReadAssignee(key []byte) ([]byte, error) {
+	return bs.read(badgerKeyPrefixAssignee, key)
+}
+
+func (bs *BoltStorage) ReadAssignor(key []byte) ([]byte, error) {
+	return bs.read(badgerKeyPrefixAssignor, key)
+}
+
+func (bs *BoltStorage) Watch(key []byte) ([]byte, time.Time, int, error) {
+	var assignor []byte
+	var genesis time.Time
+	var counter int
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		assignor = bytes.Clone(boltGet(b, badgerKeyPrefixWatcher, key))
+		if assignor == nil {
+			return nil
+		}
+		if gb := boltGet(b, badgerKeyPrefixGenesis, assignor); gb != nil {
+			genesis = time.Unix(0, int64(binary.BigEndian.Uint64(gb)))
+		}
+		if cb := boltGet(b, badgerKeyPrefixCounter, assignor); cb != nil {
+			counter = int(binary.BigEndian.Uint64(cb))
+		}
+		return nil
+	})
+	return assignor, genesis, counter, err
+}
+
+func (bs *BoltStorage) WriteSignRequest(assignor, watcher []byte) (time.Time, int, error) {
+	if len(assignor) == 0 || len(watcher) == 0 {
+		return time.Time{}, 0, fmt.Errorf("invalid assignor %x or watcher %x", assignor, watcher)
+	}
+	var counter int
+	var genesis time.Time
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		if cb := boltGet(b, badgerKeyPrefixCounter, assignor); cb != nil {
+			counter = int(binary.BigEndian.Uint64(cb))
+		} else {
+			counter = 1
+		}
+		if old := boltGet(b, badgerKeyPrefixGenesis, assignor); old != nil {
+			genesis = time.Unix(0, int64(binary.BigEndian.Uint64(old)))
+		} else {
+			genesis = bs.clock.Now()
+		}
+
+		old := boltGet(b, badgerKeyPrefixWatcher, watcher)
+		if old != nil && !bytes.Equal(old, assignor) {
+			return fmt.Errorf("invalid watcher %x", watcher)
+		}
+
+		key := append([]byte(badgerKeyPrefixGenesis), assignor...)
+		err := b.Put(key, uint64ToBytes(uint64(genesis.UnixNano())))
+		if err != nil {
+			return err
+		}
+		key = append([]byte(badgerKeyPrefixCounter), assignor...)
+		err = b.Put(key, uint64ToBytes(uint64(counter)))
+		if err != nil {
+			return err
+		}
+		key = append([]byte(badgerKeyPrefixWatcher), watcher...)
+		return b.Put(key, assignor)
+	})
+	return genesis, counter, err
+}
+
+func (bs *BoltStorage) WriteSignAudit(assignor, watcher []byte, nonce uint64, counter int) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		head := boltGet(b, badgerKeyAuditHead, nil)
+		audit := newSignAudit(bs.auditKey, bytes.Clone(head), assignor, watcher, nonce, counter, bs.clock.Now())
+		val, err := json.Marshal(audit)
+		if err != nil {
+			return err
+		}
+		err = b.Put(audit.key(), val)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(badgerKeyAuditHead), audit.head())
+	})
+}
+
+func (bs *BoltStorage) Ping() error {
+	now := uint64(bs.clock.Now().UnixNano())
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(badgerKeyPing), uint64ToBytes(now))
+	})
+}
+
+func (bs *BoltStorage) read(prefix string, key []byte) ([]byte, error) {
+	var val []byte
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		val = bytes.Clone(boltGet(tx.Bucket(boltBucket), prefix, key))
+		return nil
+	})
+	return val, err
+}
+
+// the returned value is only valid in the transaction
+func boltGet(b *bolt.Bucket, prefix string, key []byte) []byte {
+	return b.Get(append([]byte(prefix), key...))
+}
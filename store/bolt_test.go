@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltLimitMalformedEntries(t *testing.T) {
+	require := require.New(t)
+	conf := &Configuration{Engine: EngineBolt, Dir: t.TempDir(), Clock: newTestClock()}
+	bs, err := OpenBolt(context.Background(), conf)
+	require.NoError(err)
+	defer bs.Close()
+
+	key := []byte("limit")
+	prefix := append([]byte(badgerKeyPrefixLimit), key...)
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		err := b.Put(append(prefix, 1, 2), uint64ToBytes(0))
+		if err != nil {
+			return err
+		}
+		return b.Put(append(prefix, uint64ToBytes(maxUint64)...), []byte{1})
+	})
+	require.NoError(err)
+
+	available, err := bs.CheckLimit(key, time.Hour, 3, true)
+	require.NoError(err)
+	require.Equal(2, available)
+}
@@ -0,0 +1,17 @@
+package store
+
+import "time"
+
+// Clock is injected to the storage so the time based throttle could be
+// tested deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var SystemClock Clock = systemClock{}
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testClock ticks a microsecond on each read, because the limit entries
+// are keyed by timestamp and would collapse with a frozen clock.
+type testClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(time.Microsecond)
+	return c.now
+}
+
+func (c *testClock) Sleep(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type conformanceBackend func(t *testing.T) (Storage, func(time.Duration))
+
+func TestMemoryConformance(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (Storage, func(time.Duration)) {
+		clock := newTestClock()
+		return NewMemoryStorage(clock), clock.Sleep
+	})
+}
+
+func TestBadgerConformance(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (Storage, func(time.Duration)) {
+		clock := newTestClock()
+		conf := &Configuration{Dir: t.TempDir(), Clock: clock}
+		bs, err := OpenBadger(context.Background(), conf)
+		require.NoError(t, err)
+		t.Cleanup(bs.Close)
+		return bs, clock.Sleep
+	})
+}
+
+func TestBoltConformance(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (Storage, func(time.Duration)) {
+		clock := newTestClock()
+		conf := &Configuration{Engine: EngineBolt, Dir: t.TempDir(), Clock: clock}
+		bs, err := Open(context.Background(), conf)
+		require.NoError(t, err)
+		t.Cleanup(bs.Close)
+		return bs, clock.Sleep
+	})
+}
+
+func testStorageConformance(t *testing.T, backend conformanceBackend) {
+	t.Run("Limit", func(t *testing.T) {
+		require := require.New(t)
+		s, sleep := backend(t)
+
+		key := []byte("limit")
+		for i := range 3 {
+			available, err := s.CheckLimit(key, time.Second, 3, true)
+			require.NoError(err)
+			require.Equal(2-i, available)
+		}
+		available, err := s.CheckLimit(key, time.Second, 3, true)
+		require.NoError(err)
+		require.Equal(0, available)
+		available, err = s.CheckLimit(key, time.Second, 5, false)
+		require.NoError(err)
+		require.Equal(2, available)
+		available, err = s.CheckLimit([]byte("other"), time.Second, 5, false)
+		require.NoError(err)
+		require.Equal(5, available)
+
+		sleep(time.Second + time.Millisecond*100)
+		available, err = s.CheckLimit(key, time.Second, 3, false)
+		require.NoError(err)
+		require.Equal(3, available)
+		available, err = s.CheckLimit(key, time.Second, 3, true)
+		require.NoError(err)
+		require.Equal(2, available)
+
+		_, err = s.CheckLimit(key, time.Hour*24*365*100, 3, true)
+		require.ErrorContains(err, "invalid clock")
+	})
+
+	t.Run("Nonce", func(t *testing.T) {
+		require := require.New(t)
+		s, sleep := backend(t)
+
+		key, ephemeral := []byte("nonce"), []byte("ephemeral")
+		valid, err := s.CheckEphemeralNonce(key, ephemeral, 5, time.Second)
+		require.NoError(err)
+		require.True(valid)
+		valid, err = s.CheckEphemeralNonce(key, ephemeral, 5, time.Second)
+		require.NoError(err)
+		require.False(valid)
+		valid, err = s.CheckEphemeralNonce(key, ephemeral, 4, time.Second)
+		require.NoError(err)
+		require.False(valid)
+		valid, err = s.CheckEphemeralNonce(key, []byte("other"), 6, time.Second)
+		require.NoError(err)
+		require.False(valid)
+		valid, err = s.CheckEphemeralNonce(key, ephemeral, 6, time.Second)
+		require.NoError(err)
+		require.True(valid)
+
+		err = s.RotateEphemeralNonce(key, []byte("rotated"), 6)
+		require.NoError(err)
+		valid, err = s.CheckEphemeralNonce(key, ephemeral, 7, time.Second)
+		require.NoError(err)
+		require.False(valid)
+		valid, err = s.CheckEphemeralNonce(key, []byte("rotated"), 7, time.Second)
+		require.NoError(err)
+		require.True(valid)
+
+		sleep(time.Second + time.Millisecond*100)
+		valid, err = s.CheckEphemeralNonce(key, []byte("grace"), 0, time.Second)
+		require.NoError(err)
+		require.True(valid)
+		valid, err = s.CheckEphemeralNonce(key, []byte("rotated"), 8, time.Second)
+		require.NoError(err)
+		require.False(valid)
+	})
+
+	t.Run("Poly", func(t *testing.T) {
+		require := require.New(t)
+		s, _ := backend(t)
+
+		group, err := s.ReadPolyGroup()
+		require.NoError(err)
+		require.Nil(group)
+		valid, err := s.CheckPolyGroup([]byte("group"))
+		require.NoError(err)
+		require.True(valid)
+		group, err = s.ReadPolyGroup()
+		require.NoError(err)
+		require.Equal([]byte("group"), group)
+		valid, err = s.CheckPolyGroup([]byte("group"))
+		require.NoError(err)
+		require.True(valid)
+		valid, err = s.CheckPolyGroup([]byte("other"))
+		require.NoError(err)
+		require.False(valid)
+
+		public, err := s.ReadPolyPublic()
+		require.NoError(err)
+		require.Nil(public)
+		share, err := s.ReadPolyShare()
+		require.NoError(err)
+		require.Nil(share)
+		err = s.WritePoly([]byte("public"), []byte("share"))
+		require.NoError(err)
+		public, err = s.ReadPolyPublic()
+		require.NoError(err)
+		require.Equal([]byte("public"), public)
+		share, err = s.ReadPolyShare()
+		require.NoError(err)
+		require.Equal([]byte("share"), share)
+	})
+
+	t.Run("Assignee", func(t *testing.T) {
+		require := require.New(t)
+		s, _ := backend(t)
+
+		a, b, c := []byte("identity-a"), []byte("identity-b"), []byte("identity-c")
+		err := s.WriteAssignee(a, b)
+		require.NoError(err)
+		ee, err := s.ReadAssignee(a)
+		require.NoError(err)
+		require.Equal(b, ee)
+		or, err := s.ReadAssignor(b)
+		require.NoError(err)
+		require.Equal(a, or)
+
+		err = s.WriteAssignee(c, b)
+		require.ErrorContains(err, "invalid assignor as is assignee")
+		err = s.WriteAssignee(c, a)
+		require.ErrorContains(err, "invalid assignee as is assignee")
+		ee, err = s.ReadAssignee(c)
+		require.NoError(err)
+		require.Nil(ee)
+
+		err = s.WriteAssignee(a, b)
+		require.NoError(err)
+		err = s.WriteAssignee(a, c)
+		require.NoError(err)
+		or, err = s.ReadAssignor(b)
+		require.NoError(err)
+		require.Nil(or)
+		or, err = s.ReadAssignor(c)
+		require.NoError(err)
+		require.Equal(a, or)
+		err = s.WriteAssignee(b, b)
+		require.NoError(err)
+
+		watcher := []byte("watcher-a")
+		genesis, counter, err := s.WriteSignRequest(a, watcher)
+		require.NoError(err)
+		require.Equal(3, counter)
+		_, g, n, err := s.Watch(watcher)
+		require.NoError(err)
+		require.Equal(3, n)
+		require.True(genesis.Equal(g))
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		require := require.New(t)
+		s, sleep := backend(t)
+
+		assignor, watcher := []byte("assignor"), []byte("watcher")
+		or, genesis, counter, err := s.Watch(watcher)
+		require.NoError(err)
+		require.Nil(or)
+		require.True(genesis.IsZero())
+		require.Equal(0, counter)
+
+		_, _, err = s.WriteSignRequest(nil, watcher)
+		require.Error(err)
+		genesis, counter, err = s.WriteSignRequest(assignor, watcher)
+		require.NoError(err)
+		require.Equal(1, counter)
+		sleep(time.Millisecond * 10)
+		again, counter, err := s.WriteSignRequest(assignor, watcher)
+		require.NoError(err)
+		require.Equal(1, counter)
+		require.True(genesis.Equal(again))
+		_, _, err = s.WriteSignRequest([]byte("other"), watcher)
+		require.ErrorContains(err, "invalid watcher")
+
+		err = s.WriteAssignee(assignor, assignor)
+		require.NoError(err)
+		or, again, counter, err = s.Watch(watcher)
+		require.NoError(err)
+		require.Equal(assignor, or)
+		require.Equal(2, counter)
+		require.True(genesis.Equal(again))
+	})
+}
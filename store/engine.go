@@ -0,0 +1,228 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/badger/v4"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	EngineBadger = "badger"
+	EngineBolt   = "bolt"
+	EngineMemory = "memory"
+	EngineRemote = "remote"
+)
+
+// Configuration is the [store] section shared by all engines, and the
+// options of one engine are ignored by the others.
+type Configuration struct {
+	// badger by default, or bolt for small deployments, or memory for
+	// ephemeral nodes, or remote for the API replicas of tip store serve,
+	// and the gc options only apply to badger
+	Engine string `toml:"engine"`
+	Dir    string `toml:"dir"`
+
+	// maintenance interval in seconds, zero disables the background loop
+	GCInterval     int64   `toml:"gc_interval"`
+	GCDiscardRatio float64 `toml:"gc_discard_ratio"`
+
+	// nonce records older than this many seconds and their own grace are
+	// removed by the sweep, zero disables the sweep, and it must not be
+	// shorter than the minimum grace period of the keeper
+	NonceExpiry int64 `toml:"nonce_expiry"`
+
+	// unix socket for the operator commands, e.g. backup, to work when
+	// the database is opened by a running process
+	AdminSocket string `toml:"admin_socket"`
+	// unix socket of tip store serve, and the API replicas with the remote
+	// engine use the same socket and token to share the database
+	RPCSocket string `toml:"rpc_socket"`
+	RPCToken  string `toml:"rpc_token"`
+
+	Clock Clock `toml:"-"`
+	// derived from the node key to MAC the sign audits, never configured
+	AuditKey []byte `toml:"-"`
+}
+
+// Database is the Storage opened by the configured engine.
+type Database interface {
+	Storage
+	Close()
+}
+
+func Open(ctx context.Context, conf *Configuration) (Database, error) {
+	switch conf.Engine {
+	case "", EngineBadger:
+		return OpenBadger(ctx, conf)
+	case EngineBolt:
+		return OpenBolt(ctx, conf)
+	case EngineMemory:
+		ms := NewMemoryStorage(conf.Clock)
+		ms.auditKey = conf.AuditKey
+		return &memoryDatabase{ms}, nil
+	case EngineRemote:
+		return OpenRemote(ctx, conf)
+	}
+	return nil, fmt.Errorf("invalid store engine %s", conf.Engine)
+}
+
+type memoryDatabase struct {
+	*MemoryStorage
+}
+
+func (*memoryDatabase) Close() {}
+
+// record is the raw key value, and the expiresAt is the unix seconds of the
+// LIMIT# entries, which is their value in both badger and bolt.
+type record struct {
+	key       []byte
+	value     []byte
+	expiresAt uint64
+}
+
+type recordStore interface {
+	Database
+	exportRecords(fn func(*record) error) error
+	importRecords(records []*record) error
+}
+
+// ConvertStorage copies all records from the source to an empty target,
+// which could be opened by a different engine.
+func ConvertStorage(ctx context.Context, from, to *Configuration) (int, error) {
+	entries, err := os.ReadDir(to.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	} else if len(entries) > 0 {
+		return 0, fmt.Errorf("convert directory %s not empty", to.Dir)
+	}
+
+	src, err := openRecordStore(ctx, from, true)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := openRecordStore(ctx, to, false)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	var count int
+	var batch []*record
+	err = src.exportRecords(func(r *record) error {
+		batch = append(batch, r)
+		count++
+		if len(batch) < 1024 {
+			return nil
+		}
+		err := dst.importRecords(batch)
+		batch = nil
+		return err
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, dst.importRecords(batch)
+}
+
+// openRecordStore opens the source read only, so it's never migrated or
+// written, and the background routines never run during the conversion.
+func openRecordStore(ctx context.Context, conf *Configuration, readOnly bool) (recordStore, error) {
+	conf = &Configuration{Engine: conf.Engine, Dir: conf.Dir}
+	var db Database
+	var err error
+	switch {
+	case !readOnly:
+		db, err = Open(ctx, conf)
+	case conf.Engine == "" || conf.Engine == EngineBadger:
+		db, err = OpenBadgerReadOnly(conf)
+	case conf.Engine == EngineBolt:
+		db, err = OpenBoltReadOnly(conf)
+	default:
+		err = fmt.Errorf("invalid store engine %s to convert", conf.Engine)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rs, ok := db.(recordStore)
+	if !ok {
+		db.Close()
+		return nil, fmt.Errorf("invalid store engine %s to convert", conf.Engine)
+	}
+	return rs, nil
+}
+
+func (bs *BadgerStorage) exportRecords(fn func(*record) error) error {
+	return bs.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			r := &record{key: item.KeyCopy(nil), value: val, expiresAt: item.ExpiresAt()}
+			if bytes.HasPrefix(r.key, []byte(badgerKeyPrefixLimit)) && len(val) == 8 {
+				r.value, r.expiresAt = []byte{1}, binary.BigEndian.Uint64(val)
+			}
+			err = fn(r)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bs *BadgerStorage) importRecords(records []*record) error {
+	wb := bs.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, r := range records {
+		entry := badger.NewEntry(r.key, r.value)
+		if bytes.HasPrefix(r.key, []byte(badgerKeyPrefixLimit)) {
+			entry.Value = uint64ToBytes(r.expiresAt)
+		}
+		err := wb.SetEntry(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+func (bs *BoltStorage) exportRecords(fn func(*record) error) error {
+	return bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(k, v []byte) error {
+			r := &record{key: bytes.Clone(k), value: bytes.Clone(v)}
+			if bytes.HasPrefix(k, []byte(badgerKeyPrefixLimit)) {
+				r.value, r.expiresAt = []byte{1}, binary.BigEndian.Uint64(v)
+			}
+			return fn(r)
+		})
+	})
+}
+
+func (bs *BoltStorage) importRecords(records []*record) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		for _, r := range records {
+			val := r.value
+			if bytes.HasPrefix(r.key, []byte(badgerKeyPrefixLimit)) {
+				val = uint64ToBytes(r.expiresAt)
+			}
+			err := b.Put(r.key, val)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenEngine(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	_, err := Open(ctx, &Configuration{Engine: "leveldb", Dir: t.TempDir()})
+	require.ErrorContains(err, "invalid store engine leveldb")
+
+	db, err := Open(ctx, &Configuration{Engine: EngineMemory})
+	require.NoError(err)
+	valid, err := db.CheckPolyGroup([]byte("group"))
+	require.NoError(err)
+	require.True(valid)
+	db.Close()
+
+	dir := t.TempDir()
+	db, err = Open(ctx, &Configuration{Engine: EngineBolt, Dir: dir})
+	require.NoError(err)
+	require.FileExists(filepath.Join(dir, boltFileName))
+	db.Close()
+}
+
+func TestConvertStorage(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	badgerConf := &Configuration{Dir: t.TempDir()}
+	bs, err := OpenBadger(ctx, badgerConf)
+	require.NoError(err)
+	valid, err := bs.CheckPolyGroup([]byte("group"))
+	require.NoError(err)
+	require.True(valid)
+	err = bs.WritePoly([]byte("public"), []byte("share"))
+	require.NoError(err)
+	err = bs.WriteAssignee([]byte("identity"), []byte("assignee"))
+	require.NoError(err)
+	genesis, _, err := bs.WriteSignRequest([]byte("identity"), []byte("watcher"))
+	require.NoError(err)
+	valid, err = bs.CheckEphemeralNonce([]byte("identity"), []byte("ephemeral"), 5, time.Hour)
+	require.NoError(err)
+	require.True(valid)
+	available, err := bs.CheckLimit([]byte("identity"), time.Hour, 3, true)
+	require.NoError(err)
+	require.Equal(2, available)
+	bs.Close()
+
+	boltConf := &Configuration{Engine: EngineBolt, Dir: t.TempDir()}
+	count, err := ConvertStorage(ctx, badgerConf, boltConf)
+	require.NoError(err)
+	require.Equal(12, count)
+	_, err = ConvertStorage(ctx, badgerConf, boltConf)
+	require.ErrorContains(err, "not empty")
+
+	// convert back to verify the LIMIT# expiry survives both directions
+	backConf := &Configuration{Engine: EngineBadger, Dir: t.TempDir()}
+	count, err = ConvertStorage(ctx, boltConf, backConf)
+	require.NoError(err)
+	require.Equal(12, count)
+
+	for _, conf := range []*Configuration{boltConf, backConf} {
+		db, err := Open(ctx, conf)
+		require.NoError(err)
+		valid, err := db.CheckPolyGroup([]byte("group"))
+		require.NoError(err)
+		require.True(valid)
+		share, err := db.ReadPolyShare()
+		require.NoError(err)
+		require.Equal([]byte("share"), share)
+		assignee, err := db.ReadAssignee([]byte("identity"))
+		require.NoError(err)
+		require.Equal([]byte("assignee"), assignee)
+		assignor, g, counter, err := db.Watch([]byte("watcher"))
+		require.NoError(err)
+		require.Equal([]byte("identity"), assignor)
+		require.True(genesis.Equal(g))
+		require.Equal(1, counter)
+		valid, err = db.CheckEphemeralNonce([]byte("identity"), []byte("ephemeral"), 5, time.Hour)
+		require.NoError(err)
+		require.False(valid)
+		available, err := db.CheckLimit([]byte("identity"), time.Hour, 3, false)
+		require.NoError(err)
+		require.Equal(2, available)
+		db.Close()
+	}
+}
+
+func TestConvertStorageReadOnlySource(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	// the source of an older schema is refused instead of migrated
+	source := &Configuration{Dir: t.TempDir()}
+	db, err := badger.Open(badger.DefaultOptions(source.Dir))
+	require.NoError(err)
+	require.NoError(db.Close())
+	_, err = ConvertStorage(ctx, source, &Configuration{Engine: EngineBolt, Dir: t.TempDir()})
+	require.ErrorContains(err, "schema version 0 not match 1")
+	current, pending, err := MigrateBadger(ctx, source, true)
+	require.NoError(err)
+	require.Equal(uint64(0), current)
+	require.Len(pending, 1)
+
+	_, err = ConvertStorage(ctx, &Configuration{Engine: EngineBolt, Dir: t.TempDir()}, &Configuration{Dir: t.TempDir()})
+	require.Error(err)
+	_, err = ConvertStorage(ctx, &Configuration{Engine: EngineMemory}, &Configuration{Dir: t.TempDir()})
+	require.ErrorContains(err, "invalid store engine memory to convert")
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/tip/logger"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// the lower bound keeper.EphemeralGracePeriod of the request grace, the
+	// store could not import keeper, and the longer grace is recorded
+	minNonceExpiry        = time.Hour * 24 * 128
+	defaultGCDiscardRatio = 0.5
+)
+
+type MaintenanceReport struct {
+	ValueLogRewrites int
+	NonceScanned     int
+	NonceExpired     int
+	LimitExpired     int
+	Duration         time.Duration
+}
+
+func (conf *Configuration) validate() error {
+	if conf.GCInterval < 0 {
+		return fmt.Errorf("invalid gc interval %d", conf.GCInterval)
+	}
+	if conf.GCDiscardRatio < 0 || conf.GCDiscardRatio >= 1 {
+		return fmt.Errorf("invalid gc discard ratio %f", conf.GCDiscardRatio)
+	}
+	expiry := time.Duration(conf.NonceExpiry) * time.Second
+	if conf.NonceExpiry < 0 || (expiry > 0 && expiry < minNonceExpiry) {
+		return fmt.Errorf("invalid nonce expiry %d", conf.NonceExpiry)
+	}
+	return nil
+}
+
+func (bs *BadgerStorage) loopMaintenance(ctx context.Context, interval time.Duration) {
+	defer bs.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-bs.done:
+			return
+		case <-ticker.C:
+		}
+		report, err := bs.RunMaintenance()
+		if err != nil {
+			logger.Error("store.RunMaintenance", err)
+			continue
+		}
+		logger.Infof("store.RunMaintenance value log rewrites %d, nonce scanned %d, expired %d, limit expired %d, in %s\n",
+			report.ValueLogRewrites, report.NonceScanned, report.NonceExpired, report.LimitExpired, report.Duration)
+	}
+}
+
+// RunMaintenance sweeps the expired nonce records first, so the value log
+// GC afterwards is able to reclaim the space they occupied.
+func (bs *BadgerStorage) RunMaintenance() (*MaintenanceReport, error) {
+	start := time.Now()
+	report := &MaintenanceReport{}
+
+	if bs.conf.NonceExpiry > 0 {
+		expiry := time.Duration(bs.conf.NonceExpiry) * time.Second
+		scanned, expired, err := bs.sweepNonces(expiry)
+		report.NonceScanned, report.NonceExpired = scanned, expired
+		if err != nil {
+			return report, err
+		}
+	}
+
+	expired, err := bs.sweepLimits()
+	report.LimitExpired = expired
+	if err != nil {
+		return report, err
+	}
+
+	rewrites, err := bs.collectValueLog()
+	report.ValueLogRewrites = rewrites
+	report.Duration = time.Since(start)
+	return report, err
+}
+
+func (bs *BadgerStorage) collectValueLog() (int, error) {
+	ratio := bs.conf.GCDiscardRatio
+	if ratio == 0 {
+		ratio = defaultGCDiscardRatio
+	}
+	var rewrites int
+	for {
+		err := bs.db.RunValueLogGC(ratio)
+		if err == badger.ErrNoRewrite || err == badger.ErrRejected {
+			return rewrites, nil
+		} else if err != nil {
+			return rewrites, err
+		}
+		rewrites++
+	}
+}
+
+// sweepNonces removes the nonce records older than both the expiry and
+// the grace recorded by the request, and the records without the grace,
+// written before it's recorded, are kept because their grace is unknown.
+func (bs *BadgerStorage) sweepNonces(expiry time.Duration) (int, int, error) {
+	now := uint64(bs.clock.Now().UnixNano())
+	prefix := []byte(badgerKeyPrefixNonce)
+
+	var stale [][]byte
+	var scanned int
+	err := bs.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			scanned++
+			key := it.Item().KeyCopy(nil)
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			expired, err := nonceExpired(txn, key, v, expiry, now)
+			if err != nil {
+				return err
+			}
+			if expired {
+				stale = append(stale, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return scanned, 0, err
+	}
+
+	var expired int
+	for _, key := range stale {
+		var deleted bool
+		err := bs.db.Update(func(txn *badger.Txn) error {
+			// the nonce may be refreshed after the scan, so check it again
+			item, err := txn.Get(key)
+			if err == badger.ErrKeyNotFound {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			ok, err := nonceExpired(txn, key, v, expiry, now)
+			if err != nil || !ok {
+				return err
+			}
+			deleted = true
+			err = txn.Delete(key)
+			if err != nil {
+				return err
+			}
+			return txn.Delete(append([]byte(badgerKeyPrefixGrace), key[len(badgerKeyPrefixNonce):]...))
+		})
+		if err == badger.ErrConflict {
+			continue
+		} else if err != nil {
+			return scanned, expired, err
+		}
+		if deleted {
+			expired++
+		}
+	}
+	return scanned, expired, nil
+}
+
+func nonceExpired(txn *badger.Txn, key, val []byte, expiry time.Duration, now uint64) (bool, error) {
+	grace, err := readKey(txn, badgerKeyPrefixGrace, key[len(badgerKeyPrefixNonce):])
+	if err != nil || len(grace) != 8 || len(val) < 16 {
+		return false, err
+	}
+	keep := max(time.Duration(binary.BigEndian.Uint64(grace)), expiry)
+	return binary.BigEndian.Uint64(val[:8])+uint64(keep) < now, nil
+}
+
+// sweepLimits removes the LIMIT# entries expired by the clock, which are
+// otherwise removed by the TTL or when the same limit is checked again.
+func (bs *BadgerStorage) sweepLimits() (int, error) {
+	prefix := []byte(badgerKeyPrefixLimit)
+	var expired [][]byte
+	err := bs.db.View(func(txn *badger.Txn) error {
+		keys, err := scanLimitEntries(txn, prefix, bs.clock.Now(), func(uint64) {})
+		expired = keys
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	// the expired entries are never written again, so no conflict check
+	wb := bs.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range expired {
+		err := wb.Delete(key)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), wb.Flush()
+}
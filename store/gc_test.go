@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigurationValidate(t *testing.T) {
+	require := require.New(t)
+
+	require.NoError((&Configuration{}).validate())
+	require.Error((&Configuration{GCInterval: -1}).validate())
+	require.Error((&Configuration{GCDiscardRatio: 1}).validate())
+	require.Error((&Configuration{NonceExpiry: 3600}).validate())
+	require.NoError((&Configuration{NonceExpiry: int64(minNonceExpiry / time.Second)}).validate())
+
+	_, err := OpenBadger(context.Background(), &Configuration{Dir: t.TempDir(), NonceExpiry: 1})
+	require.Error(err)
+}
+
+func TestBadgerSweepNonces(t *testing.T) {
+	require := require.New(t)
+	clock := newTestClock()
+	bs, err := OpenBadger(context.Background(), &Configuration{Dir: t.TempDir(), Clock: clock})
+	require.NoError(err)
+	defer bs.Close()
+
+	for _, k := range []string{"stale", "long"} {
+		grace := time.Millisecond * 50
+		if k == "long" {
+			grace = time.Hour
+		}
+		valid, err := bs.CheckEphemeralNonce([]byte(k), []byte("ephemeral"), 1, grace)
+		require.NoError(err)
+		require.True(valid)
+	}
+	// the nonce without the recorded grace is never swept
+	err = bs.RotateEphemeralNonce([]byte("unknown"), []byte("ephemeral"), 1)
+	require.NoError(err)
+	clock.Sleep(time.Millisecond * 100)
+	valid, err := bs.CheckEphemeralNonce([]byte("fresh"), []byte("ephemeral"), 1, time.Millisecond*50)
+	require.NoError(err)
+	require.True(valid)
+
+	// the one in its own longer grace is kept after the expiry
+	scanned, expired, err := bs.sweepNonces(time.Millisecond * 50)
+	require.NoError(err)
+	require.Equal(4, scanned)
+	require.Equal(1, expired)
+
+	valid, err = bs.CheckEphemeralNonce([]byte("fresh"), []byte("ephemeral"), 1, time.Hour)
+	require.NoError(err)
+	require.False(valid)
+	valid, err = bs.CheckEphemeralNonce([]byte("long"), []byte("other"), 2, time.Hour)
+	require.NoError(err)
+	require.False(valid)
+	valid, err = bs.CheckEphemeralNonce([]byte("stale"), []byte("other"), 0, time.Hour)
+	require.NoError(err)
+	require.True(valid)
+	err = bs.db.View(func(txn *badger.Txn) error {
+		v, err := readKey(txn, badgerKeyPrefixGrace, []byte("stale"))
+		require.Equal(uint64ToBytes(uint64(time.Hour)), v)
+		return err
+	})
+	require.NoError(err)
+
+	report, err := bs.RunMaintenance()
+	require.NoError(err)
+	require.Equal(0, report.NonceScanned)
+	require.GreaterOrEqual(report.ValueLogRewrites, 0)
+}
+
+func TestBadgerMaintenanceLoop(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bs, err := OpenBadger(ctx, &Configuration{Dir: t.TempDir(), GCInterval: 1})
+	require.NoError(err)
+	time.Sleep(time.Millisecond * 1200)
+	bs.Close()
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"crypto/sha3"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	badgerKeyPrefixAdmin = "ADMIN#"
+
+	AdminActionResetLimit = "RESET_LIMIT"
+	AdminActionClearNonce = "CLEAR_NONCE"
+)
+
+type IdentityState struct {
+	Key      []byte `json:"key"`
+	Watcher  []byte `json:"watcher"`
+	Assignee []byte `json:"assignee"`
+	Assignor []byte `json:"assignor"`
+
+	Genesis time.Time `json:"genesis"`
+	Counter int       `json:"counter"`
+
+	// sha3 of the ephemeral, the ephemeral itself is never exposed
+	Ephemeral []byte    `json:"ephemeral"`
+	Nonce     uint64    `json:"nonce"`
+	NonceAt   time.Time `json:"nonce_at"`
+
+	// the grace the client requested with the nonce, and zero for the
+	// nonce recorded without grace, which is never swept
+	Grace       time.Duration `json:"grace"`
+	GraceExpiry time.Time     `json:"grace_expiry"`
+
+	Limits []*LimitEntries `json:"limits"`
+}
+
+type LimitEntries struct {
+	Key     []byte      `json:"key"`
+	Kind    string      `json:"kind"`
+	Entries []time.Time `json:"entries"`
+}
+
+type AdminAction struct {
+	Action    string    `json:"action"`
+	Key       []byte    `json:"key"`
+	Operator  string    `json:"operator"`
+	Reason    string    `json:"reason"`
+	Deleted   int       `json:"deleted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InspectIdentity accepts either a 32 bytes watcher or an identity public
+// key, and returns all the records the keeper uses to throttle it.
+func (bs *BadgerStorage) InspectIdentity(key []byte) (*IdentityState, error) {
+	txn := bs.db.NewTransaction(false)
+	defer txn.Discard()
+
+	state := &IdentityState{Key: key}
+	if len(key) == 32 {
+		assignor, err := readKey(txn, badgerKeyPrefixWatcher, key)
+		if err != nil || assignor == nil {
+			return nil, err
+		}
+		state.Key, state.Watcher = assignor, key
+	}
+
+	var err error
+	state.Assignee, err = readKey(txn, badgerKeyPrefixAssignee, state.Key)
+	if err != nil {
+		return nil, err
+	}
+	state.Assignor, err = readKey(txn, badgerKeyPrefixAssignor, state.Key)
+	if err != nil {
+		return nil, err
+	}
+	assignor := state.Assignor
+	if assignor == nil {
+		assignor = state.Key
+	}
+
+	gb, err := readKey(txn, badgerKeyPrefixGenesis, assignor)
+	if err != nil {
+		return nil, err
+	} else if gb != nil {
+		state.Genesis = time.Unix(0, int64(binary.BigEndian.Uint64(gb)))
+	}
+	cb, err := readKey(txn, badgerKeyPrefixCounter, assignor)
+	if err != nil {
+		return nil, err
+	} else if cb != nil {
+		state.Counter = int(binary.BigEndian.Uint64(cb))
+	}
+	nb, err := readKey(txn, badgerKeyPrefixNonce, assignor)
+	if err != nil {
+		return nil, err
+	} else if len(nb) >= 16 {
+		eh := sha3.Sum256(nb[8 : len(nb)-8])
+		state.Ephemeral = eh[:]
+		state.NonceAt = time.Unix(0, int64(binary.BigEndian.Uint64(nb[:8])))
+		state.Nonce = binary.BigEndian.Uint64(nb[len(nb)-8:])
+		grace, err := readKey(txn, badgerKeyPrefixGrace, assignor)
+		if err != nil {
+			return nil, err
+		} else if len(grace) == 8 {
+			state.Grace = time.Duration(binary.BigEndian.Uint64(grace))
+			state.GraceExpiry = state.NonceAt.Add(state.Grace)
+		}
+	}
+
+	keys := [][]byte{state.Key}
+	if state.Assignor != nil {
+		keys = append(keys, state.Assignor)
+	}
+	for _, k := range keys {
+		limits, err := readLimitEntries(txn, k)
+		if err != nil {
+			return nil, err
+		}
+		state.Limits = append(state.Limits, limits...)
+	}
+	return state, nil
+}
+
+// ResetLimit removes all entries of the limit key, i.e. the identity with
+// the limit kind suffix, and writes the audit record in the same txn.
+func (bs *BadgerStorage) ResetLimit(key []byte, audit *AdminAction) (*AdminAction, error) {
+	prefix := append([]byte(badgerKeyPrefixLimit), key...)
+	audit.Action, audit.Key = AdminActionResetLimit, key
+	err := bs.db.Update(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		var keys [][]byte
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			k := it.Item().Key()
+			if len(k) != len(prefix)+8 {
+				continue
+			}
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		for _, k := range keys {
+			err := txn.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		audit.Deleted = len(keys)
+		return writeAdminAction(txn, audit, bs.clock.Now())
+	})
+	return audit, err
+}
+
+func (bs *BadgerStorage) ClearNonce(key []byte, audit *AdminAction) (*AdminAction, error) {
+	audit.Action, audit.Key = AdminActionClearNonce, key
+	err := bs.db.Update(func(txn *badger.Txn) error {
+		old, err := readKey(txn, badgerKeyPrefixNonce, key)
+		if err != nil {
+			return err
+		} else if old != nil {
+			audit.Deleted = 1
+			err = txn.Delete(append([]byte(badgerKeyPrefixNonce), key...))
+			if err != nil {
+				return err
+			}
+			err = txn.Delete(append([]byte(badgerKeyPrefixGrace), key...))
+			if err != nil {
+				return err
+			}
+		}
+		return writeAdminAction(txn, audit, bs.clock.Now())
+	})
+	return audit, err
+}
+
+func (bs *BadgerStorage) ListAdminActions() ([]*AdminAction, error) {
+	txn := bs.db.NewTransaction(false)
+	defer txn.Discard()
+
+	prefix := []byte(badgerKeyPrefixAdmin)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var actions []*AdminAction
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var action AdminAction
+		err = json.Unmarshal(v, &action)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, &action)
+	}
+	return actions, nil
+}
+
+func readLimitEntries(txn *badger.Txn, key []byte) ([]*LimitEntries, error) {
+	prefix := append([]byte(badgerKeyPrefixLimit), key...)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var limits []*LimitEntries
+	kinds := make(map[string]*LimitEntries)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		k := it.Item().Key()[len(prefix):]
+		if len(k) < 8 {
+			continue
+		}
+		kind := string(k[:len(k)-8])
+		le := kinds[kind]
+		if le == nil {
+			le = &LimitEntries{Key: key, Kind: kind}
+			kinds[kind] = le
+			limits = append(limits, le)
+		}
+		ts := maxUint64 - binary.BigEndian.Uint64(k[len(k)-8:])
+		le.Entries = append(le.Entries, time.Unix(0, int64(ts)))
+	}
+	return limits, nil
+}
+
+func writeAdminAction(txn *badger.Txn, audit *AdminAction, now time.Time) error {
+	if audit.Operator == "" || audit.Reason == "" {
+		return fmt.Errorf("invalid admin action operator %s or reason %s", audit.Operator, audit.Reason)
+	}
+	audit.CreatedAt = now
+	val, err := json.Marshal(audit)
+	if err != nil {
+		return err
+	}
+	key := append([]byte(badgerKeyPrefixAdmin), uint64ToBytes(uint64(audit.CreatedAt.UnixNano()))...)
+	key = append(key, hex.EncodeToString(audit.Key)...)
+	return txn.Set(key, val)
+}
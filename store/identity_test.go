@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerInspectIdentityAndAdminActions(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := os.MkdirTemp("/tmp", "tip-admin-test")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "admin.sock")
+	conf := &Configuration{Dir: filepath.Join(dir, "db"), AdminSocket: socket}
+	bs, err := OpenBadger(context.Background(), conf)
+	require.NoError(err)
+	defer bs.Close()
+
+	identity := make([]byte, 128)
+	identity[0] = 1
+	watcher := make([]byte, 32)
+	watcher[0] = 2
+
+	state, err := bs.InspectIdentity(watcher)
+	require.NoError(err)
+	require.Nil(state)
+
+	_, _, err = bs.WriteSignRequest(identity, watcher)
+	require.NoError(err)
+	valid, err := bs.CheckEphemeralNonce(identity, []byte("ephemeral"), 3, time.Hour)
+	require.NoError(err)
+	require.True(valid)
+	for range 2 {
+		_, err = bs.CheckLimit(append(identity, "SECRET"...), time.Hour, 10, true)
+		require.NoError(err)
+	}
+	_, err = bs.CheckLimit(append(identity, "EPHEMERAL"...), time.Hour, 10, true)
+	require.NoError(err)
+
+	for _, admin := range []Admin{bs, NewAdminClient(socket)} {
+		state, err = admin.InspectIdentity(watcher)
+		require.NoError(err)
+		require.Equal(identity, state.Key)
+		require.Equal(watcher, state.Watcher)
+		require.Nil(state.Assignee)
+		require.Nil(state.Assignor)
+		require.Equal(1, state.Counter)
+		require.False(state.Genesis.IsZero())
+		require.Equal(uint64(3), state.Nonce)
+		require.Len(state.Ephemeral, 32)
+		require.Equal(time.Hour, state.Grace)
+		require.Equal(state.NonceAt.Add(time.Hour), state.GraceExpiry)
+		require.Len(state.Limits, 2)
+		require.Equal("EPHEMERAL", state.Limits[0].Kind)
+		require.Len(state.Limits[0].Entries, 1)
+		require.Equal("SECRET", state.Limits[1].Kind)
+		require.Len(state.Limits[1].Entries, 2)
+	}
+
+	client := NewAdminClient(socket)
+	_, err = client.ResetLimit(append(identity, "SECRET"...), &AdminAction{Operator: "op"})
+	require.ErrorContains(err, "invalid admin action")
+	action, err := client.ResetLimit(append(identity, "SECRET"...), &AdminAction{Operator: "op", Reason: "locked"})
+	require.NoError(err)
+	require.Equal(AdminActionResetLimit, action.Action)
+	require.Equal(2, action.Deleted)
+	action, err = bs.ClearNonce(identity, &AdminAction{Operator: "op", Reason: "lost"})
+	require.NoError(err)
+	require.Equal(1, action.Deleted)
+
+	state, err = bs.InspectIdentity(identity)
+	require.NoError(err)
+	require.Len(state.Limits, 1)
+	require.Equal(uint64(0), state.Nonce)
+	require.Nil(state.Ephemeral)
+	require.Zero(state.Grace)
+
+	actions, err := client.ListAdminActions()
+	require.NoError(err)
+	require.Len(actions, 2)
+	require.Equal(AdminActionResetLimit, actions[0].Action)
+	require.Equal("locked", actions[0].Reason)
+	require.Equal(AdminActionClearNonce, actions[1].Action)
+	require.Equal(identity, actions[1].Key)
+}
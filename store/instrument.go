@@ -0,0 +1,99 @@
+package store
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/tip/metrics"
+)
+
+type instrumentedDatabase struct {
+	db Database
+}
+
+// Instrument records the latency of each storage operation to the metrics,
+// and the badger size if the database is badger.
+func Instrument(db Database) Database {
+	if bs, ok := db.(*BadgerStorage); ok {
+		metrics.BadgerSize(bs.db.Size)
+	}
+	return &instrumentedDatabase{db: db}
+}
+
+func (i *instrumentedDatabase) Close() {
+	i.db.Close()
+}
+
+func (i *instrumentedDatabase) CheckPolyGroup(group []byte) (valid bool, err error) {
+	defer metrics.StoreOperation("CheckPolyGroup", time.Now(), &err)
+	return i.db.CheckPolyGroup(group)
+}
+
+func (i *instrumentedDatabase) ReadPolyGroup() (group []byte, err error) {
+	defer metrics.StoreOperation("ReadPolyGroup", time.Now(), &err)
+	return i.db.ReadPolyGroup()
+}
+
+func (i *instrumentedDatabase) ReadPolyPublic() (public []byte, err error) {
+	defer metrics.StoreOperation("ReadPolyPublic", time.Now(), &err)
+	return i.db.ReadPolyPublic()
+}
+
+func (i *instrumentedDatabase) ReadPolyShare() (share []byte, err error) {
+	defer metrics.StoreOperation("ReadPolyShare", time.Now(), &err)
+	return i.db.ReadPolyShare()
+}
+
+func (i *instrumentedDatabase) WritePoly(public, share []byte) (err error) {
+	defer metrics.StoreOperation("WritePoly", time.Now(), &err)
+	return i.db.WritePoly(public, share)
+}
+
+func (i *instrumentedDatabase) WriteAssignee(key []byte, assignee []byte) (err error) {
+	defer metrics.StoreOperation("WriteAssignee", time.Now(), &err)
+	return i.db.WriteAssignee(key, assignee)
+}
+
+func (i *instrumentedDatabase) ReadAssignor(key []byte) (assignor []byte, err error) {
+	defer metrics.StoreOperation("ReadAssignor", time.Now(), &err)
+	return i.db.ReadAssignor(key)
+}
+
+func (i *instrumentedDatabase) ReadAssignee(key []byte) (assignee []byte, err error) {
+	defer metrics.StoreOperation("ReadAssignee", time.Now(), &err)
+	return i.db.ReadAssignee(key)
+}
+
+func (i *instrumentedDatabase) CheckLimit(key []byte, window time.Duration, quota uint32, increase bool) (available int, err error) {
+	defer metrics.StoreOperation("CheckLimit", time.Now(), &err)
+	return i.db.CheckLimit(key, window, quota, increase)
+}
+
+func (i *instrumentedDatabase) CheckEphemeralNonce(key, ephemeral []byte, nonce uint64, grace time.Duration) (valid bool, err error) {
+	defer metrics.StoreOperation("CheckEphemeralNonce", time.Now(), &err)
+	return i.db.CheckEphemeralNonce(key, ephemeral, nonce, grace)
+}
+
+func (i *instrumentedDatabase) RotateEphemeralNonce(key, ephemeral []byte, nonce uint64) (err error) {
+	defer metrics.StoreOperation("RotateEphemeralNonce", time.Now(), &err)
+	return i.db.RotateEphemeralNonce(key, ephemeral, nonce)
+}
+
+func (i *instrumentedDatabase) WriteSignRequest(key, watcher []byte) (genesis time.Time, counter int, err error) {
+	defer metrics.StoreOperation("WriteSignRequest", time.Now(), &err)
+	return i.db.WriteSignRequest(key, watcher)
+}
+
+func (i *instrumentedDatabase) WriteSignAudit(assignor, watcher []byte, nonce uint64, counter int) (err error) {
+	defer metrics.StoreOperation("WriteSignAudit", time.Now(), &err)
+	return i.db.WriteSignAudit(assignor, watcher, nonce, counter)
+}
+
+func (i *instrumentedDatabase) Ping() (err error) {
+	defer metrics.StoreOperation("Ping", time.Now(), &err)
+	return i.db.Ping()
+}
+
+func (i *instrumentedDatabase) Watch(key []byte) (assignor []byte, genesis time.Time, counter int, err error) {
+	defer metrics.StoreOperation("Watch", time.Now(), &err)
+	return i.db.Watch(key)
+}
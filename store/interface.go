@@ -4,6 +4,7 @@ import "time"
 
 type Storage interface {
 	CheckPolyGroup(group []byte) (bool, error)
+	ReadPolyGroup() ([]byte, error)
 	ReadPolyPublic() ([]byte, error)
 	ReadPolyShare() ([]byte, error)
 	WritePoly(public, share []byte) error
@@ -15,5 +16,9 @@ type Storage interface {
 	CheckEphemeralNonce(key, ephemeral []byte, nonce uint64, grace time.Duration) (bool, error)
 	RotateEphemeralNonce(key, ephemeral []byte, nonce uint64) error
 	WriteSignRequest(key, watcher []byte) (time.Time, int, error)
+	WriteSignAudit(assignor, watcher []byte, nonce uint64, counter int) error
 	Watch(key []byte) ([]byte, time.Time, int, error)
+
+	// Ping writes a probe record to check the storage is writable
+	Ping() error
 }
@@ -0,0 +1,277 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps all records in memory with the same semantics as
+// the BadgerStorage, it's for tests and ephemeral nodes only.
+type MemoryStorage struct {
+	sync.Mutex
+	clock Clock
+
+	polyGroup  []byte
+	polyPublic []byte
+	polyShare  []byte
+
+	assignees map[string][]byte
+	assignors map[string][]byte
+	watchers  map[string][]byte
+	limits    map[string][]*memoryLimitEntry
+	nonces    map[string]*memoryNonce
+	geneses   map[string]time.Time
+	counters  map[string]uint64
+	audits    []*SignAudit
+	auditKey  []byte
+}
+
+type memoryLimitEntry struct {
+	// the inverted timestamp as the LIMIT# key suffix in badger
+	ts     uint64
+	expiry time.Time
+}
+
+type memoryNonce struct {
+	ts        uint64
+	ephemeral []byte
+	nonce     uint64
+}
+
+func NewMemoryStorage(clock Clock) *MemoryStorage {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &MemoryStorage{
+		clock:     clock,
+		assignees: make(map[string][]byte),
+		assignors: make(map[string][]byte),
+		watchers:  make(map[string][]byte),
+		limits:    make(map[string][]*memoryLimitEntry),
+		nonces:    make(map[string]*memoryNonce),
+		geneses:   make(map[string]time.Time),
+		counters:  make(map[string]uint64),
+	}
+}
+
+func (ms *MemoryStorage) CheckLimit(key []byte, window time.Duration, quota uint32, increase bool) (int, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	clock := ms.clock.Now()
+	now := uint64(clock.UnixNano())
+	if now >= maxUint64/2 || now <= uint64(window) {
+		return 0, fmt.Errorf("invalid clock %s for window %s", clock, window)
+	}
+	now = maxUint64 - now
+	threshold := now + uint64(window)
+	available := quota
+
+	// entries are sorted by the inverted timestamp, i.e. the newest first
+	entries := slices.DeleteFunc(ms.limits[string(key)], func(e *memoryLimitEntry) bool {
+		return !e.expiry.After(clock)
+	})
+	for _, e := range entries {
+		if available == 0 || e.ts > threshold {
+			break
+		}
+		available--
+	}
+	ms.limits[string(key)] = entries
+	if available == 0 || !increase {
+		return int(available), nil
+	}
+
+	available--
+	entry := &memoryLimitEntry{ts: now, expiry: clock.Add(window * 2)}
+	i, found := slices.BinarySearchFunc(entries, now, func(e *memoryLimitEntry, ts uint64) int {
+		return compareUint64(e.ts, ts)
+	})
+	if found {
+		entries[i] = entry
+	} else {
+		entries = slices.Insert(entries, i, entry)
+	}
+	ms.limits[string(key)] = entries
+	return int(available), nil
+}
+
+func (ms *MemoryStorage) CheckEphemeralNonce(key, ephemeral []byte, nonce uint64, grace time.Duration) (bool, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	now := uint64(ms.clock.Now().UnixNano())
+	val := &memoryNonce{ts: now, ephemeral: bytes.Clone(ephemeral), nonce: nonce}
+	old := ms.nonces[string(key)]
+	if old == nil || old.ts+uint64(grace) < now {
+		ms.nonces[string(key)] = val
+		return true, nil
+	}
+	if !bytes.Equal(old.ephemeral, ephemeral) {
+		return false, nil
+	}
+	if old.nonce >= nonce {
+		return false, nil
+	}
+	ms.nonces[string(key)] = val
+	return true, nil
+}
+
+func (ms *MemoryStorage) RotateEphemeralNonce(key, ephemeral []byte, nonce uint64) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	now := uint64(ms.clock.Now().UnixNano())
+	ms.nonces[string(key)] = &memoryNonce{ts: now, ephemeral: bytes.Clone(ephemeral), nonce: nonce}
+	return nil
+}
+
+func (ms *MemoryStorage) CheckPolyGroup(group []byte) (bool, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if ms.polyGroup == nil {
+		ms.polyGroup = bytes.Clone(group)
+		return true, nil
+	}
+	return bytes.Equal(ms.polyGroup, group), nil
+}
+
+func (ms *MemoryStorage) ReadPolyGroup() ([]byte, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	return bytes.Clone(ms.polyGroup), nil
+}
+
+func (ms *MemoryStorage) ReadPolyShare() ([]byte, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	return bytes.Clone(ms.polyShare), nil
+}
+
+func (ms *MemoryStorage) ReadPolyPublic() ([]byte, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	return bytes.Clone(ms.polyPublic), nil
+}
+
+func (ms *MemoryStorage) WritePoly(public, share []byte) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	ms.polyPublic = bytes.Clone(public)
+	ms.polyShare = bytes.Clone(share)
+	return nil
+}
+
+func (ms *MemoryStorage) WriteAssignee(key []byte, assignee []byte) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if !bytes.Equal(key, assignee) {
+		if ms.assignees[string(assignee)] != nil {
+			return fmt.Errorf("invalid assignee as is assignee")
+		}
+		if ms.assignors[string(assignee)] != nil {
+			old := ms.assignees[string(key)]
+			if !bytes.Equal(old, assignee) {
+				return fmt.Errorf("invalid assignor as is assignee")
+			}
+		}
+	}
+
+	// badger deletes the old link first in the same txn, so the assignee
+	// check above sees the state after the deletion
+	if oa := ms.assignees[string(key)]; oa != nil {
+		delete(ms.assignors, string(oa))
+	}
+	ms.assignees[string(key)] = bytes.Clone(assignee)
+	ms.assignors[string(assignee)] = bytes.Clone(key)
+	ms.counters[string(key)] = ms.counters[string(key)] + 1
+	return nil
+}
+
+func (ms *MemoryStorage) ReadAssignee(key []byte) ([]byte, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	return bytes.Clone(ms.assignees[string(key)]), nil
+}
+
+func (ms *MemoryStorage) ReadAssignor(key []byte) ([]byte, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	return bytes.Clone(ms.assignors[string(key)]), nil
+}
+
+func (ms *MemoryStorage) Watch(key []byte) ([]byte, time.Time, int, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	assignor := ms.watchers[string(key)]
+	if assignor == nil {
+		return nil, time.Time{}, 0, nil
+	}
+	genesis := ms.geneses[string(assignor)]
+	counter := int(ms.counters[string(assignor)])
+	return bytes.Clone(assignor), genesis, counter, nil
+}
+
+func (ms *MemoryStorage) WriteSignRequest(assignor, watcher []byte) (time.Time, int, error) {
+	if len(assignor) == 0 || len(watcher) == 0 {
+		return time.Time{}, 0, fmt.Errorf("invalid assignor %x or watcher %x", assignor, watcher)
+	}
+	ms.Lock()
+	defer ms.Unlock()
+
+	old := ms.watchers[string(watcher)]
+	if old != nil && !bytes.Equal(old, assignor) {
+		return time.Time{}, 0, fmt.Errorf("invalid watcher %x", watcher)
+	}
+
+	counter, found := ms.counters[string(assignor)]
+	if !found {
+		counter = 1
+	}
+	genesis, found := ms.geneses[string(assignor)]
+	if !found {
+		genesis = time.Unix(0, ms.clock.Now().UnixNano())
+	}
+	ms.counters[string(assignor)] = counter
+	ms.geneses[string(assignor)] = genesis
+	ms.watchers[string(watcher)] = bytes.Clone(assignor)
+	return genesis, int(counter), nil
+}
+
+func (ms *MemoryStorage) WriteSignAudit(assignor, watcher []byte, nonce uint64, counter int) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	var head []byte
+	if n := len(ms.audits); n > 0 {
+		head = ms.audits[n-1].head()
+	}
+	audit := newSignAudit(ms.auditKey, head, assignor, bytes.Clone(watcher), nonce, counter, ms.clock.Now())
+	ms.audits = append(ms.audits, audit)
+	return nil
+}
+
+func (ms *MemoryStorage) Ping() error {
+	return nil
+}
+
+func compareUint64(a, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,299 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/tip/logger"
+)
+
+const (
+	remoteTokenMinLength = 32
+	remoteRequestTimeout = 10 * time.Second
+)
+
+// remoteRequest has the union of all Storage method arguments, and each
+// method only uses some of them.
+type remoteRequest struct {
+	Key      []byte        `json:"key,omitempty"`
+	Value    []byte        `json:"value,omitempty"`
+	Extra    []byte        `json:"extra,omitempty"`
+	Window   time.Duration `json:"window,omitempty"`
+	Quota    uint32        `json:"quota,omitempty"`
+	Increase bool          `json:"increase,omitempty"`
+	Nonce    uint64        `json:"nonce,omitempty"`
+	Counter  int           `json:"counter,omitempty"`
+}
+
+type remoteResponse struct {
+	Data      []byte    `json:"data,omitempty"`
+	Valid     bool      `json:"valid,omitempty"`
+	Available int       `json:"available,omitempty"`
+	Time      time.Time `json:"time"`
+	Counter   int       `json:"counter,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// StorageServer exposes a Storage to the API replicas on the same host, so
+// they share the throttle and nonce states of the database opened by this
+// process. The unix socket is only accessible by the process owner, and
+// each request must also carry the shared token.
+type StorageServer struct {
+	storage Storage
+	token   string
+	server  *http.Server
+}
+
+func NewStorageServer(storage Storage, conf *Configuration) (*StorageServer, error) {
+	if conf.RPCSocket == "" {
+		return nil, fmt.Errorf("invalid rpc socket %s", conf.RPCSocket)
+	}
+	if len(conf.RPCToken) < remoteTokenMinLength {
+		return nil, fmt.Errorf("invalid rpc token length %d", len(conf.RPCToken))
+	}
+	ss := &StorageServer{storage: storage, token: conf.RPCToken}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /storage/{method}", ss.handle)
+	ss.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return ss, nil
+}
+
+// Serve blocks until the context is done, and the database must be opened
+// before, because the stale socket is removed.
+func (ss *StorageServer) Serve(ctx context.Context, path string) error {
+	l, err := listenUnix(path)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := ss.server.Shutdown(sctx)
+		if err != nil {
+			logger.Error("store.StorageServer.Shutdown", err)
+		}
+	}()
+	err = ss.server.Serve(l)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (ss *StorageServer) handle(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(ss.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req remoteRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 65536)).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var res remoteResponse
+	switch method := r.PathValue("method"); method {
+	case "CheckPolyGroup":
+		res.Valid, err = ss.storage.CheckPolyGroup(req.Value)
+	case "ReadPolyGroup":
+		res.Data, err = ss.storage.ReadPolyGroup()
+	case "ReadPolyPublic":
+		res.Data, err = ss.storage.ReadPolyPublic()
+	case "ReadPolyShare":
+		res.Data, err = ss.storage.ReadPolyShare()
+	case "WritePoly":
+		err = ss.storage.WritePoly(req.Value, req.Extra)
+	case "WriteAssignee":
+		err = ss.storage.WriteAssignee(req.Key, req.Value)
+	case "ReadAssignor":
+		res.Data, err = ss.storage.ReadAssignor(req.Key)
+	case "ReadAssignee":
+		res.Data, err = ss.storage.ReadAssignee(req.Key)
+	case "CheckLimit":
+		res.Available, err = ss.storage.CheckLimit(req.Key, req.Window, req.Quota, req.Increase)
+	case "CheckEphemeralNonce":
+		res.Valid, err = ss.storage.CheckEphemeralNonce(req.Key, req.Value, req.Nonce, req.Window)
+	case "RotateEphemeralNonce":
+		err = ss.storage.RotateEphemeralNonce(req.Key, req.Value, req.Nonce)
+	case "WriteSignRequest":
+		res.Time, res.Counter, err = ss.storage.WriteSignRequest(req.Key, req.Value)
+	case "Watch":
+		res.Data, res.Time, res.Counter, err = ss.storage.Watch(req.Key)
+	case "Ping":
+		err = ss.storage.Ping()
+	case "WriteSignAudit":
+		err = ss.storage.WriteSignAudit(req.Key, req.Value, req.Nonce, req.Counter)
+	default:
+		http.Error(w, "invalid method "+method, http.StatusNotFound)
+		return
+	}
+	// the storage errors are returned in the body, and the client returns
+	// them as is, so the keeper sees the same errors as the local storage
+	if err != nil {
+		res.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&res)
+}
+
+// RemoteStorage implements the Storage through the socket of the process
+// which runs the StorageServer.
+type RemoteStorage struct {
+	token  string
+	client *http.Client
+}
+
+func OpenRemote(ctx context.Context, conf *Configuration) (*RemoteStorage, error) {
+	if conf.RPCSocket == "" {
+		return nil, fmt.Errorf("invalid rpc socket %s", conf.RPCSocket)
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", conf.RPCSocket)
+		},
+		MaxIdleConnsPerHost: 64,
+	}
+	rs := &RemoteStorage{
+		token:  conf.RPCToken,
+		client: &http.Client{Transport: transport, Timeout: remoteRequestTimeout},
+	}
+	// fail early on a wrong socket or token, instead of the first request
+	_, err := rs.ReadPolyPublic()
+	if err != nil {
+		rs.Close()
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (rs *RemoteStorage) Close() {
+	rs.client.CloseIdleConnections()
+}
+
+func (rs *RemoteStorage) CheckPolyGroup(group []byte) (bool, error) {
+	res, err := rs.call("CheckPolyGroup", &remoteRequest{Value: group})
+	return res.Valid, err
+}
+
+func (rs *RemoteStorage) ReadPolyGroup() ([]byte, error) {
+	res, err := rs.call("ReadPolyGroup", &remoteRequest{})
+	return res.Data, err
+}
+
+func (rs *RemoteStorage) ReadPolyPublic() ([]byte, error) {
+	res, err := rs.call("ReadPolyPublic", &remoteRequest{})
+	return res.Data, err
+}
+
+func (rs *RemoteStorage) ReadPolyShare() ([]byte, error) {
+	res, err := rs.call("ReadPolyShare", &remoteRequest{})
+	return res.Data, err
+}
+
+func (rs *RemoteStorage) WritePoly(public, share []byte) error {
+	_, err := rs.call("WritePoly", &remoteRequest{Value: public, Extra: share})
+	return err
+}
+
+func (rs *RemoteStorage) WriteAssignee(key []byte, assignee []byte) error {
+	_, err := rs.call("WriteAssignee", &remoteRequest{Key: key, Value: assignee})
+	return err
+}
+
+func (rs *RemoteStorage) ReadAssignor(key []byte) ([]byte, error) {
+	res, err := rs.call("ReadAssignor", &remoteRequest{Key: key})
+	return res.Data, err
+}
+
+func (rs *RemoteStorage) ReadAssignee(key []byte) ([]byte, error) {
+	res, err := rs.call("ReadAssignee", &remoteRequest{Key: key})
+	return res.Data, err
+}
+
+func (rs *RemoteStorage) CheckLimit(key []byte, window time.Duration, quota uint32, increase bool) (int, error) {
+	req := &remoteRequest{Key: key, Window: window, Quota: quota, Increase: increase}
+	res, err := rs.call("CheckLimit", req)
+	return res.Available, err
+}
+
+func (rs *RemoteStorage) CheckEphemeralNonce(key, ephemeral []byte, nonce uint64, grace time.Duration) (bool, error) {
+	req := &remoteRequest{Key: key, Value: ephemeral, Nonce: nonce, Window: grace}
+	res, err := rs.call("CheckEphemeralNonce", req)
+	return res.Valid, err
+}
+
+func (rs *RemoteStorage) RotateEphemeralNonce(key, ephemeral []byte, nonce uint64) error {
+	_, err := rs.call("RotateEphemeralNonce", &remoteRequest{Key: key, Value: ephemeral, Nonce: nonce})
+	return err
+}
+
+func (rs *RemoteStorage) WriteSignRequest(key, watcher []byte) (time.Time, int, error) {
+	res, err := rs.call("WriteSignRequest", &remoteRequest{Key: key, Value: watcher})
+	return res.Time, res.Counter, err
+}
+
+func (rs *RemoteStorage) Watch(key []byte) ([]byte, time.Time, int, error) {
+	res, err := rs.call("Watch", &remoteRequest{Key: key})
+	return res.Data, res.Time, res.Counter, err
+}
+
+func (rs *RemoteStorage) WriteSignAudit(assignor, watcher []byte, nonce uint64, counter int) error {
+	req := &remoteRequest{Key: assignor, Value: watcher, Nonce: nonce, Counter: counter}
+	_, err := rs.call("WriteSignAudit", req)
+	return err
+}
+
+func (rs *RemoteStorage) Ping() error {
+	_, err := rs.call("Ping", &remoteRequest{})
+	return err
+}
+
+// call never returns a nil response, so the methods above read the fields
+// without checks, and they are all zero values on error.
+func (rs *RemoteStorage) call(method string, body *remoteRequest) (*remoteResponse, error) {
+	var res remoteResponse
+	b, err := json.Marshal(body)
+	if err != nil {
+		return &res, err
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://storage/storage/"+method, bytes.NewReader(b))
+	if err != nil {
+		return &res, err
+	}
+	req.Header.Set("Authorization", "Bearer "+rs.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := rs.client.Do(req)
+	if err != nil {
+		return &res, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return &res, fmt.Errorf("storage %s error %d %s", method, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return &remoteResponse{}, err
+	}
+	if res.Error != "" {
+		return &remoteResponse{}, errors.New(res.Error)
+	}
+	return &res, nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testRPCToken = "0123456789abcdef0123456789abcdef"
+
+func serveTestStorage(t *testing.T, storage Storage) *Configuration {
+	// the unix socket path is limited to about 100 bytes
+	dir, err := os.MkdirTemp("/tmp", "tip-rpc")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	conf := &Configuration{
+		Engine:    EngineRemote,
+		RPCSocket: filepath.Join(dir, "rpc.sock"),
+		RPCToken:  testRPCToken,
+	}
+
+	server, err := NewStorageServer(storage, conf)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- server.Serve(ctx, conf.RPCSocket) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(conf.RPCSocket)
+		return err == nil
+	}, time.Second, time.Millisecond*10)
+	return conf
+}
+
+func TestRemoteConformance(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (Storage, func(time.Duration)) {
+		clock := newTestClock()
+		conf := serveTestStorage(t, NewMemoryStorage(clock))
+		rs, err := Open(context.Background(), conf)
+		require.NoError(t, err)
+		t.Cleanup(rs.Close)
+		return rs, clock.Sleep
+	})
+}
+
+func TestRemoteStorage(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	_, err := NewStorageServer(NewMemoryStorage(nil), &Configuration{RPCSocket: "rpc.sock", RPCToken: "short"})
+	require.ErrorContains(err, "invalid rpc token length 5")
+
+	conf := serveTestStorage(t, NewMemoryStorage(nil))
+	_, err = OpenRemote(ctx, &Configuration{RPCSocket: conf.RPCSocket, RPCToken: "invalid"})
+	require.ErrorContains(err, "error 401 unauthorized")
+
+	// the replicas share the same quota, and each entry is counted once
+	var wg sync.WaitGroup
+	key := []byte("identity")
+	type result struct {
+		available int
+		err       error
+	}
+	results := make(chan result, 10)
+	for range 2 {
+		rs, err := OpenRemote(ctx, conf)
+		require.NoError(err)
+		defer rs.Close()
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				available, err := rs.CheckLimit(key, time.Hour, 8, true)
+				results <- result{available, err}
+			}()
+		}
+	}
+	wg.Wait()
+	close(results)
+	var exhausted int
+	for r := range results {
+		require.NoError(r.err)
+		if r.available == 0 {
+			exhausted++
+		}
+	}
+	require.Equal(3, exhausted)
+}
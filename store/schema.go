@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/tip/logger"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	badgerKeySchemaVersion = "SCHEMA#VERSION"
+)
+
+// Migration changes the layout of some records to the schema Version, it
+// may be interrupted before the version written, so must be idempotent.
+type Migration struct {
+	Version uint64
+	Name    string
+	apply   func(db *badger.DB) error
+}
+
+// migrations must be ordered by version without gaps, and the last one
+// is the schema version this code base reads and writes.
+var migrations = []*Migration{{
+	Version: 1,
+	Name:    "record the initial layout, e.g. NONCE# value ts||ephemeral||nonce",
+	apply:   func(*badger.DB) error { return nil },
+}}
+
+func SchemaVersion() uint64 {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrateBadger opens the database without the automatic migration, and
+// returns the current version and all migrations pending to apply.
+func MigrateBadger(ctx context.Context, conf *Configuration, dryRun bool) (current uint64, pending []*Migration, err error) {
+	db, err := badger.Open(badger.DefaultOptions(conf.Dir))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		cerr := db.Close()
+		if err == nil && cerr != nil {
+			err = fmt.Errorf("schema migration close error %v", cerr)
+		}
+	}()
+
+	return migrateSchema(db, dryRun)
+}
+
+func (bs *BadgerStorage) ReadSchemaVersion() (uint64, error) {
+	return readSchemaVersion(bs.db)
+}
+
+// checkMigrations ensures the versions start from 1 and ascend by one,
+// otherwise a gap or duplicate would skip or repeat some migration.
+func checkMigrations(ms []*Migration) error {
+	if len(ms) == 0 {
+		return fmt.Errorf("no schema migrations")
+	}
+	for i, m := range ms {
+		if m.Version != uint64(i+1) {
+			return fmt.Errorf("schema migration %d %s out of order, expect %d", m.Version, m.Name, i+1)
+		}
+	}
+	return nil
+}
+
+func migrateSchema(db *badger.DB, dryRun bool) (uint64, []*Migration, error) {
+	err := checkMigrations(migrations)
+	if err != nil {
+		return 0, nil, err
+	}
+	current, err := readSchemaVersion(db)
+	if err != nil {
+		return 0, nil, err
+	}
+	if latest := SchemaVersion(); current > latest {
+		return current, nil, fmt.Errorf("schema version %d is newer than %d supported, downgrade refused", current, latest)
+	}
+
+	var pending []*Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun {
+		return current, pending, nil
+	}
+
+	for _, m := range pending {
+		err := m.apply(db)
+		if err != nil {
+			return current, pending, fmt.Errorf("schema migration %d %s error %v", m.Version, m.Name, err)
+		}
+		err = db.Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte(badgerKeySchemaVersion), uint64ToBytes(m.Version))
+		})
+		if err != nil {
+			return current, pending, err
+		}
+		logger.Infof("store.migrateSchema %d => %d %s\n", current, m.Version, m.Name)
+		current = m.Version
+	}
+	return current, pending, nil
+}
+
+func readSchemaVersion(db *badger.DB) (uint64, error) {
+	txn := db.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get([]byte(badgerKeySchemaVersion))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	v, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, fmt.Errorf("invalid schema version %x", v)
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
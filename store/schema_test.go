@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerSchemaVersion(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	conf := &Configuration{Dir: t.TempDir()}
+
+	bs, err := OpenBadger(ctx, conf)
+	require.NoError(err)
+	version, err := bs.ReadSchemaVersion()
+	require.NoError(err)
+	require.Equal(SchemaVersion(), version)
+	err = bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(badgerKeySchemaVersion))
+	})
+	require.NoError(err)
+	bs.Close()
+
+	current, pending, err := MigrateBadger(ctx, conf, true)
+	require.NoError(err)
+	require.Equal(uint64(0), current)
+	require.Len(pending, len(migrations))
+	current, pending, err = MigrateBadger(ctx, conf, true)
+	require.NoError(err)
+	require.Equal(uint64(0), current)
+	require.Len(pending, len(migrations))
+
+	current, pending, err = MigrateBadger(ctx, conf, false)
+	require.NoError(err)
+	require.Equal(SchemaVersion(), current)
+	require.Len(pending, len(migrations))
+	current, pending, err = MigrateBadger(ctx, conf, true)
+	require.NoError(err)
+	require.Equal(SchemaVersion(), current)
+	require.Len(pending, 0)
+}
+
+func TestBadgerSchemaMigrationOrder(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	conf := &Configuration{Dir: t.TempDir()}
+
+	bs, err := OpenBadger(ctx, conf)
+	require.NoError(err)
+	bs.Close()
+
+	var applied []uint64
+	defer func(old []*Migration) { migrations = old }(migrations)
+	latest := SchemaVersion()
+	for _, version := range []uint64{latest + 1, latest + 2} {
+		migrations = append(migrations, &Migration{
+			Version: version,
+			Name:    "test",
+			apply: func(*badger.DB) error {
+				applied = append(applied, version)
+				return nil
+			},
+		})
+	}
+
+	bs, err = OpenBadger(ctx, conf)
+	require.NoError(err)
+	version, err := bs.ReadSchemaVersion()
+	require.NoError(err)
+	require.Equal(SchemaVersion(), version)
+	require.Equal([]uint64{version - 1, version}, applied)
+	bs.Close()
+
+	migrations = migrations[:len(migrations)-2]
+	_, err = OpenBadger(ctx, conf)
+	require.ErrorContains(err, "downgrade refused")
+	_, _, err = MigrateBadger(ctx, conf, true)
+	require.ErrorContains(err, "downgrade refused")
+}
+
+func TestSchemaMigrationsAscending(t *testing.T) {
+	require := require.New(t)
+	require.NoError(checkMigrations(migrations))
+
+	noop := func(*badger.DB) error { return nil }
+	require.Error(checkMigrations(nil))
+	require.Error(checkMigrations([]*Migration{{Version: 2, apply: noop}}))
+	require.Error(checkMigrations([]*Migration{{Version: 1, apply: noop}, {Version: 3, apply: noop}}))
+	require.Error(checkMigrations([]*Migration{{Version: 1, apply: noop}, {Version: 1, apply: noop}}))
+	require.NoError(checkMigrations([]*Migration{{Version: 1, apply: noop}, {Version: 2, apply: noop}}))
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package store
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenSocket creates the socket with the umask of the mode, so it's never
+// accessible by others before the chmod. The umask is of the process, but
+// it only makes the files created meanwhile more restrictive.
+func listenSocket(path string, mode os.FileMode) (net.Listener, error) {
+	old := syscall.Umask(int(^mode & os.ModePerm))
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}